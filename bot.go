@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	"fmt"
+
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -10,7 +12,13 @@ type EstafetteBot struct {
 	Builder         *EstafetteBuilder   `yaml:"builder,omitempty"`
 	CloneRepository *bool               `yaml:"clone,omitempty" json:",omitempty"`
 	Triggers        []*EstafetteTrigger `yaml:"triggers,omitempty" json:",omitempty"`
+	EnvVars         map[string]string   `yaml:"env,omitempty" json:",omitempty"`
 	Stages          []*EstafetteStage   `yaml:"-" json:",omitempty"`
+	Template        string              `yaml:"template,omitempty" json:",omitempty"`
+	StagesMerge     StagesMergeMode     `yaml:"stagesMerge,omitempty" json:",omitempty"`
+
+	// ResolvedTemplates lists the bot templates the bot got its values from, starting with its own template followed by its parents
+	ResolvedTemplates []string `yaml:"-" json:",omitempty"`
 }
 
 // UnmarshalYAML customizes unmarshalling an EstafetteBot
@@ -21,7 +29,10 @@ func (bot *EstafetteBot) UnmarshalYAML(unmarshal func(interface{}) error) (err e
 		Builder         *EstafetteBuilder   `yaml:"builder"`
 		CloneRepository *bool               `yaml:"clone"`
 		Triggers        []*EstafetteTrigger `yaml:"triggers"`
+		EnvVars         map[string]string   `yaml:"env"`
 		Stages          yaml.MapSlice       `yaml:"stages"`
+		Template        string              `yaml:"template"`
+		StagesMerge     StagesMergeMode     `yaml:"stagesMerge"`
 	}
 
 	// unmarshal to auxiliary type
@@ -34,17 +45,20 @@ func (bot *EstafetteBot) UnmarshalYAML(unmarshal func(interface{}) error) (err e
 	bot.Builder = aux.Builder
 	bot.CloneRepository = aux.CloneRepository
 	bot.Triggers = aux.Triggers
+	bot.EnvVars = aux.EnvVars
+	bot.Template = aux.Template
+	bot.StagesMerge = aux.StagesMerge
 
 	for _, mi := range aux.Stages {
 
 		bytes, err := yaml.Marshal(mi.Value)
 		if err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("stages.%v", mi.Key), err)
 		}
 
 		var stage *EstafetteStage
 		if err := yaml.Unmarshal(bytes, &stage); err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("stages.%v", mi.Key), err)
 		}
 		if stage == nil {
 			stage = &EstafetteStage{}
@@ -65,13 +79,19 @@ func (bot *EstafetteBot) MarshalYAML() (out interface{}, err error) {
 		Builder         *EstafetteBuilder   `yaml:"builder,omitempty"`
 		CloneRepository *bool               `yaml:"clone,omitempty"`
 		Triggers        []*EstafetteTrigger `yaml:"triggers,omitempty"`
+		EnvVars         map[string]string   `yaml:"env,omitempty"`
 		Stages          yaml.MapSlice       `yaml:"stages,omitempty"`
+		Template        string              `yaml:"template,omitempty"`
+		StagesMerge     StagesMergeMode     `yaml:"stagesMerge,omitempty"`
 	}
 
 	// map auxiliary properties
 	aux.Builder = bot.Builder
 	aux.CloneRepository = bot.CloneRepository
 	aux.Triggers = bot.Triggers
+	aux.EnvVars = bot.EnvVars
+	aux.Template = bot.Template
+	aux.StagesMerge = bot.StagesMerge
 
 	for _, stage := range bot.Stages {
 		aux.Stages = append(aux.Stages, yaml.MapItem{
@@ -82,3 +102,36 @@ func (bot *EstafetteBot) MarshalYAML() (out interface{}, err error) {
 
 	return aux, err
 }
+
+// InitFromTemplate uses template values for everything the bot doesn't set itself, including the values the template inherits from its
+// own parent templates
+func (bot *EstafetteBot) InitFromTemplate(botTemplates map[string]*EstafetteBotTemplate) {
+
+	if bot.Template != "" {
+		if botTemplate, found := botTemplates[bot.Template]; found && botTemplate != nil {
+
+			// unknown or circular parent templates are reported by Validate
+			template, resolvedTemplates, err := botTemplate.resolve(botTemplates)
+			if err != nil {
+				return
+			}
+
+			own := EstafetteBotTemplate{
+				Builder:         bot.Builder,
+				CloneRepository: bot.CloneRepository,
+				Triggers:        bot.Triggers,
+				EnvVars:         bot.EnvVars,
+				Stages:          bot.Stages,
+				StagesMerge:     bot.StagesMerge,
+			}
+			own.inheritFrom(template)
+
+			bot.Builder = own.Builder
+			bot.CloneRepository = own.CloneRepository
+			bot.Triggers = own.Triggers
+			bot.EnvVars = own.EnvVars
+			bot.Stages = own.Stages
+			bot.ResolvedTemplates = resolvedTemplates
+		}
+	}
+}
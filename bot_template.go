@@ -0,0 +1,180 @@
+package manifest
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/copier"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EstafetteBotTemplate represents a template for a bot
+type EstafetteBotTemplate struct {
+	Name            string              `yaml:"-"`
+	Builder         *EstafetteBuilder   `yaml:"builder,omitempty"`
+	CloneRepository *bool               `yaml:"clone,omitempty" json:",omitempty"`
+	Triggers        []*EstafetteTrigger `yaml:"triggers,omitempty" json:",omitempty"`
+	EnvVars         map[string]string   `yaml:"env,omitempty" json:",omitempty"`
+	Stages          []*EstafetteStage   `yaml:"-"`
+	Template        string              `yaml:"template,omitempty" json:",omitempty"`
+	StagesMerge     StagesMergeMode     `yaml:"stagesMerge,omitempty" json:",omitempty"`
+}
+
+// UnmarshalYAML customizes unmarshalling an EstafetteBotTemplate
+func (botTemplate *EstafetteBotTemplate) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
+
+	var aux struct {
+		Name            string              `yaml:"name"`
+		Builder         *EstafetteBuilder   `yaml:"builder"`
+		CloneRepository *bool               `yaml:"clone"`
+		Triggers        []*EstafetteTrigger `yaml:"triggers"`
+		EnvVars         map[string]string   `yaml:"env"`
+		Stages          yaml.MapSlice       `yaml:"stages"`
+		Template        string              `yaml:"template"`
+		StagesMerge     StagesMergeMode     `yaml:"stagesMerge"`
+	}
+
+	// unmarshal to auxiliary type
+	if err := unmarshal(&aux); err != nil {
+		return err
+	}
+
+	// map auxiliary properties
+	botTemplate.Name = aux.Name
+	botTemplate.Builder = aux.Builder
+	botTemplate.CloneRepository = aux.CloneRepository
+	botTemplate.Triggers = aux.Triggers
+	botTemplate.EnvVars = aux.EnvVars
+	botTemplate.Template = aux.Template
+	botTemplate.StagesMerge = aux.StagesMerge
+
+	for _, mi := range aux.Stages {
+
+		bytes, err := yaml.Marshal(mi.Value)
+		if err != nil {
+			return wrapUnmarshalError(fmt.Sprintf("stages.%v", mi.Key), err)
+		}
+
+		var stage *EstafetteStage
+		if err := yaml.Unmarshal(bytes, &stage); err != nil {
+			return wrapUnmarshalError(fmt.Sprintf("stages.%v", mi.Key), err)
+		}
+		if stage == nil {
+			stage = &EstafetteStage{}
+		}
+
+		// set the stage name, overwriting the name property if set on the stage explicitly
+		stage.Name = mi.Key.(string)
+
+		botTemplate.Stages = append(botTemplate.Stages, stage)
+	}
+
+	return nil
+}
+
+// MarshalYAML customizes marshalling an EstafetteBotTemplate
+func (botTemplate EstafetteBotTemplate) MarshalYAML() (out interface{}, err error) {
+
+	var aux struct {
+		Name            string              `yaml:"-"`
+		Builder         *EstafetteBuilder   `yaml:"builder,omitempty"`
+		CloneRepository *bool               `yaml:"clone,omitempty"`
+		Triggers        []*EstafetteTrigger `yaml:"triggers,omitempty"`
+		EnvVars         map[string]string   `yaml:"env,omitempty"`
+		Stages          yaml.MapSlice       `yaml:"stages,omitempty"`
+		Template        string              `yaml:"template,omitempty"`
+		StagesMerge     StagesMergeMode     `yaml:"stagesMerge,omitempty"`
+	}
+
+	// map auxiliary properties
+	aux.Builder = botTemplate.Builder
+	aux.CloneRepository = botTemplate.CloneRepository
+	aux.Triggers = botTemplate.Triggers
+	aux.EnvVars = botTemplate.EnvVars
+	aux.Template = botTemplate.Template
+	aux.StagesMerge = botTemplate.StagesMerge
+
+	for _, stage := range botTemplate.Stages {
+		aux.Stages = append(aux.Stages, yaml.MapItem{
+			Key:   stage.Name,
+			Value: stage,
+		})
+	}
+
+	return aux, err
+}
+
+// DeepCopy provides a copy of all nested pointers
+func (botTemplate EstafetteBotTemplate) DeepCopy() (target EstafetteBotTemplate) {
+
+	copier.CopyWithOption(&target, botTemplate, copier.Option{IgnoreEmpty: true, DeepCopy: true})
+
+	return
+}
+
+// resolve returns a copy of the template with everything it inherits from its parent templates filled in, together with the names of all
+// templates involved, starting with this one
+func (botTemplate *EstafetteBotTemplate) resolve(botTemplates map[string]*EstafetteBotTemplate) (resolved EstafetteBotTemplate, resolvedTemplates []string, err error) {
+
+	template, resolvedTemplates, err := resolveTemplate("bot", botTemplate, botTemplateLookup(botTemplates))
+	if err != nil {
+		return resolved, nil, err
+	}
+
+	return *template.(*EstafetteBotTemplate), resolvedTemplates, nil
+}
+
+// validate checks that the parent template exists and that the template doesn't inherit from itself
+func (botTemplate *EstafetteBotTemplate) validate(botTemplates map[string]*EstafetteBotTemplate) (err error) {
+
+	var errs ValidationErrors
+
+	errs.add("", validateTemplateParent("bot", botTemplate, botTemplateLookup(botTemplates)))
+	errs.add("stagesMerge", validateStagesMerge(botTemplate.StagesMerge))
+
+	return errs.errorOrNil()
+}
+
+// inheritFrom uses the values of parent for everything the template doesn't set itself; env vars are merged key by key and stages are combined
+// according to StagesMerge
+func (botTemplate *EstafetteBotTemplate) inheritFrom(parent EstafetteBotTemplate) {
+	botTemplate.inheritedFields().inheritFrom(parent.inheritedFields())
+}
+
+func (botTemplate *EstafetteBotTemplate) templateName() string {
+	return botTemplate.Name
+}
+
+func (botTemplate *EstafetteBotTemplate) parentTemplateName() string {
+	return botTemplate.Template
+}
+
+func (botTemplate *EstafetteBotTemplate) deepCopy() inheritableTemplate {
+	copied := botTemplate.DeepCopy()
+	return &copied
+}
+
+func (botTemplate *EstafetteBotTemplate) inheritFromTemplate(parent inheritableTemplate) {
+	botTemplate.inheritFrom(*parent.(*EstafetteBotTemplate))
+}
+
+func (botTemplate *EstafetteBotTemplate) inheritedFields() inheritedTemplateFields {
+	return inheritedTemplateFields{
+		builder:         &botTemplate.Builder,
+		cloneRepository: &botTemplate.CloneRepository,
+		triggers:        &botTemplate.Triggers,
+		envVars:         &botTemplate.EnvVars,
+		stages:          &botTemplate.Stages,
+		stagesMerge:     botTemplate.StagesMerge,
+	}
+}
+
+// botTemplateLookup returns a lookup of bot templates by name for resolveTemplate and validateTemplateParent
+func botTemplateLookup(botTemplates map[string]*EstafetteBotTemplate) templateLookup {
+	return func(name string) (inheritableTemplate, bool) {
+		botTemplate, found := botTemplates[name]
+		if !found || botTemplate == nil {
+			return nil, false
+		}
+		return botTemplate, true
+	}
+}
@@ -0,0 +1,177 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotTemplateInheritance(t *testing.T) {
+	t.Run("ResolvesTheWholeTemplateChain", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+botTemplates:
+  base:
+    clone: true
+    triggers:
+    - github:
+        events:
+        - pull_request
+    stages:
+      welcome:
+        image: extensions/github-pull-request-welcome:stable
+  labeler:
+    template: base
+    stagesMerge: append
+    stages:
+      label:
+        image: extensions/github-labeler:stable
+
+bots:
+  pr-bot:
+    template: labeler
+  other-bot:
+    template: base
+    stages:
+      greet:
+        image: extensions/greeter:stable`, true)
+
+		assert.Nil(t, err)
+
+		prBot := manifest.Bots[0]
+		assert.Equal(t, []string{"labeler", "base"}, prBot.ResolvedTemplates)
+		assert.True(t, *prBot.CloneRepository)
+		if assert.Equal(t, 2, len(prBot.Stages)) {
+			assert.Equal(t, "welcome", prBot.Stages[0].Name)
+			assert.Equal(t, "label", prBot.Stages[1].Name)
+		}
+
+		otherBot := manifest.Bots[1]
+		assert.Equal(t, []string{"base"}, otherBot.ResolvedTemplates)
+		if assert.Equal(t, 1, len(otherBot.Stages)) {
+			assert.Equal(t, "greet", otherBot.Stages[0].Name)
+		}
+	})
+
+	t.Run("DefaultsTemplateTriggersWithTheBotName", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+botTemplates:
+  base:
+    triggers:
+    - github:
+        events:
+        - pull_request
+    stages:
+      welcome:
+        image: extensions/github-pull-request-welcome:stable
+
+bots:
+  pr-bot:
+    template: base
+  other-bot:
+    template: base`, true)
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(manifest.Bots[0].Triggers)) {
+			assert.Equal(t, "pr-bot", manifest.Bots[0].Triggers[0].BotAction.Bot)
+		}
+		if assert.Equal(t, 1, len(manifest.Bots[1].Triggers)) {
+			assert.Equal(t, "other-bot", manifest.Bots[1].Triggers[0].BotAction.Bot)
+		}
+		assert.Nil(t, manifest.BotTemplates[0].Triggers[0].BotAction)
+	})
+
+	t.Run("ReturnsErrorForUnknownTemplateOfBot", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+bots:
+  pr-bot:
+    template: bsae
+    stages:
+      welcome:
+        image: extensions/github-pull-request-welcome:stable`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "bots.pr-bot.template", errs[0].Path)
+				assert.Equal(t, "Bot pr-bot uses unknown bot template bsae", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForTemplatesInheritingFromThemselves", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+botTemplates:
+  a:
+    template: b
+  b:
+    template: a
+
+bots:
+  pr-bot:
+    template: a
+    stages:
+      welcome:
+        image: extensions/github-pull-request-welcome:stable`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 2, len(errs)) {
+				assert.Equal(t, "botTemplates.a.template", errs[0].Path)
+				assert.Equal(t, "Bot template a inherits from itself through a -> b -> a", errs[0].Message)
+				assert.Equal(t, "botTemplates.b.template", errs[1].Path)
+			}
+		}
+		assert.Equal(t, 0, len(manifest.Bots[0].ResolvedTemplates))
+	})
+
+	t.Run("MergesEnvVarsKeyByKey", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+botTemplates:
+  base:
+    env:
+      LOG_LEVEL: info
+      ORG: estafette
+    stages:
+      welcome:
+        image: extensions/github-pull-request-welcome:stable
+
+bots:
+  pr-bot:
+    template: base
+    env:
+      LOG_LEVEL: debug`, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"LOG_LEVEL": "debug", "ORG": "estafette"}, manifest.Bots[0].EnvVars)
+	})
+}
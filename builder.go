@@ -65,17 +65,17 @@ func (builder *EstafetteBuilder) validate(preferences EstafetteManifestPreferenc
 			}
 		}
 
-		return fmt.Errorf("builder os should be one of: %v", strings.Join(allowedOperatingSystems, ", "))
+		return &ValidationError{Path: "os", Message: fmt.Sprintf("builder os should be one of: %v", strings.Join(allowedOperatingSystems, ", "))}
 	}
 
 	tracks, ok := preferences.BuilderTracksPerOperatingSystem[builder.OperatingSystem]
 
 	if !ok {
-		return fmt.Errorf("no track preferences have been configured for os %v", builder.OperatingSystem)
+		return &ValidationError{Path: "os", Message: fmt.Sprintf("no track preferences have been configured for os %v", builder.OperatingSystem)}
 	}
 
 	if !foundation.StringArrayContains(tracks, builder.Track) {
-		return fmt.Errorf("builder track should be one of: %v", strings.Join(tracks, ", "))
+		return &ValidationError{Path: "track", Message: fmt.Sprintf("builder track should be one of: %v", strings.Join(tracks, ", "))}
 	}
 
 	return nil
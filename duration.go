@@ -0,0 +1,131 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is used to unmarshal/marshal a duration in go syntax, like 90s or 1h30m
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalYAML customizes unmarshalling a Duration
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+// MarshalYAML customizes marshalling a Duration
+func (d Duration) MarshalYAML() (out interface{}, err error) {
+	return d.String(), nil
+}
+
+// UnmarshalJSON customizes unmarshalling a Duration
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+// MarshalJSON customizes marshalling a Duration
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) parse(s string) error {
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("Duration %v is not valid, use for instance 90s or 1h30m", s)
+	}
+	d.Duration = duration
+	return nil
+}
+
+// EstafetteRetries determines how often a failing stage or service is retried and how long to wait before each retry; the wait doubles
+// for every next retry
+type EstafetteRetries struct {
+	Count   int       `yaml:"count,omitempty" json:",omitempty"`
+	Backoff *Duration `yaml:"backoff,omitempty" json:",omitempty"`
+}
+
+// UnmarshalYAML customizes unmarshalling EstafetteRetries; a plain number is used as the count
+func (r *EstafetteRetries) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
+
+	var count int
+	if err := unmarshal(&count); err == nil {
+		r.Count = count
+		return nil
+	}
+
+	var aux struct {
+		Count   int       `yaml:"count"`
+		Backoff *Duration `yaml:"backoff"`
+	}
+	if err := unmarshal(&aux); err != nil {
+		return err
+	}
+	r.Count = aux.Count
+	r.Backoff = aux.Backoff
+
+	return nil
+}
+
+// Delay returns how long to wait before retry attempt, starting at 1
+func (r *EstafetteRetries) Delay(attempt int) time.Duration {
+	if r.Backoff == nil || attempt < 1 {
+		return 0
+	}
+	return r.Backoff.Duration << uint(attempt-1)
+}
+
+// validate checks the count and backoff aren't negative
+func (r *EstafetteRetries) validate() (err error) {
+
+	var errs ValidationErrors
+
+	if r.Count < 0 {
+		errs.addf("count", "Retries count %v should not be negative", r.Count)
+	}
+	if r.Backoff != nil && r.Backoff.Duration < 0 {
+		errs.addf("backoff", "Retries backoff %v should not be negative", r.Backoff)
+	}
+
+	return errs.errorOrNil()
+}
+
+// validateExecutionLimits checks the timeout and retries shared by stages and services
+func validateExecutionLimits(timeout *Duration, retries *EstafetteRetries) (err error) {
+
+	var errs ValidationErrors
+
+	if timeout != nil && timeout.Duration <= 0 {
+		errs.addf("timeout", "Timeout %v should be positive", timeout)
+	}
+	if retries != nil {
+		errs.add("retries", retries.validate())
+	}
+
+	return errs.errorOrNil()
+}
+
+// validateExecutionMaximums checks the timeout and retries shared by stages and services against the maximums in preferences
+func validateExecutionMaximums(timeout *Duration, retries *EstafetteRetries, preferences EstafetteManifestPreferences) (err error) {
+
+	var errs ValidationErrors
+
+	if timeout != nil && preferences.MaxTimeout != nil && preferences.MaxTimeout.Duration > 0 && timeout.Duration > preferences.MaxTimeout.Duration {
+		errs.addf("timeout", "Timeout %v is more than the maximum of %v", timeout, preferences.MaxTimeout)
+	}
+	if retries != nil && preferences.MaxRetries > 0 && retries.Count > preferences.MaxRetries {
+		errs.addf("retries.count", "Retries count %v is more than the maximum of %v", retries.Count, preferences.MaxRetries)
+	}
+
+	return errs.errorOrNil()
+}
@@ -0,0 +1,199 @@
+package manifest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestDuration(t *testing.T) {
+	t.Run("ReturnsDurationForGoSyntax", func(t *testing.T) {
+
+		var d Duration
+
+		// act
+		err := yaml.Unmarshal([]byte("1h30m"), &d)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 90*time.Minute, d.Duration)
+	})
+
+	t.Run("ReturnsErrorForInvalidDuration", func(t *testing.T) {
+
+		var d Duration
+
+		// act
+		err := yaml.Unmarshal([]byte("10 minutes"), &d)
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Duration 10 minutes is not valid, use for instance 90s or 1h30m", err.Error())
+		}
+	})
+
+	t.Run("ReturnsSameYamlAndJson", func(t *testing.T) {
+
+		d := Duration{90 * time.Second}
+
+		// act
+		yamlBytes, err := yaml.Marshal(d)
+		assert.Nil(t, err)
+		jsonBytes, err := json.Marshal(d)
+		assert.Nil(t, err)
+
+		assert.Equal(t, "1m30s\n", string(yamlBytes))
+		assert.Equal(t, `"1m30s"`, string(jsonBytes))
+	})
+}
+
+func TestRetries(t *testing.T) {
+	t.Run("ReturnsCountForPlainNumber", func(t *testing.T) {
+
+		var r EstafetteRetries
+
+		// act
+		err := yaml.Unmarshal([]byte("3"), &r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 3, r.Count)
+		assert.Nil(t, r.Backoff)
+	})
+
+	t.Run("ReturnsCountAndBackoff", func(t *testing.T) {
+
+		var r EstafetteRetries
+
+		// act
+		err := yaml.Unmarshal([]byte("count: 2\nbackoff: 10s"), &r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, r.Count)
+		assert.Equal(t, 10*time.Second, r.Backoff.Duration)
+	})
+
+	t.Run("ReturnsBackoffDoublingForEveryAttempt", func(t *testing.T) {
+
+		r := EstafetteRetries{Count: 3, Backoff: &Duration{10 * time.Second}}
+
+		// act
+		delays := []time.Duration{r.Delay(1), r.Delay(2), r.Delay(3)}
+
+		assert.Equal(t, []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second}, delays)
+	})
+}
+
+const executionLimitsManifest = `
+stages:
+  test:
+    image: golang:1.17-alpine
+    timeout: 2h
+    retries:
+      count: 5
+      backoff: 30s
+    allowFailure: true
+    services:
+    - name: database
+      image: postgres:14
+      timeout: 30m
+      retries: 1
+      allowFailure: false
+  lint:
+    timeout: 10m
+    retries: 2
+    allowFailure: true
+    parallelStages:
+      vet:
+        image: golang:1.17-alpine
+      staticcheck:
+        image: golang:1.17-alpine
+        timeout: 5m
+        allowFailure: false`
+
+func TestExecutionLimits(t *testing.T) {
+	t.Run("ReturnsTimeoutRetriesAndAllowFailure", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, executionLimitsManifest, true)
+
+		assert.Nil(t, err)
+		stage := manifest.Stages[0]
+		assert.Equal(t, 2*time.Hour, stage.Timeout.Duration)
+		assert.Equal(t, 5, stage.Retries.Count)
+		assert.Equal(t, 30*time.Second, stage.Retries.Backoff.Duration)
+		assert.True(t, *stage.AllowFailure)
+		assert.Equal(t, 30*time.Minute, stage.Services[0].Timeout.Duration)
+		assert.Equal(t, 1, stage.Services[0].Retries.Count)
+		if assert.NotNil(t, stage.Services[0].AllowFailure) {
+			assert.False(t, *stage.Services[0].AllowFailure)
+		}
+	})
+
+	t.Run("ParallelStagesInheritLimitsTheyDoNotSet", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, executionLimitsManifest, true)
+
+		assert.Nil(t, err)
+		vet := manifest.Stages[1].ParallelStages[0]
+		assert.Equal(t, 10*time.Minute, vet.Timeout.Duration)
+		assert.Equal(t, 2, vet.Retries.Count)
+		assert.True(t, *vet.AllowFailure)
+		staticcheck := manifest.Stages[1].ParallelStages[1]
+		assert.Equal(t, 5*time.Minute, staticcheck.Timeout.Duration)
+		assert.Equal(t, 2, staticcheck.Retries.Count)
+		assert.False(t, *staticcheck.AllowFailure)
+	})
+
+	t.Run("ReturnsErrorsForNonPositiveTimeoutAndNegativeRetries", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  test:
+    image: golang:1.17-alpine
+    timeout: 0s
+    retries:
+      count: -1
+      backoff: -5s
+    services:
+    - name: database
+      image: postgres:14
+      timeout: -1m`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 4, len(errs)) {
+				assert.Equal(t, "stages.test.timeout", errs[0].Path)
+				assert.Equal(t, "Timeout 0s should be positive", errs[0].Message)
+				assert.Equal(t, "stages.test.retries.count", errs[1].Path)
+				assert.Equal(t, "Retries count -1 should not be negative", errs[1].Message)
+				assert.Equal(t, "stages.test.retries.backoff", errs[2].Path)
+				assert.Equal(t, "Retries backoff -5s should not be negative", errs[2].Message)
+				assert.Equal(t, "stages.test.services[0].timeout", errs[3].Path)
+				assert.Equal(t, "Timeout -1m0s should be positive", errs[3].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorsForLimitsAboveTheMaximumsInPreferences", func(t *testing.T) {
+
+		preferences := GetDefaultManifestPreferences()
+		preferences.MaxTimeout = &Duration{time.Hour}
+		preferences.MaxRetries = 3
+
+		// act
+		_, err := ReadManifest(preferences, executionLimitsManifest, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 2, len(errs)) {
+				assert.Equal(t, "stages.test.timeout", errs[0].Path)
+				assert.Equal(t, "Timeout 2h0m0s is more than the maximum of 1h0m0s", errs[0].Message)
+				assert.Equal(t, "stages.test.retries.count", errs[1].Path)
+				assert.Equal(t, "Retries count 5 is more than the maximum of 3", errs[1].Message)
+			}
+		}
+	})
+}
@@ -0,0 +1,462 @@
+package manifest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// EditableManifest allows for changing a .estafette.yaml while keeping comments, key order, quoting style and anchors intact;
+// every edit only rewrites the lines it affects and leaves the rest of the file untouched
+type EditableManifest struct {
+	lines    []string
+	document *yamlv3.Node
+}
+
+// ReadEditableManifest parses the string representation of .estafette.yaml into an EditableManifest
+func ReadEditableManifest(manifestString string) (*EditableManifest, error) {
+	m := &EditableManifest{
+		lines: strings.Split(manifestString, "\n"),
+	}
+	if err := m.parse(); err != nil {
+		return nil, withPositions(err, []byte(manifestString), "")
+	}
+
+	return m, nil
+}
+
+// String returns the edited manifest
+func (m *EditableManifest) String() string {
+	return strings.Join(m.lines, "\n")
+}
+
+// Manifest reads the edited manifest into an EstafetteManifest object
+func (m *EditableManifest) Manifest(preferences *EstafetteManifestPreferences, validate bool) (EstafetteManifest, error) {
+	return ReadManifest(preferences, m.String(), validate)
+}
+
+// SetStageImage changes the image of a build stage, or adds it if the stage doesn't have one yet
+func (m *EditableManifest) SetStageImage(stageName, image string) error {
+
+	stages := m.stages()
+	if stages == nil {
+		return fmt.Errorf("The manifest has no stages")
+	}
+
+	_, stage := mappingEntry(stages, stageName)
+	if stage == nil {
+		return fmt.Errorf("Stage %v does not exist", stageName)
+	}
+	if stage.Kind != yamlv3.MappingNode {
+		return fmt.Errorf("Stage %v is not a mapping", stageName)
+	}
+
+	if _, imageValue := mappingEntry(stage, "image"); imageValue != nil {
+		return m.edit(func() error {
+			return m.replaceScalar(imageValue, image, stage.Style&yamlv3.FlowStyle != 0)
+		})
+	}
+
+	if stage.Style&yamlv3.FlowStyle != 0 || len(stage.Content) == 0 {
+		return fmt.Errorf("Stage %v has no image and is not a block mapping to add one to", stageName)
+	}
+
+	// insert the image as first property of the stage, using the indentation of the existing properties
+	firstKey := stage.Content[0]
+	indent := strings.Repeat(" ", firstKey.Column-1)
+
+	return m.edit(func() error {
+		m.insertLines(firstKey.Line-1, indent+"image: "+formatScalar(image, yamlv3.Style(0)))
+		return nil
+	})
+}
+
+// AddRelease appends a release target to the releases section, creating the section if it doesn't exist yet
+func (m *EditableManifest) AddRelease(release *EstafetteRelease) error {
+
+	if release == nil || release.Name == "" {
+		return fmt.Errorf("The release to add should have a name")
+	}
+
+	root := m.root()
+	if root == nil {
+		return fmt.Errorf("The manifest is not a mapping")
+	}
+
+	body, err := yaml.Marshal(release)
+	if err != nil {
+		return err
+	}
+	bodyLines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	if strings.TrimSpace(string(body)) == "{}" {
+		bodyLines = nil
+	}
+
+	indentUnit := m.indentUnit()
+
+	releasesKey, releases := mappingEntry(root, "releases")
+	if releases != nil && releases.Kind == yamlv3.MappingNode && releases.Style&yamlv3.FlowStyle == 0 && len(releases.Content) > 0 {
+
+		if _, existing := mappingEntry(releases, release.Name); existing != nil {
+			return fmt.Errorf("Release %v already exists", release.Name)
+		}
+
+		lastKey := releases.Content[len(releases.Content)-2]
+		indent := strings.Repeat(" ", lastKey.Column-1)
+		lines := releaseLines(indent, indentUnit, release.Name, bodyLines)
+
+		// keep a blank line between releases if the existing ones are separated that way
+		if start := m.commentStart(lastKey.Line-1, lastKey.Column-1); start > 0 && strings.TrimSpace(m.lines[start-1]) == "" && len(releases.Content) > 2 {
+			lines = append([]string{""}, lines...)
+		}
+
+		return m.edit(func() error {
+			m.insertLines(m.blockEnd(releasesKey), lines...)
+			return nil
+		})
+	}
+
+	if releases != nil && (releases.Kind != yamlv3.ScalarNode || releases.Value != "") {
+		return fmt.Errorf("The releases section is not a block mapping to add a release to")
+	}
+
+	return m.edit(func() error {
+		if releasesKey != nil {
+			// releases key exists without any value
+			m.lines[releasesKey.Line-1] = strings.Repeat(" ", releasesKey.Column-1) + "releases:"
+			m.insertLines(releasesKey.Line, releaseLines(strings.Repeat(" ", releasesKey.Column-1+indentUnit), indentUnit, release.Name, bodyLines)...)
+			return nil
+		}
+
+		// trim trailing empty lines, then append the section at the end of the file
+		end := len(m.lines)
+		for end > 0 && strings.TrimSpace(m.lines[end-1]) == "" {
+			end--
+		}
+		m.lines = m.lines[:end]
+		if end > 0 {
+			m.lines = append(m.lines, "")
+		}
+		m.lines = append(m.lines, "releases:")
+		m.lines = append(m.lines, releaseLines(strings.Repeat(" ", indentUnit), indentUnit, release.Name, bodyLines)...)
+		m.lines = append(m.lines, "")
+		return nil
+	})
+}
+
+// RemoveTrigger removes the build trigger at index
+func (m *EditableManifest) RemoveTrigger(index int) error {
+	root := m.root()
+	if root == nil {
+		return fmt.Errorf("The manifest is not a mapping")
+	}
+
+	return m.removeSequenceItem(root, "triggers", index)
+}
+
+// RemoveReleaseTrigger removes the trigger at index from a release target
+func (m *EditableManifest) RemoveReleaseTrigger(releaseName string, index int) error {
+	root := m.root()
+	if root == nil {
+		return fmt.Errorf("The manifest is not a mapping")
+	}
+
+	_, releases := mappingEntry(root, "releases")
+	if releases == nil {
+		return fmt.Errorf("The manifest has no releases")
+	}
+	_, release := mappingEntry(releases, releaseName)
+	if release == nil {
+		return fmt.Errorf("Release %v does not exist", releaseName)
+	}
+
+	return m.removeSequenceItem(release, "triggers", index)
+}
+
+func (m *EditableManifest) removeSequenceItem(parent *yamlv3.Node, key string, index int) error {
+
+	sequenceKey, sequence := mappingEntry(parent, key)
+	if sequence == nil || sequence.Kind != yamlv3.SequenceNode {
+		return fmt.Errorf("There are no %v to remove from", key)
+	}
+	if sequence.Style&yamlv3.FlowStyle != 0 {
+		return fmt.Errorf("Removing from a flow style sequence is not supported")
+	}
+	if index < 0 || index >= len(sequence.Content) {
+		return fmt.Errorf("There is no item at index %v in %v", index, key)
+	}
+
+	return m.edit(func() error {
+		// remove the whole section if this is the only item
+		if len(sequence.Content) == 1 {
+			m.removeLines(m.commentStart(sequenceKey.Line-1, sequenceKey.Column-1), m.blockEnd(sequenceKey))
+			return nil
+		}
+
+		item := sequence.Content[index]
+		dashIndent := strings.LastIndex(m.lines[item.Line-1][:byteOffset(m.lines[item.Line-1], item.Column-1)], "-")
+
+		start := m.commentStart(item.Line-1, dashIndent)
+		var end int
+		if index+1 < len(sequence.Content) {
+			end = m.commentStart(sequence.Content[index+1].Line-1, dashIndent)
+		} else {
+			end = m.blockEnd(sequenceKey)
+		}
+		m.removeLines(start, end)
+		return nil
+	})
+}
+
+// edit applies a change to the lines and reparses them, reverting the change if it results in invalid yaml
+func (m *EditableManifest) edit(change func() error) error {
+	original := append([]string{}, m.lines...)
+
+	if err := change(); err != nil {
+		m.lines = original
+		return err
+	}
+
+	if err := m.parse(); err != nil {
+		m.lines = original
+		_ = m.parse()
+		return err
+	}
+
+	return nil
+}
+
+func (m *EditableManifest) parse() error {
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal([]byte(m.String()), &document); err != nil {
+		return err
+	}
+	m.document = &document
+
+	return nil
+}
+
+func (m *EditableManifest) root() *yamlv3.Node {
+	if m.document == nil || len(m.document.Content) == 0 || m.document.Content[0].Kind != yamlv3.MappingNode {
+		return nil
+	}
+	return m.document.Content[0]
+}
+
+func (m *EditableManifest) stages() *yamlv3.Node {
+	root := m.root()
+	if root == nil {
+		return nil
+	}
+	if _, stages := mappingEntry(root, "stages"); stages != nil {
+		return stages
+	}
+	// provide backwards compatibility for the deprecated pipelines section now renamed to stages
+	_, pipelines := mappingEntry(root, "pipelines")
+	return pipelines
+}
+
+// indentUnit returns the number of spaces used to indent stages, defaulting to 2
+func (m *EditableManifest) indentUnit() int {
+	if stages := m.stages(); stages != nil && stages.Kind == yamlv3.MappingNode && len(stages.Content) > 0 && stages.Style&yamlv3.FlowStyle == 0 {
+		if unit := stages.Content[0].Column - 1; unit > 0 {
+			return unit
+		}
+	}
+	return 2
+}
+
+// blockEnd returns the index of the line after the last line holding content for the value of key; trailing empty lines and comments are
+// considered to belong to whatever follows
+func (m *EditableManifest) blockEnd(key *yamlv3.Node) int {
+	keyIndent := key.Column - 1
+	end := key.Line
+	for i := key.Line; i < len(m.lines); i++ {
+		line := m.lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent < keyIndent || (indent == keyIndent && !strings.HasPrefix(trimmed, "- ") && trimmed != "-") {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// commentStart returns the index of the first comment line directly preceding line at or beyond indent, so comments are removed along with what they describe
+func (m *EditableManifest) commentStart(line, indent int) int {
+	for line > 0 {
+		previous := m.lines[line-1]
+		trimmed := strings.TrimSpace(previous)
+		if !strings.HasPrefix(trimmed, "#") || len(previous)-len(strings.TrimLeft(previous, " ")) < indent {
+			break
+		}
+		line--
+	}
+	return line
+}
+
+func (m *EditableManifest) insertLines(at int, lines ...string) {
+	result := make([]string, 0, len(m.lines)+len(lines))
+	result = append(result, m.lines[:at]...)
+	result = append(result, lines...)
+	result = append(result, m.lines[at:]...)
+	m.lines = result
+}
+
+func (m *EditableManifest) removeLines(from, to int) {
+	m.lines = append(m.lines[:from], m.lines[to:]...)
+}
+
+// replaceScalar overwrites the text of a single line scalar, keeping its quoting style and whatever follows it on the line, like a comment
+func (m *EditableManifest) replaceScalar(node *yamlv3.Node, value string, inFlow bool) error {
+	if node.Kind != yamlv3.ScalarNode && node.Kind != yamlv3.AliasNode {
+		return fmt.Errorf("Value at line %v is not a scalar", node.Line)
+	}
+	if node.Style&(yamlv3.LiteralStyle|yamlv3.FoldedStyle) != 0 {
+		return fmt.Errorf("Value at line %v is a multi-line scalar, which is not supported", node.Line)
+	}
+
+	line := m.lines[node.Line-1]
+	start := scalarStart(line, byteOffset(line, node.Column-1))
+	end := scalarEnd(line, start, node.Style, inFlow)
+	if end < 0 {
+		return fmt.Errorf("Value at line %v spans multiple lines, which is not supported", node.Line)
+	}
+
+	m.lines[node.Line-1] = line[:start] + formatScalar(value, node.Style) + line[end:]
+	return nil
+}
+
+// replaceKey renames a mapping key in place; a plain key ends right before its colon, so its own length tells where it ends
+func (m *EditableManifest) replaceKey(key *yamlv3.Node, name string) error {
+	if key.Style&(yamlv3.SingleQuotedStyle|yamlv3.DoubleQuotedStyle) != 0 {
+		return m.replaceScalar(key, name, true)
+	}
+	if key.Kind != yamlv3.ScalarNode {
+		return fmt.Errorf("Key at line %v is not a scalar", key.Line)
+	}
+
+	line := m.lines[key.Line-1]
+	start := byteOffset(line, key.Column-1)
+	end := start + len(key.Value)
+	if end > len(line) || line[start:end] != key.Value {
+		return fmt.Errorf("Key at line %v spans multiple lines, which is not supported", key.Line)
+	}
+
+	m.lines[key.Line-1] = line[:start] + formatScalar(name, key.Style) + line[end:]
+	return nil
+}
+
+// scalarStart returns the byte offset of the scalar's value, skipping an anchor like &image and a tag like !!str in front of it, since
+// those start at the column of the node as well and should be kept
+func scalarStart(line string, start int) int {
+	for start < len(line) && (line[start] == '&' || line[start] == '!') {
+		for start < len(line) && line[start] != ' ' && line[start] != '\t' {
+			start++
+		}
+		for start < len(line) && (line[start] == ' ' || line[start] == '\t') {
+			start++
+		}
+	}
+	return start
+}
+
+// scalarEnd returns the byte offset directly after the scalar starting at start, or -1 if it doesn't end on this line
+func scalarEnd(line string, start int, style yamlv3.Style, inFlow bool) int {
+	switch {
+	case style&yamlv3.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+		return -1
+	case style&yamlv3.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+				continue
+			}
+			if line[i] == '"' {
+				return i + 1
+			}
+		}
+		return -1
+	}
+
+	end := len(line)
+	if i := strings.Index(line[start:], " #"); i >= 0 {
+		end = start + i
+	}
+	if inFlow {
+		if i := strings.IndexAny(line[start:end], ",]}"); i >= 0 {
+			end = start + i
+		}
+	}
+	return start + len(strings.TrimRight(line[start:end], " \t"))
+}
+
+// formatScalar renders value in the requested quoting style, falling back to single quotes for plain values that would otherwise change meaning
+func formatScalar(value string, style yamlv3.Style) string {
+	switch {
+	case style&yamlv3.DoubleQuotedStyle != 0:
+		return strconv.Quote(value)
+	case style&yamlv3.SingleQuotedStyle != 0:
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+
+	// let the yaml encoder decide whether a plain value needs quoting
+	out, err := yaml.Marshal(value)
+	if err != nil {
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// releaseLines renders a release target as lines for insertion in the releases section
+func releaseLines(indent string, indentUnit int, name string, bodyLines []string) []string {
+	if len(bodyLines) == 0 {
+		return []string{indent + formatScalar(name, yamlv3.Style(0)) + ": {}"}
+	}
+	lines := []string{indent + formatScalar(name, yamlv3.Style(0)) + ":"}
+	bodyIndent := indent + strings.Repeat(" ", indentUnit)
+	for _, l := range bodyLines {
+		lines = append(lines, bodyIndent+l)
+	}
+	return lines
+}
+
+// mappingEntry returns the key and value nodes for key in a mapping node
+func mappingEntry(mapping *yamlv3.Node, key string) (*yamlv3.Node, *yamlv3.Node) {
+	if mapping == nil || mapping.Kind != yamlv3.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// byteOffset converts a zero based character column into a byte offset within line
+func byteOffset(line string, column int) int {
+	offset := 0
+	for i := 0; i < column && offset < len(line); i++ {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	return offset
+}
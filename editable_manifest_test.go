@@ -0,0 +1,334 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditableManifestSetStageImage(t *testing.T) {
+	t.Run("ReplacesOnlyTheImageKeepingCommentsAndQuoting", func(t *testing.T) {
+
+		input := `# build the app
+stages:
+  build:
+    image: golang:1.16-alpine # pinned for now
+    commands:
+    - go build ./...
+
+  bake:
+    commands:
+    - docker build .
+    image: 'extensions/docker:stable'
+`
+		manifest, err := ReadEditableManifest(input)
+		assert.Nil(t, err)
+
+		// act
+		err = manifest.SetStageImage("build", "golang:1.17-alpine")
+		assert.Nil(t, err)
+		err = manifest.SetStageImage("bake", "extensions/docker:dev")
+		assert.Nil(t, err)
+
+		assert.Equal(t, `# build the app
+stages:
+  build:
+    image: golang:1.17-alpine # pinned for now
+    commands:
+    - go build ./...
+
+  bake:
+    commands:
+    - docker build .
+    image: 'extensions/docker:dev'
+`, manifest.String())
+	})
+
+	t.Run("KeepsAnchorAndTagOfImageAndReplacesAlias", func(t *testing.T) {
+
+		input := `stages:
+  build:
+    image: &img golang:1.14
+  test:
+    image: *img
+  lint:
+    image: !!str &lint golangci/golangci-lint:v1.42
+`
+		manifest, err := ReadEditableManifest(input)
+		assert.Nil(t, err)
+
+		// act
+		err = manifest.SetStageImage("build", "golang:1.17-alpine")
+		assert.Nil(t, err)
+		err = manifest.SetStageImage("lint", "golangci/golangci-lint:v1.43")
+		assert.Nil(t, err)
+
+		assert.Equal(t, `stages:
+  build:
+    image: &img golang:1.17-alpine
+  test:
+    image: *img
+  lint:
+    image: !!str &lint golangci/golangci-lint:v1.43
+`, manifest.String())
+
+		parsed, err := manifest.Manifest(nil, false)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "golang:1.17-alpine", parsed.Stages[1].ContainerImage)
+		}
+
+		// act
+		err = manifest.SetStageImage("test", "golang:1.16-alpine")
+
+		assert.Nil(t, err)
+		assert.Contains(t, manifest.String(), "  test:\n    image: golang:1.16-alpine\n")
+	})
+
+	t.Run("AddsImageIfStageHasNone", func(t *testing.T) {
+
+		input := `stages:
+  build:
+    commands:
+    - go build ./...
+`
+		manifest, err := ReadEditableManifest(input)
+		assert.Nil(t, err)
+
+		// act
+		err = manifest.SetStageImage("build", "golang:1.17-alpine")
+
+		assert.Nil(t, err)
+		assert.Equal(t, `stages:
+  build:
+    image: golang:1.17-alpine
+    commands:
+    - go build ./...
+`, manifest.String())
+	})
+
+	t.Run("ReturnsErrorIfStageDoesNotExist", func(t *testing.T) {
+
+		manifest, err := ReadEditableManifest(`stages:
+  build:
+    image: golang
+`)
+		assert.Nil(t, err)
+
+		// act
+		err = manifest.SetStageImage("bake", "docker")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestEditableManifestAddRelease(t *testing.T) {
+	t.Run("AppendsReleaseToExistingReleases", func(t *testing.T) {
+
+		input := `stages:
+  build:
+    image: golang
+
+releases:
+  development:
+    stages:
+      deploy:
+        image: extensions/gke:dev
+
+  # production needs approval
+  production:
+    stages:
+      deploy:
+        image: extensions/gke:stable
+
+# bots respond to events
+bots:
+  pr-bot:
+    stages:
+      welcome:
+        image: extensions/github-pr-bot:stable
+`
+		manifest, err := ReadEditableManifest(input)
+		assert.Nil(t, err)
+
+		// act
+		err = manifest.AddRelease(&EstafetteRelease{
+			Name: "staging",
+			Stages: []*EstafetteStage{
+				{
+					Name:           "deploy",
+					ContainerImage: "extensions/gke:beta",
+				},
+			},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, `stages:
+  build:
+    image: golang
+
+releases:
+  development:
+    stages:
+      deploy:
+        image: extensions/gke:dev
+
+  # production needs approval
+  production:
+    stages:
+      deploy:
+        image: extensions/gke:stable
+
+  staging:
+    stages:
+      deploy:
+        image: extensions/gke:beta
+
+# bots respond to events
+bots:
+  pr-bot:
+    stages:
+      welcome:
+        image: extensions/github-pr-bot:stable
+`, manifest.String())
+	})
+
+	t.Run("AddsReleasesSectionIfMissing", func(t *testing.T) {
+
+		input := `stages:
+  build:
+    image: golang
+`
+		manifest, err := ReadEditableManifest(input)
+		assert.Nil(t, err)
+
+		// act
+		err = manifest.AddRelease(&EstafetteRelease{
+			Name: "production",
+			Stages: []*EstafetteStage{
+				{
+					Name:           "deploy",
+					ContainerImage: "extensions/gke:stable",
+				},
+			},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, `stages:
+  build:
+    image: golang
+
+releases:
+  production:
+    stages:
+      deploy:
+        image: extensions/gke:stable
+`, manifest.String())
+
+		parsed, err := manifest.Manifest(nil, true)
+		assert.Nil(t, err)
+		assert.Equal(t, "production", parsed.Releases[0].Name)
+	})
+
+	t.Run("ReturnsErrorIfReleaseAlreadyExists", func(t *testing.T) {
+
+		manifest, err := ReadEditableManifest(`stages:
+  build:
+    image: golang
+releases:
+  production: {}
+`)
+		assert.Nil(t, err)
+
+		// act
+		err = manifest.AddRelease(&EstafetteRelease{Name: "production"})
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestEditableManifestRemoveTrigger(t *testing.T) {
+	t.Run("RemovesTriggerWithItsComment", func(t *testing.T) {
+
+		input := `triggers:
+# rebuild when the base image is built
+- pipeline:
+    name: github.com/estafette/estafette-ci-builder
+  builds:
+    branch: main
+# rebuild nightly
+- cron:
+    schedule: '0 2 * * *'
+
+stages:
+  build:
+    image: golang
+`
+		manifest, err := ReadEditableManifest(input)
+		assert.Nil(t, err)
+
+		// act
+		err = manifest.RemoveTrigger(0)
+
+		assert.Nil(t, err)
+		assert.Equal(t, `triggers:
+# rebuild nightly
+- cron:
+    schedule: '0 2 * * *'
+
+stages:
+  build:
+    image: golang
+`, manifest.String())
+	})
+
+	t.Run("RemovesTriggersSectionWhenRemovingTheLastTrigger", func(t *testing.T) {
+
+		input := `stages:
+  build:
+    image: golang
+
+releases:
+  production:
+    triggers:
+    - pipeline:
+        name: self
+    stages:
+      deploy:
+        image: extensions/gke:stable
+`
+		manifest, err := ReadEditableManifest(input)
+		assert.Nil(t, err)
+
+		// act
+		err = manifest.RemoveReleaseTrigger("production", 0)
+
+		assert.Nil(t, err)
+		assert.Equal(t, `stages:
+  build:
+    image: golang
+
+releases:
+  production:
+    stages:
+      deploy:
+        image: extensions/gke:stable
+`, manifest.String())
+	})
+
+	t.Run("ReturnsErrorIfIndexIsOutOfRange", func(t *testing.T) {
+
+		manifest, err := ReadEditableManifest(`triggers:
+- cron:
+    schedule: '0 2 * * *'
+stages:
+  build:
+    image: golang
+`)
+		assert.Nil(t, err)
+
+		// act
+		err = manifest.RemoveTrigger(1)
+
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,182 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// EnvVarSource tells where the value of an env var in an EffectiveEnv comes from
+type EnvVarSource string
+
+const (
+	// EnvVarSourceLabel is for the ESTAFETTE_LABEL_<NAME> env vars set from the labels of the manifest
+	EnvVarSourceLabel EnvVarSource = "label"
+	// EnvVarSourceVersion is for the ESTAFETTE_BUILD_VERSION* and ESTAFETTE_GIT_* env vars set from the version params
+	EnvVarSourceVersion EnvVarSource = "version"
+	// EnvVarSourceGlobal is for the env vars in the env section of the manifest
+	EnvVarSourceGlobal EnvVarSource = "global"
+	// EnvVarSourceRelease is for the env vars of the release the stage or service is part of
+	EnvVarSourceRelease EnvVarSource = "release"
+	// EnvVarSourceBot is for the env vars of the bot the stage or service is part of
+	EnvVarSourceBot EnvVarSource = "bot"
+	// EnvVarSourceStage is for the env vars of the stage
+	EnvVarSourceStage EnvVarSource = "stage"
+	// EnvVarSourceService is for the env vars of the service
+	EnvVarSourceService EnvVarSource = "service"
+)
+
+// EffectiveEnv holds the env vars a stage or service runs with. Global env vars are overridden by the env vars of the release or bot, which
+// are overridden by the env vars of the stage or service; the ESTAFETTE_* env vars set from labels and version params can't be overridden
+type EffectiveEnv struct {
+	Vars    map[string]string
+	Sources map[string]EnvVarSource
+	// Warnings describes env vars that are ignored because another one with the same name takes precedence unexpectedly
+	Warnings []string
+
+	labels map[string]string
+}
+
+var envVarInvalidCharRegex = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// EffectiveEnv returns the env vars stage runs with for the build with params
+func (c *EstafetteManifest) EffectiveEnv(stage *EstafetteStage, params EstafetteVersionParams) *EffectiveEnv {
+
+	env := c.estafetteEnv(params)
+	env.set(c.GlobalEnvVars, EnvVarSourceGlobal, "the global env")
+	c.setParentEnv(env, func(s *EstafetteStage) bool {
+		return s == stage
+	})
+	env.set(stage.EnvVars, EnvVarSourceStage, fmt.Sprintf("the env of stage %v", stage.Name))
+
+	return env
+}
+
+// EffectiveServiceEnv returns the env vars service runs with for the build with params; services don't get the env vars of their stage
+func (c *EstafetteManifest) EffectiveServiceEnv(service *EstafetteService, params EstafetteVersionParams) *EffectiveEnv {
+
+	env := c.estafetteEnv(params)
+	env.set(c.GlobalEnvVars, EnvVarSourceGlobal, "the global env")
+	c.setParentEnv(env, func(s *EstafetteStage) bool {
+		for _, svc := range s.Services {
+			if svc == service {
+				return true
+			}
+		}
+		return false
+	})
+	env.set(service.EnvVars, EnvVarSourceService, fmt.Sprintf("the env of service %v", service.Name))
+
+	return env
+}
+
+// LabelEnvVarName returns the name of the env var holding the value of a label, like ESTAFETTE_LABEL_APP_GROUP for app-group
+func LabelEnvVarName(label string) string {
+	return "ESTAFETTE_LABEL_" + strings.Trim(envVarInvalidCharRegex.ReplaceAllString(strings.ToUpper(label), "_"), "_")
+}
+
+// estafetteEnv returns the env vars set from labels and version params
+func (c *EstafetteManifest) estafetteEnv(params EstafetteVersionParams) *EffectiveEnv {
+
+	env := &EffectiveEnv{
+		Vars:    map[string]string{},
+		Sources: map[string]EnvVarSource{},
+		labels:  map[string]string{},
+	}
+
+	// labels are sorted, so if two of them end up with the same env var name the result is the same every time
+	for _, label := range sortedKeys(c.Labels) {
+		name := LabelEnvVarName(label)
+		if other, ok := env.labels[name]; ok {
+			env.Warnings = append(env.Warnings, fmt.Sprintf("Labels %v and %v both set env var %v, the value of label %v is used", other, label, name, label))
+		}
+		env.labels[name] = label
+		env.Vars[name] = c.Labels[label]
+		env.Sources[name] = EnvVarSourceLabel
+	}
+
+	version := map[string]string{
+		"ESTAFETTE_BUILD_VERSION": c.Version.Version(params),
+		"ESTAFETTE_GIT_BRANCH":    params.Branch,
+		"ESTAFETTE_GIT_REVISION":  params.Revision,
+	}
+	if c.Version.SemVer != nil {
+		version["ESTAFETTE_BUILD_VERSION_MAJOR"] = fmt.Sprint(c.Version.SemVer.Major)
+		version["ESTAFETTE_BUILD_VERSION_MINOR"] = fmt.Sprint(c.Version.SemVer.Minor)
+		version["ESTAFETTE_BUILD_VERSION_PATCH"] = c.Version.SemVer.GetPatch(params)
+		if !c.Version.SemVer.ReleaseBranch.Contains(params.Branch) {
+			version["ESTAFETTE_BUILD_VERSION_LABEL"] = c.Version.SemVer.GetLabel(params)
+		}
+	}
+	for name, value := range version {
+		if value != "" {
+			env.Vars[name] = value
+			env.Sources[name] = EnvVarSourceVersion
+		}
+	}
+
+	return env
+}
+
+// setParentEnv adds the env vars of the release or bot with a stage matching fn
+func (c *EstafetteManifest) setParentEnv(env *EffectiveEnv, fn func(stage *EstafetteStage) bool) {
+	for _, r := range c.Releases {
+		if containsStage(r.Stages, fn) {
+			env.set(r.EnvVars, EnvVarSourceRelease, fmt.Sprintf("the env of release %v", r.Name))
+			return
+		}
+	}
+	for _, b := range c.Bots {
+		if containsStage(b.Stages, fn) {
+			env.set(b.EnvVars, EnvVarSourceBot, fmt.Sprintf("the env of bot %v", b.Name))
+			return
+		}
+	}
+}
+
+// containsStage returns whether any of stages or their parallel stages matches fn
+func containsStage(stages []*EstafetteStage, fn func(stage *EstafetteStage) bool) (found bool) {
+	walkStages("", stages, func(path string, stage *EstafetteStage) {
+		if fn(stage) {
+			found = true
+		}
+	})
+	return found
+}
+
+// mergeEnvVars combines base env vars with the ones in override, which take precedence; the result is always a new map, so later changes
+// to it don't end up in the template or stage it came from
+func mergeEnvVars(base, override map[string]string) map[string]string {
+
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+
+	merged := map[string]string{}
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// set adds vars from source, described as description in warnings; they override everything except the ESTAFETTE_* env vars set from
+// labels and version params
+func (env *EffectiveEnv) set(vars map[string]string, source EnvVarSource, description string) {
+
+	for _, name := range sortedKeys(vars) {
+		switch env.Sources[name] {
+		case EnvVarSourceLabel:
+			env.Warnings = append(env.Warnings, fmt.Sprintf("Env var %v in %v is ignored, it's set from label %v", name, description, env.labels[name]))
+			continue
+		case EnvVarSourceVersion:
+			env.Warnings = append(env.Warnings, fmt.Sprintf("Env var %v in %v is ignored, it's set from the version params", name, description))
+			continue
+		}
+		env.Vars[name] = vars[name]
+		env.Sources[name] = source
+	}
+}
@@ -0,0 +1,244 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const envManifest = `
+labels:
+  app: gocd
+  app-group: ci
+  team: estafette
+version:
+  semver:
+    major: 1
+    minor: 2
+env:
+  REGION: europe-west1
+  ZONE: europe-west1-b
+  ESTAFETTE_LABEL_TEAM: other
+stages:
+  build:
+    image: golang:1.17-alpine
+    env:
+      ZONE: europe-west1-c
+      ESTAFETTE_BUILD_VERSION: 0.0.0
+    services:
+    - name: database
+      image: postgres:14
+      env:
+        POSTGRES_DB: test`
+
+func TestEffectiveEnv(t *testing.T) {
+
+	params := EstafetteVersionParams{AutoIncrement: 15, Branch: "feature-x", Revision: "4fa1b3c"}
+
+	t.Run("ReturnsEnvVarsWithTheirSources", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, envManifest, true)
+		assert.Nil(t, err)
+
+		// act
+		env := manifest.EffectiveEnv(manifest.Stages[0], params)
+
+		assert.Equal(t, map[string]string{
+			"ESTAFETTE_LABEL_APP":           "gocd",
+			"ESTAFETTE_LABEL_APP_GROUP":     "ci",
+			"ESTAFETTE_LABEL_TEAM":          "estafette",
+			"ESTAFETTE_BUILD_VERSION":       "1.2.15-feature-x",
+			"ESTAFETTE_BUILD_VERSION_MAJOR": "1",
+			"ESTAFETTE_BUILD_VERSION_MINOR": "2",
+			"ESTAFETTE_BUILD_VERSION_PATCH": "15",
+			"ESTAFETTE_BUILD_VERSION_LABEL": "feature-x",
+			"ESTAFETTE_GIT_BRANCH":          "feature-x",
+			"ESTAFETTE_GIT_REVISION":        "4fa1b3c",
+			"REGION":                        "europe-west1",
+			"ZONE":                          "europe-west1-c",
+		}, env.Vars)
+		assert.Equal(t, EnvVarSourceLabel, env.Sources["ESTAFETTE_LABEL_APP_GROUP"])
+		assert.Equal(t, EnvVarSourceVersion, env.Sources["ESTAFETTE_BUILD_VERSION"])
+		assert.Equal(t, EnvVarSourceGlobal, env.Sources["REGION"])
+		assert.Equal(t, EnvVarSourceStage, env.Sources["ZONE"])
+	})
+
+	t.Run("ReturnsWarningsForEnvVarsSetFromLabelsAndVersionParams", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, envManifest, true)
+		assert.Nil(t, err)
+
+		// act
+		env := manifest.EffectiveEnv(manifest.Stages[0], params)
+
+		assert.Equal(t, []string{
+			"Env var ESTAFETTE_LABEL_TEAM in the global env is ignored, it's set from label team",
+			"Env var ESTAFETTE_BUILD_VERSION in the env of stage build is ignored, it's set from the version params",
+		}, env.Warnings)
+	})
+
+	t.Run("ReturnsWarningForLabelsWithTheSameEnvVarName", func(t *testing.T) {
+
+		manifest := &EstafetteManifest{Labels: map[string]string{"app-group": "ci", "app_group": "cd"}}
+
+		// act
+		env := manifest.EffectiveEnv(&EstafetteStage{Name: "build"}, params)
+
+		assert.Equal(t, "cd", env.Vars["ESTAFETTE_LABEL_APP_GROUP"])
+		assert.Equal(t, []string{"Labels app-group and app_group both set env var ESTAFETTE_LABEL_APP_GROUP, the value of label app_group is used"}, env.Warnings)
+	})
+
+	t.Run("LeavesOutVersionLabelOnReleaseBranch", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, envManifest, true)
+		assert.Nil(t, err)
+
+		// act
+		env := manifest.EffectiveEnv(manifest.Stages[0], EstafetteVersionParams{AutoIncrement: 15, Branch: "main"})
+
+		assert.Equal(t, "1.2.15", env.Vars["ESTAFETTE_BUILD_VERSION"])
+		_, hasLabel := env.Vars["ESTAFETTE_BUILD_VERSION_LABEL"]
+		assert.False(t, hasLabel)
+	})
+}
+
+const envReleaseManifest = `
+env:
+  REGION: europe-west1
+  ZONE: europe-west1-b
+  LOG_LEVEL: info
+stages:
+  build:
+    image: golang:1.17-alpine
+releases:
+  production:
+    env:
+      ZONE: europe-west1-c
+      LOG_LEVEL: warn
+    stages:
+      deploy:
+        image: extensions/gke:stable
+        env:
+          LOG_LEVEL: debug
+        services:
+        - name: proxy
+          image: envoyproxy/envoy:v1.20
+bots:
+  pr-bot:
+    env:
+      ZONE: europe-west1-d
+    stages:
+      welcome:
+        parallelStages:
+          greet:
+            image: extensions/greeter:stable`
+
+func TestEffectiveEnvForReleasesAndBots(t *testing.T) {
+	t.Run("ReturnsGlobalOverriddenByReleaseOverriddenByStage", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, envReleaseManifest, true)
+		assert.Nil(t, err)
+
+		// act
+		env := manifest.EffectiveEnv(manifest.Releases[0].Stages[0], EstafetteVersionParams{})
+
+		assert.Equal(t, "europe-west1", env.Vars["REGION"])
+		assert.Equal(t, EnvVarSourceGlobal, env.Sources["REGION"])
+		assert.Equal(t, "europe-west1-c", env.Vars["ZONE"])
+		assert.Equal(t, EnvVarSourceRelease, env.Sources["ZONE"])
+		assert.Equal(t, "debug", env.Vars["LOG_LEVEL"])
+		assert.Equal(t, EnvVarSourceStage, env.Sources["LOG_LEVEL"])
+	})
+
+	t.Run("ReturnsReleaseEnvVarsForServicesOfReleaseStages", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, envReleaseManifest, true)
+		assert.Nil(t, err)
+
+		// act
+		env := manifest.EffectiveServiceEnv(manifest.Releases[0].Stages[0].Services[0], EstafetteVersionParams{})
+
+		assert.Equal(t, "europe-west1-c", env.Vars["ZONE"])
+		assert.Equal(t, "warn", env.Vars["LOG_LEVEL"])
+	})
+
+	t.Run("ReturnsBotEnvVarsForParallelBotStages", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, envReleaseManifest, true)
+		assert.Nil(t, err)
+
+		// act
+		env := manifest.EffectiveEnv(manifest.Bots[0].Stages[0].ParallelStages[0], EstafetteVersionParams{})
+
+		assert.Equal(t, "europe-west1-d", env.Vars["ZONE"])
+		assert.Equal(t, EnvVarSourceBot, env.Sources["ZONE"])
+	})
+
+	t.Run("DoesNotReturnReleaseEnvVarsForBuildStages", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, envReleaseManifest, true)
+		assert.Nil(t, err)
+
+		// act
+		env := manifest.EffectiveEnv(manifest.Stages[0], EstafetteVersionParams{})
+
+		assert.Equal(t, "europe-west1-b", env.Vars["ZONE"])
+		assert.Equal(t, "info", env.Vars["LOG_LEVEL"])
+	})
+}
+
+func TestEffectiveServiceEnv(t *testing.T) {
+	t.Run("ReturnsGlobalAndServiceEnvVarsWithoutTheStageEnvVars", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, envManifest, true)
+		assert.Nil(t, err)
+
+		// act
+		env := manifest.EffectiveServiceEnv(manifest.Stages[0].Services[0], EstafetteVersionParams{AutoIncrement: 15, Branch: "main"})
+
+		assert.Equal(t, "europe-west1-b", env.Vars["ZONE"])
+		assert.Equal(t, EnvVarSourceGlobal, env.Sources["ZONE"])
+		assert.Equal(t, "test", env.Vars["POSTGRES_DB"])
+		assert.Equal(t, EnvVarSourceService, env.Sources["POSTGRES_DB"])
+	})
+}
+
+func TestMergeEnvVars(t *testing.T) {
+	t.Run("ReturnsOverrideEnvVarsTakingPrecedenceOverBase", func(t *testing.T) {
+
+		// act
+		merged := mergeEnvVars(map[string]string{"ZONE": "europe-west1-b", "REGION": "europe-west1"}, map[string]string{"ZONE": "europe-west1-c"})
+
+		assert.Equal(t, map[string]string{"ZONE": "europe-west1-c", "REGION": "europe-west1"}, merged)
+	})
+
+	t.Run("ReturnsCopyOfOverrideIfBaseIsEmpty", func(t *testing.T) {
+
+		override := map[string]string{"ZONE": "europe-west1-c"}
+
+		// act
+		merged := mergeEnvVars(nil, override)
+
+		merged["MATRIX_GO"] = "1.17"
+		assert.Equal(t, map[string]string{"ZONE": "europe-west1-c"}, override)
+	})
+
+	t.Run("ReturnsCopyOfBaseIfOverrideIsEmpty", func(t *testing.T) {
+
+		base := map[string]string{"ZONE": "europe-west1-b"}
+
+		// act
+		merged := mergeEnvVars(base, nil)
+
+		merged["MATRIX_GO"] = "1.17"
+		assert.Equal(t, map[string]string{"ZONE": "europe-west1-b"}, base)
+	})
+
+	t.Run("ReturnsNilIfBothAreEmpty", func(t *testing.T) {
+
+		// act
+		merged := mergeEnvVars(map[string]string{}, nil)
+
+		assert.Nil(t, merged)
+	})
+}
@@ -43,9 +43,15 @@ type EstafetteCronEvent struct {
 	Time time.Time `yaml:"time,omitempty" json:"time,omitempty"`
 }
 
-// EstafetteManualEvent fires when a user manually triggers a build or release
+// EstafetteManualEvent fires when a user manually triggers a build, release or bot run; target is the name of the release or bot and
+// version, action and branch override the ones of the trigger's action
 type EstafetteManualEvent struct {
-	UserID string `yaml:"userID,omitempty" json:"userID,omitempty"`
+	UserID     string      `yaml:"userID,omitempty" json:"userID,omitempty"`
+	TargetType TriggerType `yaml:"targetType,omitempty" json:"targetType,omitempty"`
+	Target     string      `yaml:"target,omitempty" json:"target,omitempty"`
+	Version    string      `yaml:"version,omitempty" json:"version,omitempty"`
+	Action     string      `yaml:"action,omitempty" json:"action,omitempty"`
+	Branch     string      `yaml:"branch,omitempty" json:"branch,omitempty"`
 }
 
 // EstafettePubSubEvent fires when a subscribed pubsub topic receives an event
@@ -0,0 +1,288 @@
+package manifest
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// IncludeResolver reads the files listed in the include section of a manifest, for instance from disk or from a git repository
+type IncludeResolver interface {
+	// ResolveInclude returns the name and contents of include, which is relative to the file named from that includes it
+	ResolveInclude(from, include string) (name string, data []byte, err error)
+}
+
+// NewFSIncludeResolver returns an IncludeResolver that reads includes from a file system
+func NewFSIncludeResolver(fsys fs.FS) IncludeResolver {
+	return &fsIncludeResolver{fsys: fsys}
+}
+
+type fsIncludeResolver struct {
+	fsys fs.FS
+}
+
+func (r *fsIncludeResolver) ResolveInclude(from, include string) (name string, data []byte, err error) {
+	if path.IsAbs(include) {
+		return "", nil, fmt.Errorf("Include %v should be relative to the manifest including it", include)
+	}
+
+	name = path.Join(path.Dir(from), include)
+	if !fs.ValidPath(name) {
+		return "", nil, fmt.Errorf("Include %v points outside of the file system", include)
+	}
+
+	data, err = fs.ReadFile(r.fsys, name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return name, data, nil
+}
+
+// nonIncludableSections lists the top-level sections that can only be defined in the main manifest
+var nonIncludableSections = map[string]bool{
+	"archived":  true,
+	"builder":   true,
+	"labels":    true,
+	"version":   true,
+	"env":       true,
+	"pipelines": true,
+	"triggers":  true,
+}
+
+// includeResolution keeps track of the files included so far, to detect cycles and names defined in more than one file
+type includeResolution struct {
+	resolver  IncludeResolver
+	unmarshal func(data []byte, out interface{}) error
+
+	chain            []string
+	data             map[string][]byte
+	stageTemplates   map[string]string
+	stages           map[string]string
+	releases         map[string]string
+	releaseTemplates map[string]string
+	botTemplates     map[string]string
+	bots             map[string]string
+}
+
+// resolveIncludes merges the stage templates, stages, releases, release templates, bots and bot templates of all included files into the manifest, in the order they're included;
+// afterwards the include section is cleared, since the manifest holds everything it referred to; the returned resolution knows which file
+// defined what, and is nil if there's nothing to include
+func (c *EstafetteManifest) resolveIncludes(data []byte, file string, resolver IncludeResolver, unmarshal func(data []byte, out interface{}) error) (*includeResolution, error) {
+
+	if len(c.Includes) == 0 {
+		return nil, nil
+	}
+	if resolver == nil {
+		return nil, withPositions(&ValidationError{Path: "include", Message: "cannot be resolved without a file system to read the included files from"}, data, file)
+	}
+
+	r := &includeResolution{
+		resolver:         resolver,
+		unmarshal:        unmarshal,
+		data:             map[string][]byte{},
+		stageTemplates:   map[string]string{},
+		stages:           map[string]string{},
+		releases:         map[string]string{},
+		releaseTemplates: map[string]string{},
+		botTemplates:     map[string]string{},
+		bots:             map[string]string{},
+	}
+
+	// the including manifest is the first to define its names, so move its own sections out of the way before merging everything back in order
+	source := &EstafetteManifest{
+		Includes:         c.Includes,
+		StageTemplates:   c.StageTemplates,
+		Stages:           c.Stages,
+		Releases:         c.Releases,
+		ReleaseTemplates: c.ReleaseTemplates,
+		BotTemplates:     c.BotTemplates,
+		Bots:             c.Bots,
+	}
+	c.Includes, c.StageTemplates, c.Stages, c.Releases, c.ReleaseTemplates, c.BotTemplates, c.Bots = nil, nil, nil, nil, nil, nil, nil
+
+	if err := r.merge(c, source, file, data); err != nil {
+		return nil, err
+	}
+
+	// releases and bots can use templates from other files, which weren't known yet while unmarshalling
+	releaseTemplates := map[string]*EstafetteReleaseTemplate{}
+	for _, rt := range c.ReleaseTemplates {
+		releaseTemplates[rt.Name] = rt
+	}
+	for _, release := range c.Releases {
+		release.InitFromTemplate(releaseTemplates)
+	}
+	botTemplates := map[string]*EstafetteBotTemplate{}
+	for _, bt := range c.BotTemplates {
+		botTemplates[bt.Name] = bt
+	}
+	for _, bot := range c.Bots {
+		bot.InitFromTemplate(botTemplates)
+	}
+
+	// and the same goes for stages using stage templates
+	c.resolveStageTemplates()
+
+	return r, nil
+}
+
+func (r *includeResolution) merge(target, source *EstafetteManifest, file string, data []byte) error {
+
+	for _, f := range r.chain {
+		if f == file {
+			return fmt.Errorf("Include cycle detected: %v -> %v", strings.Join(r.chain, " -> "), file)
+		}
+	}
+	r.chain = append(r.chain, file)
+	defer func() { r.chain = r.chain[:len(r.chain)-1] }()
+	r.data[file] = data
+
+	var errs ValidationErrors
+	for _, stageTemplate := range source.StageTemplates {
+		if r.define(r.stageTemplates, "stageTemplates", stageTemplate.Name, file, &errs) {
+			target.StageTemplates = append(target.StageTemplates, stageTemplate)
+		}
+	}
+	for _, stage := range source.Stages {
+		if r.define(r.stages, "stages", stage.Name, file, &errs) {
+			target.Stages = append(target.Stages, stage)
+		}
+	}
+	for _, release := range source.Releases {
+		if r.define(r.releases, "releases", release.Name, file, &errs) {
+			target.Releases = append(target.Releases, release)
+		}
+	}
+	for _, releaseTemplate := range source.ReleaseTemplates {
+		if r.define(r.releaseTemplates, "releaseTemplates", releaseTemplate.Name, file, &errs) {
+			target.ReleaseTemplates = append(target.ReleaseTemplates, releaseTemplate)
+		}
+	}
+	for _, botTemplate := range source.BotTemplates {
+		if r.define(r.botTemplates, "botTemplates", botTemplate.Name, file, &errs) {
+			target.BotTemplates = append(target.BotTemplates, botTemplate)
+		}
+	}
+	for _, bot := range source.Bots {
+		if r.define(r.bots, "bots", bot.Name, file, &errs) {
+			target.Bots = append(target.Bots, bot)
+		}
+	}
+	if err := errs.errorOrNil(); err != nil {
+		return withPositions(err, data, file)
+	}
+
+	for i, include := range source.Includes {
+		name, includedData, err := r.resolver.ResolveInclude(file, include)
+		if err != nil {
+			return withPositions(&ValidationError{Path: fmt.Sprintf("include[%v]", i), Message: err.Error()}, data, file)
+		}
+
+		var sections yaml.MapSlice
+		if err := yaml.Unmarshal(includedData, &sections); err != nil {
+			return withPositions(err, includedData, name)
+		}
+		for _, mi := range sections {
+			if key, ok := mi.Key.(string); ok && nonIncludableSections[key] {
+				return withPositions(&ValidationError{Path: key, Message: "can only be defined in the main manifest, included files can only define include, stageTemplates, stages, releases, releaseTemplates, bots and botTemplates"}, includedData, name)
+			}
+		}
+
+		var includedManifest EstafetteManifest
+		if err := r.unmarshal(includedData, &includedManifest); err != nil {
+			return withPositions(err, includedData, name)
+		}
+
+		if err := r.merge(target, &includedManifest, name, includedData); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// define registers a name as defined in file, adding an error if another file already defined it
+func (r *includeResolution) define(defined map[string]string, section, name, file string, errs *ValidationErrors) bool {
+	if definedIn, exists := defined[name]; exists {
+		if definedIn == "" {
+			definedIn = "the main manifest"
+		}
+		errs.addf(fmt.Sprintf("%v.%v", section, name), "is already defined in %v", definedIn)
+		return false
+	}
+	defined[name] = file
+	return true
+}
+
+// withPositions attaches positions to err like the package level withPositions does for file, except for errors about stage templates,
+// stages, releases, release templates, bots and bot templates defined in an included file, which get their position in that file
+func (r *includeResolution) withPositions(err error, data []byte, file string) error {
+
+	if r == nil {
+		return withPositions(err, data, file)
+	}
+
+	var errs ValidationErrors
+	switch e := err.(type) {
+	case ValidationErrors:
+		errs = e
+	case *ValidationError:
+		errs = ValidationErrors{e}
+	default:
+		return withPositions(err, data, file)
+	}
+
+	indexes := map[string]positionIndex{}
+	for _, e := range errs {
+		if e.Position != nil {
+			continue
+		}
+		definedIn, ok := r.definedIn(e.Path)
+		if !ok || definedIn == file {
+			continue
+		}
+		index, ok := indexes[definedIn]
+		if !ok {
+			index = newPositionIndex(r.data[definedIn], definedIn)
+			indexes[definedIn] = index
+		}
+		if position, ok := index.lookup(e.Path); ok {
+			e.Position = &position
+		} else {
+			e.Position = &Position{File: definedIn}
+		}
+	}
+
+	return withPositions(errs, data, file)
+}
+
+// definedIn returns the file that defined the stage template, stage, release, release template, bot or bot template path is about
+func (r *includeResolution) definedIn(path string) (string, bool) {
+
+	sections := map[string]map[string]string{
+		"stageTemplates":   r.stageTemplates,
+		"stages":           r.stages,
+		"releases":         r.releases,
+		"releaseTemplates": r.releaseTemplates,
+		"botTemplates":     r.botTemplates,
+		"bots":             r.bots,
+	}
+
+	for section, defined := range sections {
+		if !strings.HasPrefix(path, section+".") {
+			continue
+		}
+		rest := strings.TrimPrefix(path, section+".")
+		for name, file := range defined {
+			if rest == name || strings.HasPrefix(rest, name+".") || strings.HasPrefix(rest, name+"[") {
+				return file, true
+			}
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,256 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadManifestWithIncludes(t *testing.T) {
+	t.Run("MergesIncludedFilesRelativeToTheManifest", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifestFromFile(GetDefaultManifestPreferences(), "test-manifest-with-includes.yaml", true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(manifest.Includes))
+		assert.Equal(t, 1, len(manifest.Stages))
+		if assert.Equal(t, 1, len(manifest.ReleaseTemplates)) {
+			assert.Equal(t, "gke", manifest.ReleaseTemplates[0].Name)
+		}
+		if assert.Equal(t, 2, len(manifest.Releases)) {
+			assert.Equal(t, "staging", manifest.Releases[0].Name)
+			assert.Equal(t, "production", manifest.Releases[1].Name)
+			// the template is defined in another file than the releases using it
+			if assert.Equal(t, 1, len(manifest.Releases[1].Stages)) {
+				assert.Equal(t, "extensions/gke:stable", manifest.Releases[1].Stages[0].ContainerImage)
+			}
+			assert.True(t, *manifest.Releases[1].CloneRepository)
+		}
+	})
+
+	t.Run("MergesStagesInTheOrderTheyAreIncluded", func(t *testing.T) {
+
+		fsys := fstest.MapFS{
+			".estafette.yaml": &fstest.MapFile{Data: []byte(`
+include:
+- .estafette/test.yaml
+- .estafette/publish.yaml
+stages:
+  build:
+    image: golang:1.17-alpine`)},
+			".estafette/test.yaml": &fstest.MapFile{Data: []byte(`
+stages:
+  test:
+    image: golang:1.17-alpine`)},
+			".estafette/publish.yaml": &fstest.MapFile{Data: []byte(`
+stages:
+  bake:
+    image: extensions/docker:stable
+  push:
+    image: extensions/docker:stable`)},
+		}
+
+		// act
+		manifest, err := ReadManifestWithOptions(nil, ReadOptions{FS: fsys, File: ".estafette.yaml"})
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 4, len(manifest.Stages)) {
+			assert.Equal(t, "build", manifest.Stages[0].Name)
+			assert.Equal(t, "test", manifest.Stages[1].Name)
+			assert.Equal(t, "bake", manifest.Stages[2].Name)
+			assert.Equal(t, "push", manifest.Stages[3].Name)
+		}
+	})
+
+	t.Run("UsesIncludeResolverWhenSet", func(t *testing.T) {
+
+		resolver := NewFSIncludeResolver(fstest.MapFS{
+			"bots.yaml": &fstest.MapFile{Data: []byte(`
+bots:
+  stale-prs:
+    stages:
+      close:
+        image: extensions/github-bot:stable`)},
+		})
+
+		// act
+		manifest, err := ReadManifestWithOptions(strings.NewReader(`
+include:
+- bots.yaml
+stages:
+  build:
+    image: golang:1.17-alpine`), ReadOptions{IncludeResolver: resolver})
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(manifest.Bots)) {
+			assert.Equal(t, "stale-prs", manifest.Bots[0].Name)
+		}
+	})
+
+	t.Run("ReturnsErrorForIncludeCycle", func(t *testing.T) {
+
+		fsys := fstest.MapFS{
+			".estafette.yaml": &fstest.MapFile{Data: []byte(`
+include:
+- a.yaml
+stages:
+  build:
+    image: golang:1.17-alpine`)},
+			"a.yaml": &fstest.MapFile{Data: []byte(`
+include:
+- b.yaml`)},
+			"b.yaml": &fstest.MapFile{Data: []byte(`
+include:
+- a.yaml`)},
+		}
+
+		// act
+		_, err := ReadManifestWithOptions(nil, ReadOptions{FS: fsys, File: ".estafette.yaml"})
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Include cycle detected: .estafette.yaml -> a.yaml -> b.yaml -> a.yaml", err.Error())
+		}
+	})
+
+	t.Run("ReturnsErrorForNamesDefinedInMoreThanOneFile", func(t *testing.T) {
+
+		fsys := fstest.MapFS{
+			".estafette.yaml": &fstest.MapFile{Data: []byte(`
+include:
+- stages.yaml
+stages:
+  build:
+    image: golang:1.17-alpine`)},
+			"stages.yaml": &fstest.MapFile{Data: []byte(`
+stages:
+  build:
+    image: golang:1.18-alpine`)},
+		}
+
+		// act
+		_, err := ReadManifestWithOptions(nil, ReadOptions{FS: fsys, File: ".estafette.yaml"})
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "stages.yaml:3:3: stages.build: is already defined in .estafette.yaml", err.Error())
+		}
+	})
+
+	t.Run("ReturnsErrorForSectionsOnlyAllowedInTheMainManifest", func(t *testing.T) {
+
+		fsys := fstest.MapFS{
+			".estafette.yaml": &fstest.MapFile{Data: []byte(`
+include:
+- labels.yaml
+stages:
+  build:
+    image: golang:1.17-alpine`)},
+			"labels.yaml": &fstest.MapFile{Data: []byte(`
+labels:
+  app: estafette-ci-manifest`)},
+		}
+
+		// act
+		_, err := ReadManifestWithOptions(nil, ReadOptions{FS: fsys, File: ".estafette.yaml"})
+
+		if assert.NotNil(t, err) {
+			assert.True(t, strings.HasPrefix(err.Error(), "labels.yaml:2:1: labels: can only be defined in the main manifest"), err.Error())
+		}
+	})
+
+	t.Run("ReturnsErrorForMissingIncludedFile", func(t *testing.T) {
+
+		fsys := fstest.MapFS{
+			".estafette.yaml": &fstest.MapFile{Data: []byte(`
+include:
+- missing.yaml
+stages:
+  build:
+    image: golang:1.17-alpine`)},
+		}
+
+		// act
+		_, err := ReadManifestWithOptions(nil, ReadOptions{FS: fsys, File: ".estafette.yaml"})
+
+		if assert.NotNil(t, err) {
+			assert.True(t, strings.HasPrefix(err.Error(), ".estafette.yaml:3:3: include[0]: open missing.yaml"), err.Error())
+		}
+	})
+
+	t.Run("ReturnsPositionInIncludedFileForErrorsInIncludedStages", func(t *testing.T) {
+
+		fsys := fstest.MapFS{
+			".estafette.yaml": &fstest.MapFile{Data: []byte(`
+include:
+- stages.yaml
+stages:
+  build:
+    image: golang:1.17-alpine`)},
+			"stages.yaml": &fstest.MapFile{Data: []byte(`
+stages:
+  test:
+    image: golang:1.17-alpine
+    when: branch ==`)},
+		}
+
+		// act
+		_, err := ReadManifestWithOptions(nil, ReadOptions{FS: fsys, File: ".estafette.yaml"})
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "stages.test.when", errs[0].Path)
+				assert.Equal(t, &Position{File: "stages.yaml", Line: 5, Column: 5}, errs[0].Position)
+			}
+		}
+	})
+
+	t.Run("ResolvesIncludesRelativeToTheManifestDirectoryHoweverItsPathIsPassed", func(t *testing.T) {
+
+		dir := t.TempDir()
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, "app"), 0755))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "app", ".estafette.yaml"), []byte(`
+include:
+- ../shared.yaml
+stages:
+  build:
+    image: golang:1.17-alpine`), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "shared.yaml"), []byte(`
+stages:
+  test:
+    image: golang:1.17-alpine`), 0644))
+
+		wd, err := os.Getwd()
+		assert.Nil(t, err)
+		relative, err := filepath.Rel(wd, filepath.Join(dir, "app", ".estafette.yaml"))
+		assert.Nil(t, err)
+
+		for _, manifestPath := range []string{filepath.Join(dir, "app", ".estafette.yaml"), relative} {
+			// act
+			_, err := ReadManifestFromFile(GetDefaultManifestPreferences(), manifestPath, true)
+
+			if assert.NotNil(t, err, manifestPath) {
+				errs := err.(ValidationErrors)
+				assert.Equal(t, "Include ../shared.yaml points outside of the file system", errs[0].Message)
+				assert.Equal(t, filepath.Join(filepath.Dir(manifestPath), ".estafette.yaml"), errs[0].Position.File)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForIncludesWithoutFileSystem", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+include:
+- stages.yaml
+stages:
+  build:
+    image: golang:1.17-alpine`, true)
+
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,118 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// jsonPath is a parsed JSONPath expression supporting the subset of the syntax needed to pick values from a message, like
+// $.action, $.target.tags[0], $['image-name'] or $.items[*].name
+type jsonPath []jsonPathSegment
+
+// jsonPathSegment selects the child with a key, the element at an index or - as a wildcard - all children of a value
+type jsonPathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+var jsonPathSegmentRegex = regexp.MustCompile(`^(?:\.([A-Za-z0-9_-]+)|\.\*|\[\*\]|\[([0-9]+)\]|\['([^']*)'\]|\["([^"]*)"\])`)
+
+// parseJSONPath parses a JSONPath expression starting at the root $
+func parseJSONPath(path string) (jsonPath, error) {
+
+	if len(path) == 0 || path[0] != '$' {
+		return nil, fmt.Errorf("JSONPath %v is not valid, it should start with $ like $.target.tag", path)
+	}
+
+	segments := jsonPath{}
+	rest := path[1:]
+	for rest != "" {
+		matches := jsonPathSegmentRegex.FindStringSubmatch(rest)
+		if matches == nil {
+			return nil, fmt.Errorf("JSONPath %v is not valid at %v, use .key, ['key'], [0] or [*]", path, rest)
+		}
+
+		switch {
+		case matches[1] != "":
+			segments = append(segments, jsonPathSegment{key: matches[1]})
+		case matches[2] != "":
+			index, err := strconv.Atoi(matches[2])
+			if err != nil {
+				return nil, fmt.Errorf("JSONPath %v is not valid at %v: %v", path, rest, err)
+			}
+			segments = append(segments, jsonPathSegment{index: index, isIndex: true})
+		case matches[0] == ".*" || matches[0] == "[*]":
+			segments = append(segments, jsonPathSegment{wildcard: true})
+		case matches[0][1] == '\'':
+			segments = append(segments, jsonPathSegment{key: matches[3]})
+		default:
+			segments = append(segments, jsonPathSegment{key: matches[4]})
+		}
+
+		rest = rest[len(matches[0]):]
+	}
+
+	return segments, nil
+}
+
+// selectFrom returns the values the path selects from data, which holds json
+func (p jsonPath) selectFrom(data []byte) ([]interface{}, error) {
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// keep numbers as they are written instead of converting them to floats
+	decoder.UseNumber()
+
+	var root interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return nil, err
+	}
+
+	values := []interface{}{root}
+	for _, segment := range p {
+		selected := []interface{}{}
+		for _, value := range values {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				if segment.wildcard {
+					keys := make([]string, 0, len(v))
+					for key := range v {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						selected = append(selected, v[key])
+					}
+				} else if child, ok := v[segment.key]; ok && !segment.isIndex {
+					selected = append(selected, child)
+				}
+			case []interface{}:
+				if segment.wildcard {
+					selected = append(selected, v...)
+				} else if segment.isIndex && segment.index < len(v) {
+					selected = append(selected, v[segment.index])
+				}
+			}
+		}
+		values = selected
+	}
+
+	return values, nil
+}
+
+// jsonPathValueString returns a value selected by a JSONPath as string to match against; strings are used as is, other values as json
+func jsonPathValueString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
@@ -0,0 +1,120 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	t.Run("ReturnsSegmentsForKeysIndexesAndWildcards", func(t *testing.T) {
+
+		// act
+		path, err := parseJSONPath(`$.target.tags[0]['image-name']["digest"][*].*`)
+
+		assert.Nil(t, err)
+		assert.Equal(t, jsonPath{
+			{key: "target"},
+			{key: "tags"},
+			{index: 0, isIndex: true},
+			{key: "image-name"},
+			{key: "digest"},
+			{wildcard: true},
+			{wildcard: true},
+		}, path)
+	})
+
+	t.Run("ReturnsEmptyPathForRoot", func(t *testing.T) {
+
+		// act
+		path, err := parseJSONPath("$")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(path))
+	})
+
+	t.Run("ReturnsErrorIfPathDoesNotStartWithRoot", func(t *testing.T) {
+
+		// act
+		_, err := parseJSONPath("target.tag")
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "JSONPath target.tag is not valid, it should start with $ like $.target.tag", err.Error())
+		}
+	})
+
+	t.Run("ReturnsErrorForInvalidSegment", func(t *testing.T) {
+
+		// act
+		_, err := parseJSONPath("$.target[tag]")
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "JSONPath $.target[tag] is not valid at [tag], use .key, ['key'], [0] or [*]", err.Error())
+		}
+	})
+}
+
+func TestJSONPathSelectFrom(t *testing.T) {
+
+	data := []byte(`{"action":"INSERT","digest":"sha256:abc","tag":"gcr.io/my-project/my-image:1.2.3","size":1024,"labels":[{"name":"a"},{"name":"b"}]}`)
+
+	t.Run("ReturnsValueForKey", func(t *testing.T) {
+
+		path, _ := parseJSONPath("$.action")
+
+		// act
+		values, err := path.selectFrom(data)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{"INSERT"}, values)
+	})
+
+	t.Run("ReturnsAllValuesForWildcard", func(t *testing.T) {
+
+		path, _ := parseJSONPath("$.labels[*].name")
+
+		// act
+		values, err := path.selectFrom(data)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{"a", "b"}, values)
+	})
+
+	t.Run("ReturnsNoValuesForMissingKeyOrIndex", func(t *testing.T) {
+
+		path, _ := parseJSONPath("$.labels[5].name")
+
+		// act
+		values, err := path.selectFrom(data)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(values))
+	})
+
+	t.Run("ReturnsErrorForInvalidJSON", func(t *testing.T) {
+
+		path, _ := parseJSONPath("$.action")
+
+		// act
+		_, err := path.selectFrom([]byte("not json"))
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestJSONPathValueString(t *testing.T) {
+	t.Run("ReturnsStringsAsIsAndOtherValuesAsJSON", func(t *testing.T) {
+
+		path, _ := parseJSONPath("$[*]")
+		values, err := path.selectFrom([]byte(`["text",1024,true,null,{"a":1}]`))
+		assert.Nil(t, err)
+
+		results := []string{}
+		for _, v := range values {
+			// act
+			results = append(results, jsonPathValueString(v))
+		}
+
+		assert.Equal(t, []string{"text", "1024", "true", "null", `{"a":1}`}, results)
+	})
+}
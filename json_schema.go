@@ -0,0 +1,260 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// jsonSchemaMappingKeyedFields lists the fields that are lists in Go, but mappings keyed by name in the manifest
+var jsonSchemaMappingKeyedFields = map[string]string{
+	"EstafetteManifest.StageTemplates":   "stageTemplates",
+	"EstafetteManifest.Stages":           "stages",
+	"EstafetteManifest.Releases":         "releases",
+	"EstafetteManifest.ReleaseTemplates": "releaseTemplates",
+	"EstafetteManifest.BotTemplates":     "botTemplates",
+	"EstafetteManifest.Bots":             "bots",
+	"EstafetteStage.ParallelStages":      "parallelStages",
+	"EstafetteRelease.Stages":            "stages",
+	"EstafetteReleaseTemplate.Stages":    "stages",
+	"EstafetteBot.Stages":                "stages",
+	"EstafetteBotTemplate.Stages":        "stages",
+	"EstafetteStageTemplate.Parameters":  "parameters",
+}
+
+// jsonSchemaComputedFields lists the fields that are set while reading the manifest instead of being part of it
+var jsonSchemaComputedFields = map[string]bool{
+	"EstafetteRelease.ResolvedTemplates": true,
+	"EstafetteBot.ResolvedTemplates":     true,
+}
+
+// jsonSchemaDeprecatedFields lists keys that are still accepted for backwards compatibility, but aren't part of the Go types anymore
+var jsonSchemaDeprecatedFields = map[string]map[string]string{
+	"EstafetteManifest": {"pipelines": "stages"},
+}
+
+// jsonSchemaEnums lists the allowed values for string types
+var jsonSchemaEnums = map[reflect.Type][]string{
+	reflect.TypeOf(OperatingSystemUnknown): {string(OperatingSystemLinux), string(OperatingSystemWindows)},
+	reflect.TypeOf(BuilderTypeUnknown):     {string(BuilderTypeDocker), string(BuilderTypeKubernetes)},
+	reflect.TypeOf(StorageMediumDefault):   {string(StorageMediumDefault), string(StorageMediumMemory)},
+	reflect.TypeOf(StagesMergeModeReplace): {string(StagesMergeModeReplace), string(StagesMergeModeAppend), string(StagesMergeModePatch)},
+}
+
+type jsonSchema map[string]interface{}
+
+type jsonSchemaGenerator struct {
+	definitions map[string]jsonSchema
+}
+
+// GenerateJSONSchema returns a JSON Schema for .estafette.yaml; it's generated from the Go types so editors can autocomplete and validate
+// the manifest exactly the way this library reads it
+func GenerateJSONSchema() ([]byte, error) {
+
+	g := &jsonSchemaGenerator{
+		definitions: map[string]jsonSchema{},
+	}
+
+	root, err := g.schemaFor(reflect.TypeOf(EstafetteManifest{}))
+	if err != nil {
+		return nil, err
+	}
+
+	schema := jsonSchema{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         "https://estafette.io/schemas/estafette-manifest.schema.json",
+		"title":       "Estafette CI manifest",
+		"description": "The .estafette.yaml file describing the build stages, releases, bots and triggers of an Estafette CI pipeline",
+		// in draft-07 everything next to $ref is ignored, so the manifest definition is referenced through allOf to keep the definitions
+		"allOf":       []jsonSchema{{"$ref": root["$ref"]}},
+		"definitions": g.definitions,
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+func (g *jsonSchemaGenerator) schemaFor(t reflect.Type) (jsonSchema, error) {
+
+	if t.Kind() == reflect.Ptr {
+		return g.schemaFor(t.Elem())
+	}
+
+	if enum, ok := jsonSchemaEnums[t]; ok {
+		return jsonSchema{"type": "string", "enum": enum}, nil
+	}
+
+	switch t {
+	case reflect.TypeOf(StringOrStringArray{}):
+		return jsonSchema{
+			"oneOf": []jsonSchema{
+				{"type": "string"},
+				{"type": "array", "items": jsonSchema{"type": "string"}},
+			},
+		}, nil
+	case reflect.TypeOf(Duration{}):
+		return jsonSchema{"type": "string"}, nil
+	case reflect.TypeOf(Quantity("")):
+		// quantities without a suffix are numbers in yaml
+		return jsonSchema{"oneOf": []jsonSchema{{"type": "string"}, {"type": "number"}}}, nil
+	case reflect.TypeOf(EstafetteRetries{}):
+		// retries can be just the count
+		definition, err := g.definitionFor(t)
+		if err != nil {
+			return nil, err
+		}
+		return jsonSchema{"oneOf": []jsonSchema{{"type": "integer"}, definition}}, nil
+	case reflect.TypeOf(EstafetteMatrix{}):
+		// the axes are keys next to include and exclude
+		combinations := jsonSchema{"type": "array", "items": jsonSchema{"type": "object", "additionalProperties": jsonSchema{"type": "string"}}}
+		return jsonSchema{
+			"type": "object",
+			"properties": jsonSchema{
+				"include": combinations,
+				"exclude": combinations,
+			},
+			"additionalProperties": jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}},
+		}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return jsonSchema{"type": "string"}, nil
+	case reflect.Bool:
+		return jsonSchema{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonSchema{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return jsonSchema{"type": "number"}, nil
+	case reflect.Interface:
+		return jsonSchema{}, nil
+	case reflect.Slice:
+		items, err := g.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return jsonSchema{"type": "array", "items": items}, nil
+	case reflect.Map:
+		values, err := g.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			return jsonSchema{"type": "object"}, nil
+		}
+		return jsonSchema{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return g.definitionFor(t)
+	}
+
+	return nil, fmt.Errorf("Type %v is not supported in the json schema", t)
+}
+
+// definitionFor adds a struct to the definitions once and returns a reference to it, which also takes care of recursive types like parallel stages
+func (g *jsonSchemaGenerator) definitionFor(t reflect.Type) (jsonSchema, error) {
+
+	ref := jsonSchema{"$ref": "#/definitions/" + t.Name()}
+
+	if _, exists := g.definitions[t.Name()]; exists {
+		return ref, nil
+	}
+
+	definition := jsonSchema{"type": "object"}
+	g.definitions[t.Name()] = definition
+
+	properties := jsonSchema{}
+	additionalProperties := false
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, options := parseYamlTag(field.Tag.Get("yaml"))
+
+		if key, ok := jsonSchemaMappingKeyedFields[t.Name()+"."+field.Name]; ok {
+			items, err := g.schemaFor(field.Type.Elem())
+			if err != nil {
+				return nil, err
+			}
+			properties[key] = jsonSchema{"type": "object", "additionalProperties": items}
+			continue
+		}
+
+		if name == "-" {
+			if field.Type.Kind() == reflect.Slice && !jsonSchemaComputedFields[t.Name()+"."+field.Name] {
+				return nil, fmt.Errorf("Field %v.%v is not serialized as is, add it to jsonSchemaMappingKeyedFields if it's keyed by name", t.Name(), field.Name)
+			}
+			continue
+		}
+
+		if options["inline"] && field.Type.Kind() == reflect.Struct {
+			// inlined structs add their properties to the ones of this type
+			if _, err := g.schemaFor(field.Type); err != nil {
+				return nil, err
+			}
+			inlined := g.definitions[field.Type.Name()]
+			for key, property := range inlined["properties"].(jsonSchema) {
+				properties[key] = property
+			}
+			additionalProperties = additionalProperties || inlined["additionalProperties"].(bool)
+			continue
+		}
+
+		if options["inline"] {
+			// inlined maps hold custom properties, for instance for extensions
+			additionalProperties = true
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		property, err := g.schemaFor(field.Type)
+		if err != nil {
+			return nil, err
+		}
+		properties[name] = property
+	}
+
+	for key, replacement := range jsonSchemaDeprecatedFields[t.Name()] {
+		properties[key] = jsonSchema{
+			"$ref":        fmt.Sprintf("#/definitions/%v/properties/%v", t.Name(), replacement),
+			"description": fmt.Sprintf("Deprecated, use %v instead", replacement),
+		}
+	}
+
+	definition["properties"] = properties
+	definition["additionalProperties"] = additionalProperties
+
+	// a trigger should have exactly one type of trigger
+	if t == reflect.TypeOf(EstafetteTrigger{}) {
+		oneOf := []jsonSchema{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Type.Kind() == reflect.Ptr && strings.HasSuffix(field.Type.Elem().Name(), "Trigger") {
+				name, _ := parseYamlTag(field.Tag.Get("yaml"))
+				oneOf = append(oneOf, jsonSchema{"required": []string{name}})
+			}
+		}
+		definition["oneOf"] = oneOf
+	}
+
+	return ref, nil
+}
+
+func parseYamlTag(tag string) (name string, options map[string]bool) {
+	options = map[string]bool{}
+	parts := strings.Split(tag, ",")
+	for _, o := range parts[1:] {
+		options[o] = true
+	}
+	return parts[0], options
+}
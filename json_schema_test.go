@@ -0,0 +1,114 @@
+package manifest
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateJSONSchema = flag.Bool("update-json-schema", false, "regenerate estafette-manifest.schema.json from the go types")
+
+func TestGenerateJSONSchema(t *testing.T) {
+	t.Run("MatchesCommittedSchemaFile", func(t *testing.T) {
+
+		// act
+		schema, err := GenerateJSONSchema()
+
+		assert.Nil(t, err)
+
+		if *updateJSONSchema {
+			err = ioutil.WriteFile("estafette-manifest.schema.json", schema, 0644)
+			assert.Nil(t, err)
+		}
+
+		committed, err := ioutil.ReadFile("estafette-manifest.schema.json")
+		assert.Nil(t, err)
+		assert.Equal(t, string(committed), string(schema), "estafette-manifest.schema.json is out of sync with the go types, run go test -run TestGenerateJSONSchema -update-json-schema")
+	})
+
+	t.Run("ReferencesManifestDefinitionThroughAllOfAtTheRoot", func(t *testing.T) {
+
+		schema, err := GenerateJSONSchema()
+		assert.Nil(t, err)
+
+		var parsed map[string]interface{}
+		err = json.Unmarshal(schema, &parsed)
+		assert.Nil(t, err)
+
+		// act
+		allOf := parsed["allOf"]
+
+		assert.NotContains(t, parsed, "$ref")
+		assert.Equal(t, []interface{}{map[string]interface{}{"$ref": "#/definitions/EstafetteManifest"}}, allOf)
+		assert.Contains(t, parsed["definitions"], "EstafetteManifest")
+	})
+
+	t.Run("ReturnsMappingKeyedStagesReleasesAndBots", func(t *testing.T) {
+
+		schema, err := GenerateJSONSchema()
+		assert.Nil(t, err)
+
+		var parsed map[string]interface{}
+		err = json.Unmarshal(schema, &parsed)
+		assert.Nil(t, err)
+
+		// act
+		properties := parsed["definitions"].(map[string]interface{})["EstafetteManifest"].(map[string]interface{})["properties"].(map[string]interface{})
+
+		for _, key := range []string{"stages", "releases", "releaseTemplates", "bots"} {
+			if assert.Contains(t, properties, key) {
+				assert.Equal(t, "object", properties[key].(map[string]interface{})["type"])
+				assert.Contains(t, properties[key], "additionalProperties")
+			}
+		}
+		assert.Contains(t, properties, "pipelines")
+	})
+
+	t.Run("ReturnsStringOrStringArrayAsUnion", func(t *testing.T) {
+
+		schema, err := GenerateJSONSchema()
+		assert.Nil(t, err)
+
+		var parsed map[string]interface{}
+		err = json.Unmarshal(schema, &parsed)
+		assert.Nil(t, err)
+
+		// act
+		releaseBranch := parsed["definitions"].(map[string]interface{})["EstafetteSemverVersion"].(map[string]interface{})["properties"].(map[string]interface{})["releaseBranch"].(map[string]interface{})
+
+		assert.Equal(t, 2, len(releaseBranch["oneOf"].([]interface{})))
+	})
+
+	t.Run("ReturnsOneOfRuleForTriggerTypes", func(t *testing.T) {
+
+		schema, err := GenerateJSONSchema()
+		assert.Nil(t, err)
+
+		var parsed map[string]interface{}
+		err = json.Unmarshal(schema, &parsed)
+		assert.Nil(t, err)
+
+		// act
+		trigger := parsed["definitions"].(map[string]interface{})["EstafetteTrigger"].(map[string]interface{})
+
+		assert.Equal(t, 9, len(trigger["oneOf"].([]interface{})))
+	})
+
+	t.Run("AllowsCustomPropertiesOnStages", func(t *testing.T) {
+
+		schema, err := GenerateJSONSchema()
+		assert.Nil(t, err)
+
+		var parsed map[string]interface{}
+		err = json.Unmarshal(schema, &parsed)
+		assert.Nil(t, err)
+
+		// act
+		stage := parsed["definitions"].(map[string]interface{})["EstafetteStage"].(map[string]interface{})
+
+		assert.Equal(t, true, stage["additionalProperties"])
+	})
+}
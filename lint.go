@@ -0,0 +1,238 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// LintSeverity indicates how serious a lint finding is
+type LintSeverity string
+
+const (
+	LintSeverityOff     LintSeverity = "off"
+	LintSeverityInfo    LintSeverity = "info"
+	LintSeverityWarning LintSeverity = "warning"
+	LintSeverityError   LintSeverity = "error"
+)
+
+// Finding is a problem in a manifest reported by a lint rule; unlike validation errors findings don't stop a manifest from being used
+type Finding struct {
+	RuleID   string       `yaml:"ruleID,omitempty" json:"ruleID,omitempty"`
+	Severity LintSeverity `yaml:"severity,omitempty" json:"severity,omitempty"`
+	Path     string       `yaml:"path,omitempty" json:"path,omitempty"`
+	Message  string       `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+// String returns the finding as a single line
+func (f Finding) String() string {
+	if f.Path == "" {
+		return fmt.Sprintf("%v [%v] %v", f.Severity, f.RuleID, f.Message)
+	}
+	return fmt.Sprintf("%v [%v] %v: %v", f.Severity, f.RuleID, f.Path, f.Message)
+}
+
+// LintRule checks a manifest for a single kind of problem; Check only has to set the path and message of its findings
+type LintRule struct {
+	ID          string
+	Description string
+	Severity    LintSeverity
+	Check       func(manifest *EstafetteManifest, preferences EstafetteManifestPreferences) []Finding
+}
+
+var (
+	lintRulesMutex sync.RWMutex
+	lintRules      = []LintRule{
+		deprecatedPipelinesLintRule,
+		deprecatedReadinessLintRule,
+		deprecatedReadinessFieldsLintRule,
+		readinessAndReadinessProbeLintRule,
+		latestImageTagLintRule,
+		unusedReleaseTemplateLintRule,
+	}
+)
+
+// RegisterLintRule adds a custom rule to the ones run by Lint
+func RegisterLintRule(rule LintRule) error {
+	if rule.ID == "" {
+		return fmt.Errorf("Lint rule should have an id")
+	}
+	if rule.Check == nil {
+		return fmt.Errorf("Lint rule %v should have a check", rule.ID)
+	}
+	if rule.Severity == "" || rule.Severity == LintSeverityOff {
+		return fmt.Errorf("Lint rule %v should have severity %v, %v or %v", rule.ID, LintSeverityInfo, LintSeverityWarning, LintSeverityError)
+	}
+
+	lintRulesMutex.Lock()
+	defer lintRulesMutex.Unlock()
+
+	for _, r := range lintRules {
+		if r.ID == rule.ID {
+			return fmt.Errorf("Lint rule %v is already registered", rule.ID)
+		}
+	}
+
+	lintRules = append(lintRules, rule)
+
+	return nil
+}
+
+// GetLintRules returns all registered rules, both built-in and custom ones
+func GetLintRules() []LintRule {
+	lintRulesMutex.RLock()
+	defer lintRulesMutex.RUnlock()
+
+	return append([]LintRule{}, lintRules...)
+}
+
+// Lint runs all registered rules against the manifest; preferences.LintRules can change the severity of a rule or turn it off
+func Lint(manifest *EstafetteManifest, preferences EstafetteManifestPreferences) []Finding {
+
+	findings := []Finding{}
+
+	if manifest == nil {
+		return findings
+	}
+
+	for _, rule := range GetLintRules() {
+		severity := rule.Severity
+		if override, ok := preferences.LintRules[rule.ID]; ok {
+			severity = override
+		}
+		if severity == LintSeverityOff {
+			continue
+		}
+
+		for _, f := range rule.Check(manifest, preferences) {
+			f.RuleID = rule.ID
+			f.Severity = severity
+			findings = append(findings, f)
+		}
+	}
+
+	return findings
+}
+
+var deprecatedPipelinesLintRule = LintRule{
+	ID:          "deprecated-pipelines",
+	Description: "The pipelines section has been renamed to stages",
+	Severity:    LintSeverityWarning,
+	Check: func(manifest *EstafetteManifest, preferences EstafetteManifestPreferences) (findings []Finding) {
+		if manifest.usesDeprecatedPipelines {
+			findings = append(findings, Finding{Path: "pipelines", Message: "The pipelines section is deprecated, rename it to stages"})
+		}
+		return
+	},
+}
+
+var deprecatedReadinessLintRule = LintRule{
+	ID:          "deprecated-readiness",
+	Description: "The readiness property of services has been replaced by readinessProbe",
+	Severity:    LintSeverityInfo,
+	Check: func(manifest *EstafetteManifest, preferences EstafetteManifestPreferences) (findings []Finding) {
+		manifest.walkServices(func(path string, service *EstafetteService) {
+			if service.Readiness != nil && service.ReadinessProbe == nil {
+				findings = append(findings, Finding{Path: path + ".readiness", Message: "The readiness property is deprecated, use readinessProbe instead"})
+			}
+		})
+		return
+	},
+}
+
+var deprecatedReadinessFieldsLintRule = LintRule{
+	ID:          "deprecated-readiness-fields",
+	Description: "The path, port, protocol and hostname fields of a readiness probe have been replaced by httpGet",
+	Severity:    LintSeverityWarning,
+	Check: func(manifest *EstafetteManifest, preferences EstafetteManifestPreferences) (findings []Finding) {
+		manifest.walkServices(func(path string, service *EstafetteService) {
+			if service.Readiness != nil && service.Readiness.setsDeprecatedFieldsExplicitly() {
+				findings = append(findings, Finding{Path: path + ".readiness", Message: "The path, port, protocol and hostname fields are deprecated, use httpGet.path, httpGet.port, httpGet.scheme and httpGet.host instead"})
+			}
+			if service.ReadinessProbe != nil && service.ReadinessProbe.setsDeprecatedFieldsExplicitly() {
+				findings = append(findings, Finding{Path: path + ".readinessProbe", Message: "The path, port, protocol and hostname fields are deprecated, use httpGet.path, httpGet.port, httpGet.scheme and httpGet.host instead"})
+			}
+		})
+		return
+	},
+}
+
+var readinessAndReadinessProbeLintRule = LintRule{
+	ID:          "readiness-and-readiness-probe",
+	Description: "A service should only set one of readiness and readinessProbe",
+	Severity:    LintSeverityWarning,
+	Check: func(manifest *EstafetteManifest, preferences EstafetteManifestPreferences) (findings []Finding) {
+		manifest.walkServices(func(path string, service *EstafetteService) {
+			if service.Readiness != nil && service.ReadinessProbe != nil {
+				findings = append(findings, Finding{Path: path, Message: fmt.Sprintf("Service %v sets both readiness and readinessProbe, only readinessProbe is used", service.Name)})
+			}
+		})
+		return
+	},
+}
+
+var latestImageTagLintRule = LintRule{
+	ID:          "latest-image-tag",
+	Description: "Images should be pinned to a tag other than latest for reproducible builds",
+	Severity:    LintSeverityWarning,
+	Check: func(manifest *EstafetteManifest, preferences EstafetteManifestPreferences) (findings []Finding) {
+		manifest.walkStages(func(path string, stage *EstafetteStage) {
+			if usesLatestTag(stage.ContainerImage) {
+				findings = append(findings, Finding{Path: path + ".image", Message: fmt.Sprintf("Image %v uses the latest tag, pin it to a specific version instead", stage.ContainerImage)})
+			}
+		})
+		manifest.walkServices(func(path string, service *EstafetteService) {
+			if usesLatestTag(service.ContainerImage) {
+				findings = append(findings, Finding{Path: path + ".image", Message: fmt.Sprintf("Image %v uses the latest tag, pin it to a specific version instead", service.ContainerImage)})
+			}
+		})
+		return
+	},
+}
+
+var unusedReleaseTemplateLintRule = LintRule{
+	ID:          "unused-release-template",
+	Description: "Release templates should be used by at least one release",
+	Severity:    LintSeverityWarning,
+	Check: func(manifest *EstafetteManifest, preferences EstafetteManifestPreferences) (findings []Finding) {
+		used := map[string]bool{}
+		for _, r := range manifest.Releases {
+			used[r.Template] = true
+		}
+		// templates other templates inherit from are used as well
+		for _, rt := range manifest.ReleaseTemplates {
+			used[rt.Template] = true
+		}
+		for _, rt := range manifest.ReleaseTemplates {
+			if !used[rt.Name] {
+				findings = append(findings, Finding{Path: fmt.Sprintf("releaseTemplates.%v", rt.Name), Message: fmt.Sprintf("Release template %v is not used by any release", rt.Name)})
+			}
+		}
+		return
+	},
+}
+
+// walkServices calls fn for every service of every stage, together with its structured path
+func (c *EstafetteManifest) walkServices(fn func(path string, service *EstafetteService)) {
+	c.walkStages(func(path string, stage *EstafetteStage) {
+		for i, svc := range stage.Services {
+			fn(fmt.Sprintf("%v.services[%v]", path, i), svc)
+		}
+	})
+}
+
+// usesLatestTag checks whether an image has no tag or digest, or explicitly uses the latest tag
+func usesLatestTag(image string) bool {
+	if image == "" || strings.Contains(image, "@") || strings.Contains(image, "${") {
+		return false
+	}
+
+	// a colon before the last slash separates a registry host from its port
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	if i < 0 {
+		return true
+	}
+
+	return name[i+1:] == "latest"
+}
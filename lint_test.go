@@ -0,0 +1,304 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	t.Run("ReturnsNoFindingsForCleanManifest", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+    services:
+    - name: database
+      image: cockroachdb/cockroach:v19.1.5
+      readinessProbe:
+        httpGet:
+          path: /health?ready=1
+          port: 8080`, true)
+		assert.Nil(t, err)
+
+		// act
+		findings := Lint(&manifest, *GetDefaultManifestPreferences())
+
+		assert.Equal(t, 0, len(findings))
+	})
+
+	t.Run("ReturnsWarningForDeprecatedPipelinesSection", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+pipelines:
+  build:
+    image: golang:1.17-alpine`, true)
+		assert.Nil(t, err)
+
+		// act
+		findings := Lint(&manifest, *GetDefaultManifestPreferences())
+
+		if assert.Equal(t, 1, len(findings)) {
+			assert.Equal(t, "deprecated-pipelines", findings[0].RuleID)
+			assert.Equal(t, LintSeverityWarning, findings[0].Severity)
+			assert.Equal(t, "pipelines", findings[0].Path)
+		}
+	})
+
+	t.Run("ReturnsFindingsForLegacyReadiness", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  integration-test:
+    image: golang:1.17-alpine
+    services:
+    - name: kubernetes
+      image: bsycorp/kind:latest-1.15
+      readiness:
+        path: /kubernetes-ready
+        port: 80
+      readinessProbe:
+        httpGet:
+          path: /kubernetes-ready`, true)
+		assert.Nil(t, err)
+
+		// act
+		findings := Lint(&manifest, *GetDefaultManifestPreferences())
+
+		if assert.Equal(t, 2, len(findings)) {
+			assert.Equal(t, "deprecated-readiness-fields", findings[0].RuleID)
+			assert.Equal(t, "stages.integration-test.services[0].readiness", findings[0].Path)
+			assert.Equal(t, "readiness-and-readiness-probe", findings[1].RuleID)
+			assert.Equal(t, "stages.integration-test.services[0]", findings[1].Path)
+		}
+	})
+
+	t.Run("ReturnsNoFindingsForDefaultsOfLegacyReadinessFields", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  integration-test:
+    image: golang:1.17-alpine
+    services:
+    - name: kubernetes
+      image: bsycorp/kind:v1.15.0
+      readinessProbe:
+        timeoutSeconds: 120`, true)
+		assert.Nil(t, err)
+
+		// act
+		findings := Lint(&manifest, *GetDefaultManifestPreferences())
+
+		assert.Equal(t, 80, manifest.Stages[0].Services[0].ReadinessProbe.Port)
+		assert.Equal(t, 0, len(findings))
+	})
+
+	t.Run("ReturnsSameReadinessFindingsForDeepCopiesAndMatrixStages", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  integration-test:
+    image: golang:1.17-alpine
+    matrix:
+      go: [1.16, 1.17]
+    services:
+    - name: kubernetes
+      image: bsycorp/kind:v1.15.0
+      readinessProbe:
+        timeoutSeconds: 120
+  e2e-test:
+    image: golang:1.17-alpine
+    services:
+    - name: kubernetes
+      image: bsycorp/kind:v1.15.0
+      readinessProbe:
+        path: /kubernetes-ready`, true)
+		assert.Nil(t, err)
+
+		copied := manifest.DeepCopy()
+
+		// act
+		findings := Lint(&copied, *GetDefaultManifestPreferences())
+
+		if assert.Equal(t, 1, len(findings)) {
+			assert.Equal(t, "deprecated-readiness-fields", findings[0].RuleID)
+			assert.Equal(t, "stages.e2e-test.services[0].readinessProbe", findings[0].Path)
+		}
+	})
+
+	t.Run("ReturnsInfoForDeprecatedReadiness", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  integration-test:
+    image: golang:1.17-alpine
+    services:
+    - name: kubernetes
+      image: bsycorp/kind:latest-1.15
+      readiness:
+        httpGet:
+          path: /kubernetes-ready`, true)
+		assert.Nil(t, err)
+
+		// act
+		findings := Lint(&manifest, *GetDefaultManifestPreferences())
+
+		if assert.Equal(t, 1, len(findings)) {
+			assert.Equal(t, "deprecated-readiness", findings[0].RuleID)
+			assert.Equal(t, LintSeverityInfo, findings[0].Severity)
+		}
+	})
+
+	t.Run("ReturnsWarningForLatestOrMissingImageTags", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:latest
+  bake:
+    image: localhost:5000/extensions/docker
+  push:
+    image: extensions/docker:stable
+
+releases:
+  production:
+    stages:
+      deploy:
+        image: extensions/gke@sha256:8a4c4a1e4f8d`, true)
+		assert.Nil(t, err)
+
+		// act
+		findings := Lint(&manifest, *GetDefaultManifestPreferences())
+
+		if assert.Equal(t, 2, len(findings)) {
+			assert.Equal(t, "latest-image-tag", findings[0].RuleID)
+			assert.Equal(t, "stages.build.image", findings[0].Path)
+			assert.Equal(t, "stages.bake.image", findings[1].Path)
+		}
+	})
+
+	t.Run("ReturnsWarningForUnusedReleaseTemplates", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+releaseTemplates:
+  base:
+    clone: true
+  gke:
+    template: base
+    stages:
+      deploy:
+        image: extensions/gke:stable
+  cloudrun:
+    stages:
+      deploy:
+        image: extensions/cloudrun:stable
+
+releases:
+  production:
+    template: gke`, true)
+		assert.Nil(t, err)
+
+		// act
+		findings := Lint(&manifest, *GetDefaultManifestPreferences())
+
+		if assert.Equal(t, 1, len(findings)) {
+			assert.Equal(t, "unused-release-template", findings[0].RuleID)
+			assert.Equal(t, "releaseTemplates.cloudrun", findings[0].Path)
+		}
+	})
+
+	t.Run("AppliesSeverityOverridesAndDisabledRulesFromPreferences", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+pipelines:
+  build:
+    image: golang:latest`, true)
+		assert.Nil(t, err)
+
+		preferences := GetDefaultManifestPreferences()
+		preferences.LintRules = map[string]LintSeverity{
+			"deprecated-pipelines": LintSeverityOff,
+			"latest-image-tag":     LintSeverityError,
+		}
+
+		// act
+		findings := Lint(&manifest, *preferences)
+
+		if assert.Equal(t, 1, len(findings)) {
+			assert.Equal(t, "latest-image-tag", findings[0].RuleID)
+			assert.Equal(t, LintSeverityError, findings[0].Severity)
+		}
+	})
+}
+
+func TestRegisterLintRule(t *testing.T) {
+
+	// don't leak the rules registered here into other tests
+	registered := GetLintRules()
+	t.Cleanup(func() {
+		lintRulesMutex.Lock()
+		defer lintRulesMutex.Unlock()
+		lintRules = registered
+	})
+
+	t.Run("RunsCustomRulesInLint", func(t *testing.T) {
+
+		err := RegisterLintRule(LintRule{
+			ID:       "test-team-label",
+			Severity: LintSeverityError,
+			Check: func(manifest *EstafetteManifest, preferences EstafetteManifestPreferences) (findings []Finding) {
+				if _, ok := manifest.Labels["team"]; !ok && manifest.Labels["app"] == "lint-test" {
+					findings = append(findings, Finding{Path: "labels", Message: "Set a team label"})
+				}
+				return
+			},
+		})
+		assert.Nil(t, err)
+
+		manifest, err := ReadManifest(nil, `
+labels:
+  app: lint-test
+stages:
+  build:
+    image: golang:1.17-alpine`, true)
+		assert.Nil(t, err)
+
+		// act
+		findings := Lint(&manifest, *GetDefaultManifestPreferences())
+
+		if assert.Equal(t, 1, len(findings)) {
+			assert.Equal(t, "test-team-label", findings[0].RuleID)
+			assert.Equal(t, LintSeverityError, findings[0].Severity)
+		}
+	})
+
+	t.Run("ReturnsErrorForDuplicateRuleID", func(t *testing.T) {
+
+		// act
+		err := RegisterLintRule(LintRule{
+			ID:       "latest-image-tag",
+			Severity: LintSeverityError,
+			Check: func(manifest *EstafetteManifest, preferences EstafetteManifestPreferences) []Finding {
+				return nil
+			},
+		})
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForRuleWithoutCheck", func(t *testing.T) {
+
+		// act
+		err := RegisterLintRule(LintRule{
+			ID:       "without-check",
+			Severity: LintSeverityError,
+		})
+
+		assert.NotNil(t, err)
+	})
+}
@@ -2,13 +2,13 @@ package manifest
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/jinzhu/copier"
-	"github.com/rs/zerolog/log"
 
 	yaml "gopkg.in/yaml.v2"
 )
@@ -21,10 +21,16 @@ type EstafetteManifest struct {
 	Version          EstafetteVersion            `yaml:"version,omitempty"`
 	GlobalEnvVars    map[string]string           `yaml:"env,omitempty"`
 	Triggers         []*EstafetteTrigger         `yaml:"triggers,omitempty"`
+	Includes         []string                    `yaml:"include,omitempty" json:",omitempty"`
+	StageTemplates   []*EstafetteStageTemplate   `yaml:"-" json:",omitempty"`
 	Stages           []*EstafetteStage           `yaml:"-"`
 	Releases         []*EstafetteRelease         `yaml:"-"`
 	ReleaseTemplates []*EstafetteReleaseTemplate `yaml:"-"`
+	BotTemplates     []*EstafetteBotTemplate     `yaml:"-" json:",omitempty"`
 	Bots             []*EstafetteBot             `yaml:"-"`
+
+	// usesDeprecatedPipelines is set when the manifest defines its stages in the deprecated pipelines section
+	usesDeprecatedPipelines bool
 }
 
 // UnmarshalYAML customizes unmarshalling an EstafetteManifest
@@ -38,9 +44,12 @@ func (c *EstafetteManifest) UnmarshalYAML(unmarshal func(interface{}) error) (er
 		GlobalEnvVars       map[string]string   `yaml:"env"`
 		DeprecatedPipelines yaml.MapSlice       `yaml:"pipelines"`
 		Triggers            []*EstafetteTrigger `yaml:"triggers"`
+		Includes            []string            `yaml:"include"`
+		StageTemplates      yaml.MapSlice       `yaml:"stageTemplates"`
 		Stages              yaml.MapSlice       `yaml:"stages"`
 		Releases            yaml.MapSlice       `yaml:"releases"`
 		ReleaseTemplates    yaml.MapSlice       `yaml:"releaseTemplates"`
+		BotTemplates        yaml.MapSlice       `yaml:"botTemplates"`
 		Bots                yaml.MapSlice       `yaml:"bots"`
 	}
 
@@ -56,22 +65,43 @@ func (c *EstafetteManifest) UnmarshalYAML(unmarshal func(interface{}) error) (er
 	c.Labels = aux.Labels
 	c.GlobalEnvVars = aux.GlobalEnvVars
 	c.Triggers = aux.Triggers
+	c.Includes = aux.Includes
 
 	// provide backwards compatibility for the deprecated pipelines section now renamed to stages
 	if len(aux.Stages) == 0 && len(aux.DeprecatedPipelines) > 0 {
 		aux.Stages = aux.DeprecatedPipelines
+		c.usesDeprecatedPipelines = true
+	}
+
+	for _, mi := range aux.StageTemplates {
+
+		bytes, err := yaml.Marshal(mi.Value)
+		if err != nil {
+			return wrapUnmarshalError(fmt.Sprintf("stageTemplates.%v", mi.Key), err)
+		}
+
+		var stageTemplate *EstafetteStageTemplate
+		if err := yaml.Unmarshal(bytes, &stageTemplate); err != nil {
+			return wrapUnmarshalError(fmt.Sprintf("stageTemplates.%v", mi.Key), err)
+		}
+		if stageTemplate == nil {
+			stageTemplate = &EstafetteStageTemplate{}
+		}
+
+		stageTemplate.Name = mi.Key.(string)
+		c.StageTemplates = append(c.StageTemplates, stageTemplate)
 	}
 
 	for _, mi := range aux.Stages {
 
 		bytes, err := yaml.Marshal(mi.Value)
 		if err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("stages.%v", mi.Key), err)
 		}
 
 		var stage *EstafetteStage
 		if err := yaml.Unmarshal(bytes, &stage); err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("stages.%v", mi.Key), err)
 		}
 		if stage == nil {
 			stage = &EstafetteStage{}
@@ -89,12 +119,12 @@ func (c *EstafetteManifest) UnmarshalYAML(unmarshal func(interface{}) error) (er
 
 		bytes, err := yaml.Marshal(mi.Value)
 		if err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("releaseTemplates.%v", mi.Key), err)
 		}
 
 		var releaseTemplate *EstafetteReleaseTemplate
 		if err := yaml.Unmarshal(bytes, &releaseTemplate); err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("releaseTemplates.%v", mi.Key), err)
 		}
 		if releaseTemplate == nil {
 			releaseTemplate = &EstafetteReleaseTemplate{}
@@ -112,12 +142,12 @@ func (c *EstafetteManifest) UnmarshalYAML(unmarshal func(interface{}) error) (er
 
 		bytes, err := yaml.Marshal(mi.Value)
 		if err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("releases.%v", mi.Key), err)
 		}
 
 		var release *EstafetteRelease
 		if err := yaml.Unmarshal(bytes, &release); err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("releases.%v", mi.Key), err)
 		}
 		if release == nil {
 			release = &EstafetteRelease{}
@@ -132,25 +162,55 @@ func (c *EstafetteManifest) UnmarshalYAML(unmarshal func(interface{}) error) (er
 		c.Releases = append(c.Releases, release)
 	}
 
+	botTemplates := map[string]*EstafetteBotTemplate{}
+
+	for _, mi := range aux.BotTemplates {
+
+		bytes, err := yaml.Marshal(mi.Value)
+		if err != nil {
+			return wrapUnmarshalError(fmt.Sprintf("botTemplates.%v", mi.Key), err)
+		}
+
+		var botTemplate *EstafetteBotTemplate
+		if err := yaml.Unmarshal(bytes, &botTemplate); err != nil {
+			return wrapUnmarshalError(fmt.Sprintf("botTemplates.%v", mi.Key), err)
+		}
+		if botTemplate == nil {
+			botTemplate = &EstafetteBotTemplate{}
+		}
+
+		if botTemplate.Name == "" {
+			botTemplate.Name = mi.Key.(string)
+		}
+		c.BotTemplates = append(c.BotTemplates, botTemplate)
+
+		botTemplates[botTemplate.Name] = botTemplate
+	}
+
 	for _, mi := range aux.Bots {
 
 		bytes, err := yaml.Marshal(mi.Value)
 		if err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("bots.%v", mi.Key), err)
 		}
 
 		var bot *EstafetteBot
 		if err := yaml.Unmarshal(bytes, &bot); err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("bots.%v", mi.Key), err)
 		}
 		if bot == nil {
 			bot = &EstafetteBot{}
 		}
 
 		bot.Name = mi.Key.(string)
+
+		bot.InitFromTemplate(botTemplates)
+
 		c.Bots = append(c.Bots, bot)
 	}
 
+	c.resolveStageTemplates()
+
 	return nil
 }
 
@@ -163,9 +223,12 @@ func (c EstafetteManifest) MarshalYAML() (out interface{}, err error) {
 		Version          EstafetteVersion    `yaml:"version,omitempty"`
 		GlobalEnvVars    map[string]string   `yaml:"env,omitempty"`
 		Triggers         []*EstafetteTrigger `yaml:"triggers,omitempty"`
+		Includes         []string            `yaml:"include,omitempty"`
+		StageTemplates   yaml.MapSlice       `yaml:"stageTemplates,omitempty"`
 		Stages           yaml.MapSlice       `yaml:"stages,omitempty"`
 		Releases         yaml.MapSlice       `yaml:"releases,omitempty"`
 		ReleaseTemplates yaml.MapSlice       `yaml:"releaseTemplates,omitempty"`
+		BotTemplates     yaml.MapSlice       `yaml:"botTemplates,omitempty"`
 		Bots             yaml.MapSlice       `yaml:"bots,omitempty"`
 	}
 
@@ -175,7 +238,14 @@ func (c EstafetteManifest) MarshalYAML() (out interface{}, err error) {
 	aux.Version = c.Version
 	aux.GlobalEnvVars = c.GlobalEnvVars
 	aux.Triggers = c.Triggers
+	aux.Includes = c.Includes
 
+	for _, stageTemplate := range c.StageTemplates {
+		aux.StageTemplates = append(aux.StageTemplates, yaml.MapItem{
+			Key:   stageTemplate.Name,
+			Value: stageTemplate,
+		})
+	}
 	for _, stage := range c.Stages {
 		aux.Stages = append(aux.Stages, yaml.MapItem{
 			Key:   stage.Name,
@@ -194,6 +264,12 @@ func (c EstafetteManifest) MarshalYAML() (out interface{}, err error) {
 			Value: releaseTemplate,
 		})
 	}
+	for _, botTemplate := range c.BotTemplates {
+		aux.BotTemplates = append(aux.BotTemplates, yaml.MapItem{
+			Key:   botTemplate.Name,
+			Value: botTemplate,
+		})
+	}
 	for _, bot := range c.Bots {
 		aux.Bots = append(aux.Bots, yaml.MapItem{
 			Key:   bot.Name,
@@ -206,6 +282,9 @@ func (c EstafetteManifest) MarshalYAML() (out interface{}, err error) {
 
 // SetDefaults sets default values for properties of EstafetteManifest if not defined
 func (c *EstafetteManifest) SetDefaults(preferences EstafetteManifestPreferences) {
+	// included files can add stage templates after unmarshalling, so apply them again before setting the stage defaults
+	c.resolveStageTemplates()
+
 	c.Builder.SetDefaults(preferences)
 	c.Version.SetDefaults()
 
@@ -213,6 +292,8 @@ func (c *EstafetteManifest) SetDefaults(preferences EstafetteManifestPreferences
 		t.SetDefaults(preferences, TriggerTypeBuild, "")
 	}
 	for _, s := range c.Stages {
+		// expand the matrix first, so the parallel stages get defaults as well
+		s.expandMatrix(preferences.MaxMatrixSize)
 		s.SetDefaults(c.Builder)
 	}
 
@@ -231,6 +312,8 @@ func (c *EstafetteManifest) SetDefaults(preferences EstafetteManifestPreferences
 			t.SetDefaults(preferences, TriggerTypeRelease, r.Name)
 		}
 		for _, s := range r.Stages {
+			// expand the matrix first, so the parallel stages get defaults as well
+			s.expandMatrix(preferences.MaxMatrixSize)
 			s.SetDefaults(*r.Builder)
 		}
 	}
@@ -250,99 +333,158 @@ func (c *EstafetteManifest) SetDefaults(preferences EstafetteManifestPreferences
 			t.SetDefaults(preferences, TriggerTypeBot, b.Name)
 		}
 		for _, s := range b.Stages {
+			// expand the matrix first, so the parallel stages get defaults as well
+			s.expandMatrix(preferences.MaxMatrixSize)
 			s.SetDefaults(*b.Builder)
 		}
 	}
+
+	// stages grouping parallel stages don't run themselves, so only the stages that do and their services get the default resources
+	c.walkStages(func(path string, stage *EstafetteStage) {
+		if len(stage.ParallelStages) == 0 {
+			stage.CPU = stage.CPU.withDefaults(preferences.DefaultCPU)
+			stage.Memory = stage.Memory.withDefaults(preferences.DefaultMemory)
+		}
+		for _, svc := range stage.Services {
+			svc.CPU = svc.CPU.withDefaults(preferences.DefaultCPU)
+			svc.Memory = svc.Memory.withDefaults(preferences.DefaultMemory)
+		}
+	})
 }
 
-// Validate checks if the manifest is valid
+// Validate checks if the manifest is valid and returns all problems found as ValidationErrors
 func (c *EstafetteManifest) Validate(preferences EstafetteManifestPreferences) (err error) {
 
-	err = c.Builder.validate(preferences)
-	if err != nil {
-		return
+	var errs ValidationErrors
+
+	errs.add("builder", c.Builder.validate(preferences))
+
+	// loop labels and check if they meet the label regexes; sorted so problems are reported in a stable order
+	labelKeys := make([]string, 0, len(c.Labels))
+	for key := range c.Labels {
+		labelKeys = append(labelKeys, key)
 	}
+	sort.Strings(labelKeys)
 
-	// loop labels and check if they meet the label regexes
-	for key, value := range c.Labels {
+	for _, key := range labelKeys {
+		value := c.Labels[key]
 		if pattern, ok := preferences.LabelRegexes[key]; ok {
 			pattern = fmt.Sprintf("^%v$", strings.TrimSpace(pattern))
 
 			match, err := regexp.MatchString(pattern, value)
 			if err != nil {
-				return err
+				errs.add(fmt.Sprintf("labels.%v", key), err)
+				continue
 			}
 
 			if !match {
-				return fmt.Errorf("Label %v does not match regex %v", key, pattern)
+				errs.addf(fmt.Sprintf("labels.%v", key), "Label %v does not match regex %v", key, pattern)
 			}
 		}
 	}
 
+	errs.add("", c.validateStageTemplates())
+
+	c.walkStages(func(path string, stage *EstafetteStage) {
+		if stage.Matrix != nil && preferences.MaxMatrixSize > 0 {
+			if size := stage.Matrix.size(); size > maxMatrixCombinationsToBuild {
+				errs.addf(path+".matrix", "Matrix of stage %v has over %v combinations, more than the maximum of %v", stage.Name, maxMatrixCombinationsToBuild, preferences.MaxMatrixSize)
+			} else if size > preferences.MaxMatrixSize {
+				errs.addf(path+".matrix", "Matrix of stage %v has %v combinations, more than the maximum of %v", stage.Name, size, preferences.MaxMatrixSize)
+			}
+		}
+
+		errs.add(path, validateExecutionMaximums(stage.Timeout, stage.Retries, preferences))
+		errs.add(path, validateResourceMaximums(stage.CPU, stage.Memory, preferences))
+		for i, svc := range stage.Services {
+			errs.add(fmt.Sprintf("%v.services[%v]", path, i), validateExecutionMaximums(svc.Timeout, svc.Retries, preferences))
+			errs.add(fmt.Sprintf("%v.services[%v]", path, i), validateResourceMaximums(svc.CPU, svc.Memory, preferences))
+		}
+	})
+
 	if len(c.Stages) == 0 {
-		return fmt.Errorf("The manifest should define 1 or more stages")
+		errs.addf("stages", "The manifest should define 1 or more stages")
 	}
 	for _, s := range c.Stages {
-		err = s.Validate()
-		if err != nil {
-			return
-		}
+		errs.add(fmt.Sprintf("stages.%v", s.Name), s.Validate())
+	}
+	if _, err := c.StageGraph(); err != nil {
+		errs.add("", err)
 	}
 
-	for _, t := range c.Triggers {
-		err = t.Validate("build", "")
-		if err != nil {
-			return
-		}
+	for i, t := range c.Triggers {
+		errs.add(fmt.Sprintf("triggers[%v]", i), t.Validate(TriggerTypeBuild, ""))
+	}
+
+	releaseTemplates := map[string]*EstafetteReleaseTemplate{}
+	for _, rt := range c.ReleaseTemplates {
+		releaseTemplates[rt.Name] = rt
+	}
+	for _, rt := range c.ReleaseTemplates {
+		errs.add(fmt.Sprintf("releaseTemplates.%v", rt.Name), rt.validate(releaseTemplates))
 	}
 
 	for _, r := range c.Releases {
+		path := fmt.Sprintf("releases.%v", r.Name)
+
 		if r.Builder != nil {
-			err = r.Builder.validate(preferences)
-			if err != nil {
-				return
-			}
+			errs.add(path+".builder", r.Builder.validate(preferences))
 		}
 
-		for _, t := range r.Triggers {
-			err = t.Validate(TriggerTypeRelease, r.Name)
-			if err != nil {
-				return
+		if r.Template != "" {
+			if _, found := releaseTemplates[r.Template]; !found {
+				errs.addf(path+".template", "Release %v uses unknown release template %v", r.Name, r.Template)
 			}
 		}
+		errs.add(path+".stagesMerge", validateStagesMerge(r.StagesMerge))
+
+		for i, t := range r.Triggers {
+			errs.add(fmt.Sprintf("%v.triggers[%v]", path, i), t.Validate(TriggerTypeRelease, r.Name))
+		}
 
 		for _, s := range r.Stages {
-			err = s.Validate()
-			if err != nil {
-				return
-			}
+			errs.add(fmt.Sprintf("%v.stages.%v", path, s.Name), s.Validate())
 		}
+		if _, err := r.StageGraph(); err != nil {
+			errs.add(path, err)
+		}
+	}
+
+	botTemplates := map[string]*EstafetteBotTemplate{}
+	for _, bt := range c.BotTemplates {
+		botTemplates[bt.Name] = bt
+	}
+	for _, bt := range c.BotTemplates {
+		errs.add(fmt.Sprintf("botTemplates.%v", bt.Name), bt.validate(botTemplates))
 	}
 
 	for _, b := range c.Bots {
+		path := fmt.Sprintf("bots.%v", b.Name)
+
 		if b.Builder != nil {
-			err = b.Builder.validate(preferences)
-			if err != nil {
-				return
-			}
+			errs.add(path+".builder", b.Builder.validate(preferences))
 		}
 
-		for _, t := range b.Triggers {
-			err = t.Validate(TriggerTypeBot, b.Name)
-			if err != nil {
-				return
+		if b.Template != "" {
+			if _, found := botTemplates[b.Template]; !found {
+				errs.addf(path+".template", "Bot %v uses unknown bot template %v", b.Name, b.Template)
 			}
 		}
+		errs.add(path+".stagesMerge", validateStagesMerge(b.StagesMerge))
+
+		for i, t := range b.Triggers {
+			errs.add(fmt.Sprintf("%v.triggers[%v]", path, i), t.Validate(TriggerTypeBot, b.Name))
+		}
 
 		for _, s := range b.Stages {
-			err = s.Validate()
-			if err != nil {
-				return
-			}
+			errs.add(fmt.Sprintf("%v.stages.%v", path, s.Name), s.Validate())
+		}
+		if _, err := b.StageGraph(); err != nil {
+			errs.add(path, err)
 		}
 	}
 
-	return nil
+	return errs.errorOrNil()
 }
 
 // GetAllTriggers returns both build and release triggers as one list
@@ -383,6 +525,58 @@ func (c *EstafetteManifest) GetAllTriggers(repoSource, repoOwner, repoName strin
 	return triggers
 }
 
+// MatchEvent returns the actions to take for event, each with the build, release or bot trigger that fired; repo is the full name of this
+// pipeline, like github.com/estafette/estafette-ci-manifest, and replaces 'self' in the returned triggers
+func (c *EstafetteManifest) MatchEvent(event *EstafetteEvent, repo string) []EstafetteTriggerMatch {
+
+	matches := make([]EstafetteTriggerMatch, 0)
+
+	fire := func(triggerType TriggerType, target string, triggers []*EstafetteTrigger) {
+		// a manual event only fires the triggers of the build, release or bot it was started for
+		if event != nil && event.Manual != nil && !event.Manual.targets(triggerType, target) {
+			return
+		}
+		for _, t := range triggers {
+			if t == nil {
+				continue
+			}
+			trigger := t.withSelfReplaced(repo)
+			if trigger.Fires(event) {
+				matches = append(matches, trigger.match(triggerType, event))
+			}
+		}
+	}
+
+	fire(TriggerTypeBuild, "", c.Triggers)
+	for _, r := range c.Releases {
+		fire(TriggerTypeRelease, r.Name, r.Triggers)
+	}
+	for _, b := range c.Bots {
+		fire(TriggerTypeBot, b.Name, b.Triggers)
+	}
+
+	return matches
+}
+
+// walkStages calls fn for every stage of the build, releases and bots, including inner parallel stages, together with its structured path
+func (c *EstafetteManifest) walkStages(fn func(path string, stage *EstafetteStage)) {
+	walkStages("stages", c.Stages, fn)
+	for _, r := range c.Releases {
+		walkStages(fmt.Sprintf("releases.%v.stages", r.Name), r.Stages, fn)
+	}
+	for _, b := range c.Bots {
+		walkStages(fmt.Sprintf("bots.%v.stages", b.Name), b.Stages, fn)
+	}
+}
+
+func walkStages(path string, stages []*EstafetteStage, fn func(path string, stage *EstafetteStage)) {
+	for _, s := range stages {
+		stagePath := fmt.Sprintf("%v.%v", path, s.Name)
+		fn(stagePath, s)
+		walkStages(stagePath+".parallelStages", s.ParallelStages, fn)
+	}
+}
+
 // DeepCopy provides a copy of all nested pointers
 func (c *EstafetteManifest) DeepCopy() (target EstafetteManifest) {
 
@@ -406,64 +600,34 @@ func Exists(manifestPath string) bool {
 // ReadManifestFromFile reads the .estafette.yaml into an EstafetteManifest object
 func ReadManifestFromFile(preferences *EstafetteManifestPreferences, manifestPath string, validate bool) (manifest EstafetteManifest, err error) {
 
-	log.Debug().Msgf("Reading %v file...", manifestPath)
-
-	// default preferences if not passed
-	if preferences == nil {
-		preferences = GetDefaultManifestPreferences()
-	}
-
-	data, err := ioutil.ReadFile(manifestPath)
-	if err != nil {
-		return manifest, err
-	}
-
-	// unmarshal strict, so non-defined properties or incorrect nesting will fail
-	if err := yaml.UnmarshalStrict(data, &manifest); err != nil {
-		return manifest, err
-	}
-
-	// set defaults
-	manifest.SetDefaults(*preferences)
-
-	if validate {
-		// check if manifest is valid
-		err = manifest.Validate(*preferences)
-		if err != nil {
-			return manifest, err
+	// the manifest's directory is the root for the files it includes, however the path is passed
+	dir := filepath.Dir(manifestPath)
+
+	manifest, err = ReadManifestWithOptions(nil, ReadOptions{
+		Preferences:    preferences,
+		SkipValidation: !validate,
+		File:           filepath.Base(manifestPath),
+		FS:             os.DirFS(dir),
+	})
+
+	// error positions show the path of the files as passed
+	if errs, ok := err.(ValidationErrors); ok && dir != "." {
+		for _, e := range errs {
+			if e.Position != nil && e.Position.File != "" {
+				e.Position.File = filepath.Join(dir, filepath.FromSlash(e.Position.File))
+			}
 		}
 	}
 
-	log.Debug().Msgf("Finished reading %v file successfully", manifestPath)
-
-	return
+	return manifest, err
 }
 
 // ReadManifest reads the string representation of .estafette.yaml into an EstafetteManifest object
 func ReadManifest(preferences *EstafetteManifestPreferences, manifestString string, validate bool) (manifest EstafetteManifest, err error) {
-
-	// default preferences if not passed
-	if preferences == nil {
-		preferences = GetDefaultManifestPreferences()
-	}
-
-	// unmarshal strict, so non-defined properties or incorrect nesting will fail
-	if err := yaml.UnmarshalStrict([]byte(manifestString), &manifest); err != nil {
-		return manifest, err
-	}
-
-	// set defaults
-	manifest.SetDefaults(*preferences)
-
-	if validate {
-		// check if manifest is valid
-		err = manifest.Validate(*preferences)
-		if err != nil {
-			return manifest, err
-		}
-	}
-
-	return
+	return ReadManifestWithOptions(strings.NewReader(manifestString), ReadOptions{
+		Preferences:    preferences,
+		SkipValidation: !validate,
+	})
 }
 
 // GetDefaultManifestPreferences returns default preferences if not configured at the server
@@ -1,11 +1,23 @@
 package manifest
 
+import (
+	"sort"
+)
+
 // EstafetteManifestPreferences is used to configure validation rules for the manifest
 type EstafetteManifestPreferences struct {
 	LabelRegexes                    map[string]string            `yaml:"labelRegexes,omitempty" json:"labelRegexes,omitempty"`
 	BuilderOperatingSystems         []OperatingSystem            `yaml:"builderOperatingSystems,omitempty" json:"builderOperatingSystems,omitempty"`
 	BuilderTracksPerOperatingSystem map[OperatingSystem][]string `yaml:"builderTracksPerOperatingSystem,omitempty" json:"builderTracksPerOperatingSystem,omitempty"`
 	DefaultBranch                   string                       `yaml:"defaultBranch,omitempty" json:"defaultBranch,omitempty"`
+	LintRules                       map[string]LintSeverity      `yaml:"lintRules,omitempty" json:"lintRules,omitempty"`
+	MaxMatrixSize                   int                          `yaml:"maxMatrixSize,omitempty" json:"maxMatrixSize,omitempty"`
+	MaxTimeout                      *Duration                    `yaml:"maxTimeout,omitempty" json:"maxTimeout,omitempty"`
+	MaxRetries                      int                          `yaml:"maxRetries,omitempty" json:"maxRetries,omitempty"`
+	DefaultCPU                      *EstafetteResource           `yaml:"defaultCpu,omitempty" json:"defaultCpu,omitempty"`
+	DefaultMemory                   *EstafetteResource           `yaml:"defaultMemory,omitempty" json:"defaultMemory,omitempty"`
+	MaxCPU                          Quantity                     `yaml:"maxCpu,omitempty" json:"maxCpu,omitempty"`
+	MaxMemory                       Quantity                     `yaml:"maxMemory,omitempty" json:"maxMemory,omitempty"`
 }
 
 func (p *EstafetteManifestPreferences) SetDefaults() {
@@ -27,4 +39,30 @@ func (p *EstafetteManifestPreferences) SetDefaults() {
 	if p.DefaultBranch == "" {
 		p.DefaultBranch = "master"
 	}
+
+	if p.MaxMatrixSize == 0 {
+		p.MaxMatrixSize = 16
+	}
+}
+
+// Validate checks whether the preferences are valid, like lint rule overrides using a known severity
+func (p *EstafetteManifestPreferences) Validate() (err error) {
+
+	var errs ValidationErrors
+
+	ids := make([]string, 0, len(p.LintRules))
+	for id := range p.LintRules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		switch p.LintRules[id] {
+		case LintSeverityOff, LintSeverityInfo, LintSeverityWarning, LintSeverityError:
+		default:
+			errs.addf(joinPath("lintRules", id), "Lint rule %v has unknown severity %v, use %v, %v, %v or %v", id, p.LintRules[id], LintSeverityOff, LintSeverityInfo, LintSeverityWarning, LintSeverityError)
+		}
+	}
+
+	return errs.errorOrNil()
 }
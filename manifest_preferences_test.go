@@ -0,0 +1,41 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstafetteManifestPreferencesValidate(t *testing.T) {
+	t.Run("ReturnsNoErrorForDefaultPreferencesWithKnownLintSeverities", func(t *testing.T) {
+
+		preferences := GetDefaultManifestPreferences()
+		preferences.LintRules = map[string]LintSeverity{
+			"deprecated-pipelines": LintSeverityOff,
+			"latest-image-tag":     LintSeverityError,
+		}
+
+		// act
+		err := preferences.Validate()
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorForUnknownLintSeverity", func(t *testing.T) {
+
+		preferences := GetDefaultManifestPreferences()
+		preferences.LintRules = map[string]LintSeverity{
+			"latest-image-tag": "fatal",
+		}
+
+		// act
+		err := preferences.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			assert.Equal(t, 1, len(errs))
+			assert.Equal(t, "lintRules.latest-image-tag", errs[0].Path)
+			assert.Equal(t, "Lint rule latest-image-tag has unknown severity fatal, use off, info, warning or error", errs[0].Message)
+		}
+	})
+}
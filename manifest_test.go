@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	yaml "gopkg.in/yaml.v2"
@@ -864,7 +865,7 @@ stages:
 		output, err := json.Marshal(manifest)
 
 		if assert.Nil(t, err) {
-			assert.Equal(t, "{\"Archived\":false,\"Builder\":{\"Track\":\"stable\",\"OperatingSystem\":\"windows\",\"StorageMedium\":\"\",\"BuilderType\":\"docker\"},\"Labels\":{\"app\":\"estafette-ci-builder\",\"language\":\"golang\",\"team\":\"estafette-team\"},\"Version\":{\"SemVer\":{\"Major\":0,\"Minor\":0,\"Patch\":\"{{auto}}\",\"LabelTemplate\":\"{{branch}}\",\"ReleaseBranch\":\"main\"}},\"GlobalEnvVars\":null,\"Triggers\":null,\"Stages\":[{\"Name\":\"test-alpha-version\",\"ContainerImage\":\"extensions/gke:${ESTAFETTE_BUILD_VERSION}\",\"Shell\":\"powershell\",\"WorkingDirectory\":\"C:/estafette-work\",\"When\":\"status == 'succeeded'\",\"CustomProperties\":{\"app\":\"gke\",\"container\":{\"name\":\"gke\",\"repository\":\"extensions\",\"tag\":\"alpha\"},\"cpu\":{\"limit\":\"100m\",\"request\":\"100m\"},\"credentials\":\"gke-tooling\",\"dryrun\":true,\"memory\":{\"limit\":\"256Mi\",\"request\":\"256Mi\"},\"namespace\":\"estafette\",\"visibility\":\"private\"}}],\"Releases\":null,\"ReleaseTemplates\":null,\"Bots\":null}", string(output))
+			assert.Equal(t, "{\"Archived\":false,\"Builder\":{\"Track\":\"stable\",\"OperatingSystem\":\"windows\",\"StorageMedium\":\"\",\"BuilderType\":\"docker\"},\"Labels\":{\"app\":\"estafette-ci-builder\",\"language\":\"golang\",\"team\":\"estafette-team\"},\"Version\":{\"SemVer\":{\"Major\":0,\"Minor\":0,\"Patch\":\"{{auto}}\",\"LabelTemplate\":\"{{branch}}\",\"ReleaseBranch\":\"main\"}},\"GlobalEnvVars\":null,\"Triggers\":null,\"Stages\":[{\"Name\":\"test-alpha-version\",\"ContainerImage\":\"extensions/gke:${ESTAFETTE_BUILD_VERSION}\",\"Shell\":\"powershell\",\"WorkingDirectory\":\"C:/estafette-work\",\"When\":\"status == 'succeeded'\",\"CPU\":{\"Request\":\"100m\",\"Limit\":\"100m\"},\"Memory\":{\"Request\":\"256Mi\",\"Limit\":\"256Mi\"},\"CustomProperties\":{\"app\":\"gke\",\"container\":{\"name\":\"gke\",\"repository\":\"extensions\",\"tag\":\"alpha\"},\"credentials\":\"gke-tooling\",\"dryrun\":true,\"namespace\":\"estafette\",\"visibility\":\"private\"}}],\"Releases\":null,\"ReleaseTemplates\":null,\"Bots\":null}", string(output))
 		}
 	})
 }
@@ -1111,6 +1112,124 @@ func TestValidate(t *testing.T) {
 
 		assert.Nil(t, err)
 	})
+
+	t.Run("ReturnsAllValidationErrorsInsteadOfStoppingAtTheFirst", func(t *testing.T) {
+
+		manifest := EstafetteManifest{
+			Builder: EstafetteBuilder{
+				Track: "nightly",
+			},
+			Stages: []*EstafetteStage{
+				{
+					Name: "build",
+				},
+			},
+			Releases: []*EstafetteRelease{
+				{
+					Name: "production",
+					Triggers: []*EstafetteTrigger{
+						{
+							Cron: &EstafetteCronTrigger{
+								Schedule: "0 10 * * *",
+							},
+						},
+						{
+							Pipeline: &EstafettePipelineTrigger{
+								Name:   "self",
+								Status: "unknown",
+							},
+						},
+					},
+					Stages: []*EstafetteStage{
+						{
+							Name:           "deploy",
+							ContainerImage: "extensions/gke:stable",
+						},
+					},
+				},
+			},
+		}
+		manifest.SetDefaults(*GetDefaultManifestPreferences())
+
+		// act
+		err := manifest.Validate(*GetDefaultManifestPreferences())
+
+		if assert.NotNil(t, err) {
+			validationErrors, ok := err.(ValidationErrors)
+			if assert.True(t, ok) && assert.Equal(t, 4, len(validationErrors)) {
+				assert.Equal(t, "builder.track", validationErrors[0].Path)
+				assert.Equal(t, "stages.build.image", validationErrors[1].Path)
+				assert.Equal(t, "releases.production.builder.track", validationErrors[2].Path)
+				assert.Equal(t, "releases.production.triggers[1].pipeline.status", validationErrors[3].Path)
+			}
+		}
+	})
+
+	t.Run("ReturnsValidationErrorsForParallelStagesAndServices", func(t *testing.T) {
+
+		manifest := EstafetteManifest{
+			Stages: []*EstafetteStage{
+				{
+					Name: "parallel",
+					ParallelStages: []*EstafetteStage{
+						{
+							Name: "stageA",
+						},
+					},
+				},
+				{
+					Name:           "integration-test",
+					ContainerImage: "golang",
+					Services: []*EstafetteService{
+						{
+							Name: "database",
+						},
+					},
+				},
+			},
+		}
+		manifest.SetDefaults(*GetDefaultManifestPreferences())
+
+		// act
+		err := manifest.Validate(*GetDefaultManifestPreferences())
+
+		if assert.NotNil(t, err) {
+			validationErrors, ok := err.(ValidationErrors)
+			if assert.True(t, ok) && assert.Equal(t, 2, len(validationErrors)) {
+				assert.Equal(t, "stages.parallel.parallelStages.stageA.image", validationErrors[0].Path)
+				assert.Equal(t, "stages.integration-test.services[0].image", validationErrors[1].Path)
+			}
+		}
+	})
+
+	t.Run("ReturnsValidationErrorsForLabelsNotMatchingRegexes", func(t *testing.T) {
+
+		preferences := GetDefaultManifestPreferences()
+		preferences.LabelRegexes["team"] = "estafette-.+"
+
+		manifest := EstafetteManifest{
+			Labels: map[string]string{
+				"team": "other",
+			},
+			Stages: []*EstafetteStage{
+				{
+					ContainerImage: "docker",
+				},
+			},
+		}
+		manifest.SetDefaults(*preferences)
+
+		// act
+		err := manifest.Validate(*preferences)
+
+		if assert.NotNil(t, err) {
+			validationErrors, ok := err.(ValidationErrors)
+			if assert.True(t, ok) && assert.Equal(t, 1, len(validationErrors)) {
+				assert.Equal(t, "labels.team", validationErrors[0].Path)
+				assert.Equal(t, "labels.team: Label team does not match regex ^estafette-.+$", validationErrors[0].Error())
+			}
+		}
+	})
 }
 
 func TestDeepCopy(t *testing.T) {
@@ -1128,3 +1247,212 @@ func TestDeepCopy(t *testing.T) {
 		assert.NotSame(t, manifest.Stages[0], copiedManifest.Stages[0])
 	})
 }
+
+const matchEventManifest = `
+triggers:
+- pipeline:
+    name: github.com/estafette/estafette-ci-builder
+  builds:
+    branch: main
+- manual:
+    users:
+    - jorrit@estafette.io
+stages:
+  build:
+    image: golang:1.17-alpine
+releases:
+  development:
+    triggers:
+    - pipeline:
+        name: self
+        branch: main
+    - manual: {}
+    stages:
+      deploy:
+        image: extensions/gke:stable
+  production:
+    triggers:
+    - pipeline:
+        name: self
+        branch: release
+    - manual: {}
+    stages:
+      deploy:
+        image: extensions/gke:stable
+bots:
+  pr-bot:
+    triggers:
+    - pipeline:
+        name: github.com/estafette/estafette-ci-builder
+    stages:
+      welcome:
+        image: extensions/greeter:stable`
+
+func TestMatchEvent(t *testing.T) {
+	t.Run("ReturnsActionsForAllTriggersThatFire", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, matchEventManifest, true)
+		assert.Nil(t, err)
+
+		event := &EstafetteEvent{
+			Pipeline: &EstafettePipelineEvent{
+				BuildVersion: "1.0.5",
+				RepoSource:   "github.com",
+				RepoOwner:    "estafette",
+				RepoName:     "estafette-ci-builder",
+				Branch:       "main",
+				Status:       "succeeded",
+				Event:        "finished",
+			},
+		}
+
+		// act
+		matches := manifest.MatchEvent(event, "github.com/estafette/estafette-ci-manifest")
+
+		if assert.Equal(t, 2, len(matches)) {
+			assert.Equal(t, TriggerTypeBuild, matches[0].TriggerType)
+			assert.Equal(t, manifest.Triggers[0], matches[0].Trigger)
+			assert.Equal(t, &EstafetteTriggerBuildAction{Branch: "main"}, matches[0].BuildAction)
+			assert.Nil(t, matches[0].ReleaseAction)
+			assert.Equal(t, TriggerTypeBot, matches[1].TriggerType)
+			assert.Equal(t, &EstafetteTriggerBotAction{Bot: "pr-bot", Branch: "master"}, matches[1].BotAction)
+		}
+	})
+
+	t.Run("ReturnsReleaseActionWithSameVersionResolvedToTheBuildVersionForSelfTrigger", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, matchEventManifest, true)
+		assert.Nil(t, err)
+
+		event := &EstafetteEvent{
+			Pipeline: &EstafettePipelineEvent{
+				BuildVersion: "2.3.4",
+				RepoSource:   "github.com",
+				RepoOwner:    "estafette",
+				RepoName:     "estafette-ci-manifest",
+				Branch:       "main",
+				Status:       "succeeded",
+				Event:        "finished",
+			},
+		}
+
+		// act
+		matches := manifest.MatchEvent(event, "github.com/estafette/estafette-ci-manifest")
+
+		if assert.Equal(t, 1, len(matches)) {
+			assert.Equal(t, TriggerTypeRelease, matches[0].TriggerType)
+			assert.Equal(t, &EstafetteTriggerReleaseAction{Target: "development", Version: "2.3.4"}, matches[0].ReleaseAction)
+			// the trigger itself still holds the unresolved version
+			assert.Equal(t, "same", manifest.Releases[0].Triggers[0].ReleaseAction.Version)
+		}
+	})
+
+	t.Run("DoesNotReplaceSelfInTheTriggersOfTheManifest", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, matchEventManifest, true)
+		assert.Nil(t, err)
+
+		eventFor := func(repoName string) *EstafetteEvent {
+			return &EstafetteEvent{
+				Pipeline: &EstafettePipelineEvent{
+					BuildVersion: "2.3.4",
+					RepoSource:   "github.com",
+					RepoOwner:    "estafette",
+					RepoName:     repoName,
+					Branch:       "main",
+					Status:       "succeeded",
+					Event:        "finished",
+				},
+			}
+		}
+
+		// act
+		first := manifest.MatchEvent(eventFor("estafette-ci-manifest"), "github.com/estafette/estafette-ci-manifest")
+		second := manifest.MatchEvent(eventFor("estafette-ci-manifest-fork"), "github.com/estafette/estafette-ci-manifest-fork")
+
+		assert.Equal(t, 1, len(first))
+		if assert.Equal(t, 1, len(second)) {
+			assert.Equal(t, "github.com/estafette/estafette-ci-manifest-fork", second[0].Trigger.Pipeline.Name)
+		}
+		assert.Equal(t, "self", manifest.Releases[0].Triggers[0].Pipeline.Name)
+	})
+
+	t.Run("ReturnsActionForTheReleaseTargetedByManualEventWithRequestedVersion", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, matchEventManifest, true)
+		assert.Nil(t, err)
+
+		event := &EstafetteEvent{
+			Manual: &EstafetteManualEvent{
+				UserID:     "someone@estafette.io",
+				TargetType: TriggerTypeRelease,
+				Target:     "production",
+				Version:    "1.0.5",
+			},
+		}
+
+		// act
+		matches := manifest.MatchEvent(event, "github.com/estafette/estafette-ci-manifest")
+
+		if assert.Equal(t, 1, len(matches)) {
+			assert.Equal(t, TriggerTypeRelease, matches[0].TriggerType)
+			assert.Equal(t, &EstafetteTriggerReleaseAction{Target: "production", Version: "1.0.5"}, matches[0].ReleaseAction)
+		}
+	})
+
+	t.Run("ReturnsBuildActionWithRequestedBranchForManualEventByAllowedUser", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, matchEventManifest, true)
+		assert.Nil(t, err)
+
+		event := &EstafetteEvent{
+			Manual: &EstafetteManualEvent{
+				UserID:     "jorrit@estafette.io",
+				TargetType: TriggerTypeBuild,
+				Branch:     "feature-x",
+			},
+		}
+
+		// act
+		matches := manifest.MatchEvent(event, "github.com/estafette/estafette-ci-manifest")
+
+		if assert.Equal(t, 1, len(matches)) {
+			assert.Equal(t, TriggerTypeBuild, matches[0].TriggerType)
+			assert.Equal(t, &EstafetteTriggerBuildAction{Branch: "feature-x"}, matches[0].BuildAction)
+		}
+	})
+
+	t.Run("ReturnsEmptyListForManualEventWithoutTargetOrForTargetWithoutManualTrigger", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, matchEventManifest, true)
+		assert.Nil(t, err)
+
+		for _, manual := range []*EstafetteManualEvent{
+			{UserID: "jorrit@estafette.io"},
+			{UserID: "jorrit@estafette.io", TargetType: TriggerTypeBot, Target: "pr-bot"},
+			{UserID: "someone@estafette.io", TargetType: TriggerTypeBuild},
+		} {
+			// act
+			matches := manifest.MatchEvent(&EstafetteEvent{Manual: manual}, "github.com/estafette/estafette-ci-manifest")
+
+			assert.Equal(t, 0, len(matches))
+		}
+	})
+
+	t.Run("ReturnsEmptyListIfNoTriggerFires", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, matchEventManifest, true)
+		assert.Nil(t, err)
+
+		event := &EstafetteEvent{
+			Cron: &EstafetteCronEvent{
+				Time: time.Date(2021, 11, 3, 12, 0, 0, 0, time.UTC),
+			},
+		}
+
+		// act
+		matches := manifest.MatchEvent(event, "github.com/estafette/estafette-ci-manifest")
+
+		assert.Equal(t, 0, len(matches))
+	})
+}
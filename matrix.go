@@ -0,0 +1,380 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/copier"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EstafetteMatrix expands a stage into a parallel stage for every combination of the values of its axes; include adds combinations and
+// exclude removes the combinations matching all of its values
+type EstafetteMatrix struct {
+	Axes    []*EstafetteMatrixAxis        `yaml:"-" json:",omitempty"`
+	Include []*EstafetteMatrixCombination `yaml:"include,omitempty" json:",omitempty"`
+	Exclude []*EstafetteMatrixCombination `yaml:"exclude,omitempty" json:",omitempty"`
+}
+
+// EstafetteMatrixAxis is a named list of values in a matrix
+type EstafetteMatrixAxis struct {
+	Name   string   `yaml:"-"`
+	Values []string `yaml:"values,omitempty" json:",omitempty"`
+}
+
+// EstafetteMatrixCombination holds a value per axis; in the manifest it's a mapping of axis names to values
+type EstafetteMatrixCombination struct {
+	Values map[string]string
+}
+
+// UnmarshalYAML customizes unmarshalling an EstafetteMatrixCombination
+func (combination *EstafetteMatrixCombination) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
+	return unmarshal(&combination.Values)
+}
+
+// MarshalYAML customizes marshalling an EstafetteMatrixCombination
+func (combination EstafetteMatrixCombination) MarshalYAML() (out interface{}, err error) {
+	return combination.Values, nil
+}
+
+// MarshalJSON customizes marshalling an EstafetteMatrixCombination to json, so it looks the same as in the manifest
+func (combination EstafetteMatrixCombination) MarshalJSON() ([]byte, error) {
+	return json.Marshal(combination.Values)
+}
+
+var (
+	matrixAxisNameRegex     = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	matrixPlaceholderRegex  = regexp.MustCompile(`\$\{\{\s*matrix\.([a-zA-Z0-9_-]+)\s*\}\}`)
+	matrixStageNameRegex    = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+	matrixEnvVarInvalidChar = regexp.MustCompile(`[^A-Z0-9_]`)
+)
+
+// UnmarshalYAML customizes unmarshalling an EstafetteMatrix
+func (matrix *EstafetteMatrix) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
+
+	// axes are all keys except include and exclude, so keep their order by unmarshalling to a map slice
+	var aux yaml.MapSlice
+	if err := unmarshal(&aux); err != nil {
+		return err
+	}
+
+	for _, mi := range aux {
+
+		bytes, err := yaml.Marshal(mi.Value)
+		if err != nil {
+			return wrapUnmarshalError(fmt.Sprintf("%v", mi.Key), err)
+		}
+
+		switch mi.Key {
+		case "include":
+			if err := yaml.Unmarshal(bytes, &matrix.Include); err != nil {
+				return wrapUnmarshalError("include", err)
+			}
+		case "exclude":
+			if err := yaml.Unmarshal(bytes, &matrix.Exclude); err != nil {
+				return wrapUnmarshalError("exclude", err)
+			}
+		default:
+			axis := &EstafetteMatrixAxis{Name: fmt.Sprintf("%v", mi.Key)}
+			if err := yaml.Unmarshal(bytes, &axis.Values); err != nil {
+				return wrapUnmarshalError(axis.Name, err)
+			}
+			matrix.Axes = append(matrix.Axes, axis)
+		}
+	}
+
+	return nil
+}
+
+// MarshalYAML customizes marshalling an EstafetteMatrix
+func (matrix EstafetteMatrix) MarshalYAML() (out interface{}, err error) {
+
+	var aux yaml.MapSlice
+
+	for _, axis := range matrix.Axes {
+		aux = append(aux, yaml.MapItem{Key: axis.Name, Value: axis.Values})
+	}
+	if len(matrix.Include) > 0 {
+		aux = append(aux, yaml.MapItem{Key: "include", Value: matrix.Include})
+	}
+	if len(matrix.Exclude) > 0 {
+		aux = append(aux, yaml.MapItem{Key: "exclude", Value: matrix.Exclude})
+	}
+
+	return aux, nil
+}
+
+// Combinations returns all combinations of axis values, minus the excluded ones and followed by the included ones that aren't in there yet
+func (matrix *EstafetteMatrix) Combinations() (combinations []map[string]string) {
+
+	if len(matrix.Axes) > 0 {
+		combinations = []map[string]string{{}}
+	}
+	for _, axis := range matrix.Axes {
+		var expanded []map[string]string
+		for _, c := range combinations {
+			for _, v := range axis.Values {
+				combination := map[string]string{}
+				for key, value := range c {
+					combination[key] = value
+				}
+				combination[axis.Name] = v
+				expanded = append(expanded, combination)
+			}
+		}
+		combinations = expanded
+	}
+
+	var kept []map[string]string
+	for _, c := range combinations {
+		excluded := false
+		for _, e := range matrix.Exclude {
+			if matrixCombinationMatches(c, e.Values) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, c)
+		}
+	}
+
+	for _, i := range matrix.Include {
+		found := false
+		for _, c := range kept {
+			if matrixCombinationMatches(c, i.Values) && len(c) == len(i.Values) {
+				found = true
+				break
+			}
+		}
+		if !found && len(i.Values) > 0 {
+			include := map[string]string{}
+			for key, value := range i.Values {
+				include[key] = value
+			}
+			kept = append(kept, include)
+		}
+	}
+
+	return kept
+}
+
+// maxMatrixCombinationsToBuild is the number of axis combinations above which a matrix's size is estimated instead of counted, since excludes
+// can't realistically bring that many combinations under the maximum matrix size
+const maxMatrixCombinationsToBuild = 100000
+
+// size returns the number of combinations of the matrix; for matrices with more than maxMatrixCombinationsToBuild axis combinations it
+// returns that number plus the includes, without building all of them
+func (matrix *EstafetteMatrix) size() int {
+
+	if len(matrix.Axes) == 0 {
+		return len(matrix.Combinations())
+	}
+
+	product := 1
+	for _, axis := range matrix.Axes {
+		product *= len(axis.Values)
+		if product > maxMatrixCombinationsToBuild {
+			return maxMatrixCombinationsToBuild + 1 + len(matrix.Include)
+		}
+	}
+
+	return len(matrix.Combinations())
+}
+
+// validate checks the axes, that include and exclude only use known axes and that every combination expands to a stage with its own name
+func (matrix *EstafetteMatrix) validate(stageName string) (err error) {
+
+	var errs ValidationErrors
+
+	axes := map[string]bool{}
+	hasDuplicateValues := false
+	for _, axis := range matrix.Axes {
+		axes[axis.Name] = true
+		if !matrixAxisNameRegex.MatchString(axis.Name) {
+			errs.addf(axis.Name, "Matrix axis %v should only contain letters, digits, dashes and underscores", axis.Name)
+		}
+		if len(axis.Values) == 0 {
+			errs.addf(axis.Name, "Matrix axis %v has no values", axis.Name)
+		}
+		values := map[string]bool{}
+		for _, value := range axis.Values {
+			if values[value] {
+				errs.addf(axis.Name, "Matrix axis %v has duplicate value %v", axis.Name, value)
+				hasDuplicateValues = true
+			}
+			values[value] = true
+		}
+	}
+
+	for i, e := range matrix.Exclude {
+		for _, key := range sortedKeys(e.Values) {
+			if !axes[key] {
+				errs.addf(fmt.Sprintf("exclude[%v].%v", i, key), "Matrix exclude uses unknown axis %v", key)
+			}
+		}
+	}
+
+	size := matrix.size()
+	if size == 0 {
+		errs.addf("", "Matrix has no combinations")
+	}
+
+	// different combinations can end up with the same stage name once invalid characters are replaced, which would merge their stages;
+	// duplicate values already got reported above
+	if !hasDuplicateValues && size <= maxMatrixCombinationsToBuild {
+		expandedBy := map[string]map[string]string{}
+		for _, combination := range matrix.Combinations() {
+			name := matrix.stageName(stageName, combination)
+			if other, exists := expandedBy[name]; exists {
+				errs.addf("", "Matrix combinations %v and %v both expand to stage %v", matrix.combinationString(other), matrix.combinationString(combination), name)
+				continue
+			}
+			expandedBy[name] = combination
+		}
+	}
+
+	return errs.errorOrNil()
+}
+
+// expandMatrix turns a stage with a matrix into a stage with a parallel stage per combination; each of them is a copy of the stage with
+// the combination's values as env vars and substituted for ${{ matrix.<axis> }} in its image, commands, env and custom properties; matrices
+// with more than maxSize combinations aren't expanded
+func (stage *EstafetteStage) expandMatrix(maxSize int) {
+
+	if stage.Matrix == nil || len(stage.ParallelStages) > 0 {
+		return
+	}
+
+	// Validate reports matrices that are too large, without combinations or with combinations expanding to the same stage name, leave the
+	// stage as is for those
+	if maxSize > 0 && stage.Matrix.size() > maxSize {
+		return
+	}
+	if stage.Matrix.validate(stage.Name) != nil {
+		return
+	}
+	combinations := stage.Matrix.Combinations()
+
+	var base EstafetteStage
+	copier.CopyWithOption(&base, stage, copier.Option{IgnoreEmpty: true, DeepCopy: true})
+	base.Matrix = nil
+	base.DependsOn = nil
+
+	var parallelStages []*EstafetteStage
+	for _, combination := range combinations {
+
+		var inner EstafetteStage
+		copier.CopyWithOption(&inner, base, copier.Option{IgnoreEmpty: true, DeepCopy: true})
+		inner.MatrixValues = combination
+
+		keys := stage.Matrix.keys(combination)
+		inner.Name = stage.Matrix.stageName(stage.Name, combination)
+
+		replace := func(s string) string {
+			return matrixPlaceholderRegex.ReplaceAllStringFunc(s, func(match string) string {
+				if value, ok := combination[matrixPlaceholderRegex.FindStringSubmatch(match)[1]]; ok {
+					return value
+				}
+				return match
+			})
+		}
+		inner.ContainerImage = replace(inner.ContainerImage)
+		for i, c := range inner.Commands {
+			inner.Commands[i] = replace(c)
+		}
+		envVars := map[string]string{}
+		for key, value := range inner.EnvVars {
+			envVars[key] = replace(value)
+		}
+		for _, key := range keys {
+			envVars[matrixEnvVarName(key)] = combination[key]
+		}
+		inner.EnvVars = envVars
+		if inner.CustomProperties != nil {
+			inner.CustomProperties = substituteValue(inner.CustomProperties, replace).(map[string]interface{})
+		}
+
+		parallelStages = append(parallelStages, &inner)
+	}
+	stage.ParallelStages = parallelStages
+
+	// everything moved to the parallel stages, the stage itself just groups them
+	stage.ContainerImage = ""
+	stage.Shell = ""
+	stage.WorkingDirectory = ""
+	stage.Commands = nil
+	stage.RunCommandsInForeground = false
+	stage.EnvVars = nil
+	stage.Services = nil
+	stage.CustomProperties = nil
+}
+
+// keys returns the keys of combination, axes in the order they're defined followed by keys only set by an include in alphabetical order
+func (matrix *EstafetteMatrix) keys(combination map[string]string) (keys []string) {
+
+	seen := map[string]bool{}
+	for _, axis := range matrix.Axes {
+		if _, ok := combination[axis.Name]; ok {
+			keys = append(keys, axis.Name)
+			seen[axis.Name] = true
+		}
+	}
+	for _, key := range sortedKeys(combination) {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// stageName returns the name of the parallel stage a combination expands to, the stage name followed by the values of the combination with
+// characters that aren't valid in a stage name replaced by dashes
+func (matrix *EstafetteMatrix) stageName(stageName string, combination map[string]string) string {
+
+	nameParts := []string{stageName}
+	for _, key := range matrix.keys(combination) {
+		nameParts = append(nameParts, combination[key])
+	}
+
+	return strings.Trim(matrixStageNameRegex.ReplaceAllString(strings.Join(nameParts, "-"), "-"), "-")
+}
+
+// combinationString returns a combination as key=value pairs in the order of matrix.keys, for use in errors
+func (matrix *EstafetteMatrix) combinationString(combination map[string]string) string {
+
+	pairs := []string{}
+	for _, key := range matrix.keys(combination) {
+		pairs = append(pairs, fmt.Sprintf("%v=%v", key, combination[key]))
+	}
+
+	return strings.Join(pairs, ", ")
+}
+
+// matrixEnvVarName returns the name of the env var holding the value of an axis, like MATRIX_GO_VERSION for go-version
+func matrixEnvVarName(axis string) string {
+	return "MATRIX_" + matrixEnvVarInvalidChar.ReplaceAllString(strings.ToUpper(axis), "_")
+}
+
+// matrixCombinationMatches returns whether combination has all values of match
+func matrixCombinationMatches(combination, match map[string]string) bool {
+	for key, value := range match {
+		if combination[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,256 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const matrixManifest = `
+stages:
+  build:
+    image: golang:1.17-alpine
+  test:
+    image: golang:${{ matrix.go }}-alpine
+    commands:
+    - go test ./...
+    env:
+      GOOS: ${{ matrix.os }}
+    matrix:
+      go: ["1.16", "1.17"]
+      os: [linux, windows]
+      include:
+      - go: "1.18"
+        os: linux
+        experimental: "true"
+      exclude:
+      - go: "1.16"
+        os: windows
+  push:
+    image: extensions/docker:stable
+`
+
+func TestMatrix(t *testing.T) {
+	t.Run("UnmarshalsAxesInOrderNextToIncludeAndExclude", func(t *testing.T) {
+
+		var manifest EstafetteManifest
+
+		// act
+		err := yaml.Unmarshal([]byte(matrixManifest), &manifest)
+
+		assert.Nil(t, err)
+		matrix := manifest.Stages[1].Matrix
+		if assert.NotNil(t, matrix) && assert.Equal(t, 2, len(matrix.Axes)) {
+			assert.Equal(t, "go", matrix.Axes[0].Name)
+			assert.Equal(t, []string{"1.16", "1.17"}, matrix.Axes[0].Values)
+			assert.Equal(t, "os", matrix.Axes[1].Name)
+			assert.Equal(t, 1, len(matrix.Include))
+			assert.Equal(t, map[string]string{"go": "1.16", "os": "windows"}, matrix.Exclude[0].Values)
+		}
+	})
+
+	t.Run("ReturnsCombinationsWithoutExcludedAndWithIncluded", func(t *testing.T) {
+
+		var manifest EstafetteManifest
+		err := yaml.Unmarshal([]byte(matrixManifest), &manifest)
+		assert.Nil(t, err)
+
+		// act
+		combinations := manifest.Stages[1].Matrix.Combinations()
+
+		assert.Equal(t, []map[string]string{
+			{"go": "1.16", "os": "linux"},
+			{"go": "1.17", "os": "linux"},
+			{"go": "1.17", "os": "windows"},
+			{"go": "1.18", "os": "linux", "experimental": "true"},
+		}, combinations)
+	})
+
+	t.Run("ExpandsIntoParallelStagesWhenSettingDefaults", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, matrixManifest, true)
+
+		assert.Nil(t, err)
+		stage := manifest.Stages[1]
+		assert.Equal(t, "", stage.ContainerImage)
+		assert.Equal(t, 0, len(stage.Commands))
+		if assert.Equal(t, 4, len(stage.ParallelStages)) {
+			assert.Equal(t, "test-1.16-linux", stage.ParallelStages[0].Name)
+			assert.Equal(t, "test-1.17-linux", stage.ParallelStages[1].Name)
+			assert.Equal(t, "test-1.17-windows", stage.ParallelStages[2].Name)
+			assert.Equal(t, "test-1.18-linux-true", stage.ParallelStages[3].Name)
+
+			inner := stage.ParallelStages[2]
+			assert.Equal(t, "golang:1.17-alpine", inner.ContainerImage)
+			assert.Equal(t, []string{"go test ./..."}, inner.Commands)
+			assert.Equal(t, map[string]string{"GOOS": "windows", "MATRIX_GO": "1.17", "MATRIX_OS": "windows"}, inner.EnvVars)
+			assert.Equal(t, map[string]string{"go": "1.17", "os": "windows"}, inner.MatrixValues)
+			assert.Equal(t, "/bin/sh", inner.Shell)
+			assert.Nil(t, inner.Matrix)
+
+			assert.Equal(t, "true", stage.ParallelStages[3].EnvVars["MATRIX_EXPERIMENTAL"])
+		}
+	})
+
+	t.Run("ExpandsOnlyOnce", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, matrixManifest, true)
+		assert.Nil(t, err)
+
+		// act
+		manifest.SetDefaults(*GetDefaultManifestPreferences())
+
+		assert.Equal(t, 4, len(manifest.Stages[1].ParallelStages))
+	})
+
+	t.Run("AddsExpandedStagesToTheStageGraph", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, matrixManifest, true)
+		assert.Nil(t, err)
+
+		// act
+		graph, err := manifest.StageGraph()
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"test/test-1.16-linux", "test/test-1.17-linux", "test/test-1.17-windows", "test/test-1.18-linux-true"}, graph.Node("push").DependsOn)
+	})
+}
+
+func TestValidateMatrix(t *testing.T) {
+	t.Run("ReturnsErrorIfMatrixIsLargerThanTheMaximum", func(t *testing.T) {
+
+		preferences := GetDefaultManifestPreferences()
+		preferences.MaxMatrixSize = 3
+
+		// act
+		_, err := ReadManifest(preferences, matrixManifest, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "stages.test.matrix", errs[0].Path)
+				assert.Equal(t, "Matrix of stage test has 4 combinations, more than the maximum of 3", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorWithoutExpandingIfMatrixIsFarLargerThanTheMaximum", func(t *testing.T) {
+
+		values := `["0", "1", "2", "3", "4", "5", "6", "7", "8", "9"]`
+
+		// act
+		manifest, err := ReadManifest(nil, `
+stages:
+  test:
+    image: golang:1.17-alpine
+    matrix:
+      a: `+values+`
+      b: `+values+`
+      c: `+values+`
+      d: `+values+`
+      e: `+values+`
+      f: `+values, false)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(manifest.Stages[0].ParallelStages))
+
+		// act
+		err = manifest.Validate(*GetDefaultManifestPreferences())
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "stages.test.matrix", errs[0].Path)
+				assert.Equal(t, "Matrix of stage test has over 100000 combinations, more than the maximum of 16", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorsForEmptyAxisAndUnknownExcludeAxis", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  test:
+    image: golang:1.17-alpine
+    matrix:
+      go: ["1.16", "1.17"]
+      os: []
+      exclude:
+      - arch: arm64`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 3, len(errs)) {
+				assert.Equal(t, "stages.test.matrix.os", errs[0].Path)
+				assert.Equal(t, "Matrix axis os has no values", errs[0].Message)
+				assert.Equal(t, "stages.test.matrix.exclude[0].arch", errs[1].Path)
+				assert.Equal(t, "Matrix exclude uses unknown axis arch", errs[1].Message)
+				assert.Equal(t, "stages.test.matrix", errs[2].Path)
+				assert.Equal(t, "Matrix has no combinations", errs[2].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForDuplicateAxisValues", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  test:
+    image: golang:1.17-alpine
+    matrix:
+      go: ["1.17", "1.17"]`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "stages.test.matrix.go", errs[0].Path)
+				assert.Equal(t, "Matrix axis go has duplicate value 1.17", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForCombinationsExpandingToTheSameStageName", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  test:
+    image: golang:1.17-alpine
+    matrix:
+      os: ["linux"]
+      target: ["a b", "a-b", "a/b"]`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 2, len(errs)) {
+				assert.Equal(t, "stages.test.matrix", errs[0].Path)
+				assert.Equal(t, "Matrix combinations os=linux, target=a b and os=linux, target=a-b both expand to stage test-linux-a-b", errs[0].Message)
+				assert.Equal(t, "Matrix combinations os=linux, target=a b and os=linux, target=a/b both expand to stage test-linux-a-b", errs[1].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorIfMatrixAndParallelStagesAreBothSet", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  test:
+    matrix:
+      go: ["1.16", "1.17"]
+    parallelStages:
+      unit:
+        image: golang:1.17-alpine`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "stages.test.matrix", errs[0].Path)
+			}
+		}
+	})
+}
@@ -0,0 +1,277 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// MigrationChange describes a single deprecated construct that was rewritten to current syntax
+type MigrationChange struct {
+	Path    string `yaml:"path,omitempty" json:"path,omitempty"`
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+// MigrationReport lists everything that was changed by a migration
+type MigrationReport struct {
+	Changes []MigrationChange `yaml:"changes,omitempty" json:"changes,omitempty"`
+}
+
+// HasChanges indicates whether the migration changed anything
+func (r *MigrationReport) HasChanges() bool {
+	return len(r.Changes) > 0
+}
+
+func (r *MigrationReport) add(path, message string) {
+	r.Changes = append(r.Changes, MigrationChange{Path: path, Message: message})
+}
+
+const (
+	migrationMessagePipelines              = "Renamed the deprecated pipelines section to stages"
+	migrationMessageReadiness              = "Renamed the deprecated readiness property to readinessProbe"
+	migrationMessageReadinessIgnored       = "Removed the deprecated readiness property, since readinessProbe is set and takes precedence"
+	migrationMessageReadinessFields        = "Moved the deprecated path, port, protocol and hostname fields into httpGet"
+	migrationMessageReadinessFieldsIgnored = "Removed the deprecated path, port, protocol and hostname fields, since they're ignored when httpGet or exec is set"
+)
+
+// migrationReadinessFields maps the deprecated readiness probe fields to their httpGet counterparts
+var migrationReadinessFields = map[string]string{
+	"path":     "path",
+	"port":     "port",
+	"hostname": "host",
+	"protocol": "scheme",
+}
+
+// Migrate rewrites deprecated constructs in the manifest to their current syntax; marshalling the manifest afterwards no longer uses any of them
+func Migrate(manifest *EstafetteManifest) (report MigrationReport) {
+
+	if manifest == nil {
+		return
+	}
+
+	if manifest.usesDeprecatedPipelines {
+		manifest.usesDeprecatedPipelines = false
+		report.add("pipelines", migrationMessagePipelines)
+	}
+
+	migrateService := func(path string, service *EstafetteService) {
+		if service.Readiness != nil {
+			if service.ReadinessProbe == nil {
+				service.ReadinessProbe = service.Readiness
+				report.add(path+".readiness", migrationMessageReadiness)
+			} else {
+				report.add(path+".readiness", migrationMessageReadinessIgnored)
+			}
+			service.Readiness = nil
+		}
+
+		if probe := service.ReadinessProbe; probe != nil && probe.setsDeprecatedFieldsExplicitly() {
+			if probe.HttpGet == nil && probe.Exec == nil {
+				probe.HttpGet = &HttpGetProbe{
+					Path:   probe.Path,
+					Port:   probe.Port,
+					Host:   probe.Hostname,
+					Scheme: probe.Protocol,
+				}
+				report.add(path+".readinessProbe", migrationMessageReadinessFields)
+			} else {
+				report.add(path+".readinessProbe", migrationMessageReadinessFieldsIgnored)
+			}
+			probe.Path = ""
+			probe.Port = 0
+			probe.Hostname = ""
+			probe.Protocol = ""
+			probe.SetsDeprecatedFields = false
+		}
+	}
+
+	manifest.walkServices(migrateService)
+
+	migrateStage := func(path string, stage *EstafetteStage) {
+		for i, svc := range stage.Services {
+			migrateService(fmt.Sprintf("%v.services[%v]", path, i), svc)
+		}
+	}
+
+	// templates aren't part of walkServices, since their stages end up in the stages, releases and bots using them
+	for _, st := range manifest.StageTemplates {
+		path := fmt.Sprintf("stageTemplates.%v", st.Name)
+		migrateStage(path, &st.Stage)
+		walkStages(path+".parallelStages", st.Stage.ParallelStages, migrateStage)
+	}
+	for _, rt := range manifest.ReleaseTemplates {
+		walkStages(fmt.Sprintf("releaseTemplates.%v.stages", rt.Name), rt.Stages, migrateStage)
+	}
+	for _, bt := range manifest.BotTemplates {
+		walkStages(fmt.Sprintf("botTemplates.%v.stages", bt.Name), bt.Stages, migrateStage)
+	}
+
+	return
+}
+
+// Migrate rewrites deprecated constructs to their current syntax, only touching the lines involved so comments and formatting stay intact
+func (m *EditableManifest) Migrate() (report MigrationReport, err error) {
+	for {
+		change, err := m.migrateNext()
+		if err != nil {
+			return report, err
+		}
+		if change == nil {
+			return report, nil
+		}
+		report.Changes = append(report.Changes, *change)
+	}
+}
+
+// migrateNext applies the first migration it can find; since every edit shifts lines the document is searched again after each of them
+func (m *EditableManifest) migrateNext() (*MigrationChange, error) {
+
+	root := m.root()
+	if root == nil {
+		return nil, nil
+	}
+
+	pipelinesKey, _ := mappingEntry(root, "pipelines")
+	stagesKey, _ := mappingEntry(root, "stages")
+	if pipelinesKey != nil && stagesKey == nil {
+		err := m.edit(func() error {
+			return m.replaceKey(pipelinesKey, "stages")
+		})
+		return &MigrationChange{Path: "pipelines", Message: migrationMessagePipelines}, err
+	}
+
+	for _, svc := range m.serviceNodes() {
+		if svc.node.Kind != yamlv3.MappingNode || svc.node.Style&yamlv3.FlowStyle != 0 {
+			continue
+		}
+
+		readinessKey, _ := mappingEntry(svc.node, "readiness")
+		probeKey, probe := mappingEntry(svc.node, "readinessProbe")
+
+		if readinessKey != nil && probeKey != nil {
+			err := m.edit(func() error {
+				m.removeLines(m.commentStart(readinessKey.Line-1, readinessKey.Column-1), m.blockEnd(readinessKey))
+				return nil
+			})
+			return &MigrationChange{Path: svc.path + ".readiness", Message: migrationMessageReadinessIgnored}, err
+		}
+
+		if readinessKey != nil {
+			err := m.edit(func() error {
+				return m.replaceKey(readinessKey, "readinessProbe")
+			})
+			return &MigrationChange{Path: svc.path + ".readiness", Message: migrationMessageReadiness}, err
+		}
+
+		if probe == nil || probe.Kind != yamlv3.MappingNode || probe.Style&yamlv3.FlowStyle != 0 {
+			continue
+		}
+
+		legacyKeys := []*yamlv3.Node{}
+		legacyValues := []*yamlv3.Node{}
+		for i := 0; i+1 < len(probe.Content); i += 2 {
+			if _, ok := migrationReadinessFields[probe.Content[i].Value]; ok {
+				legacyKeys = append(legacyKeys, probe.Content[i])
+				legacyValues = append(legacyValues, probe.Content[i+1])
+			}
+		}
+		if len(legacyKeys) == 0 {
+			continue
+		}
+
+		_, httpGet := mappingEntry(probe, "httpGet")
+		_, exec := mappingEntry(probe, "exec")
+
+		// collect the line ranges of the legacy fields, removing them from the bottom up so earlier ranges stay valid
+		type lineRange struct{ from, to int }
+		ranges := []lineRange{}
+		for _, key := range legacyKeys {
+			ranges = append(ranges, lineRange{key.Line - 1, m.blockEnd(key)})
+		}
+		sort.Slice(ranges, func(i, j int) bool { return ranges[i].from > ranges[j].from })
+
+		indent := strings.Repeat(" ", legacyKeys[0].Column-1)
+		httpGetLines := []string{indent + "httpGet:"}
+		for i, key := range legacyKeys {
+			// plain values are copied as is, so numbers like the port don't end up quoted
+			value := legacyValues[i].Value
+			if legacyValues[i].Style != 0 {
+				value = formatScalar(value, legacyValues[i].Style)
+			}
+			httpGetLines = append(httpGetLines, fmt.Sprintf("%v%v%v: %v", indent, strings.Repeat(" ", m.indentUnit()), migrationReadinessFields[key.Value], value))
+		}
+		insertAt := ranges[len(ranges)-1].from
+
+		err := m.edit(func() error {
+			for _, r := range ranges {
+				m.removeLines(r.from, r.to)
+			}
+			if httpGet == nil && exec == nil {
+				m.insertLines(insertAt, httpGetLines...)
+			}
+			return nil
+		})
+
+		if httpGet == nil && exec == nil {
+			return &MigrationChange{Path: svc.path + ".readinessProbe", Message: migrationMessageReadinessFields}, err
+		}
+		return &MigrationChange{Path: svc.path + ".readinessProbe", Message: migrationMessageReadinessFieldsIgnored}, err
+	}
+
+	return nil, nil
+}
+
+type editableNode struct {
+	path string
+	node *yamlv3.Node
+}
+
+// serviceNodes returns the services of all stages in the build, releases, bots and their templates
+func (m *EditableManifest) serviceNodes() (services []editableNode) {
+
+	root := m.root()
+	if root == nil {
+		return
+	}
+
+	var walk func(path string, stages *yamlv3.Node)
+	walk = func(path string, stages *yamlv3.Node) {
+		if stages == nil || stages.Kind != yamlv3.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(stages.Content); i += 2 {
+			stagePath := fmt.Sprintf("%v.%v", path, stages.Content[i].Value)
+			stage := stages.Content[i+1]
+
+			if _, svcs := mappingEntry(stage, "services"); svcs != nil && svcs.Kind == yamlv3.SequenceNode {
+				for j, svc := range svcs.Content {
+					services = append(services, editableNode{path: fmt.Sprintf("%v.services[%v]", stagePath, j), node: svc})
+				}
+			}
+
+			_, parallelStages := mappingEntry(stage, "parallelStages")
+			walk(stagePath+".parallelStages", parallelStages)
+		}
+	}
+
+	walk("stages", m.stages())
+
+	// a stage template is a stage itself, so the stageTemplates section is walked like a stages section
+	_, stageTemplates := mappingEntry(root, "stageTemplates")
+	walk("stageTemplates", stageTemplates)
+
+	for _, section := range []string{"releases", "releaseTemplates", "bots", "botTemplates"} {
+		_, targets := mappingEntry(root, section)
+		if targets == nil || targets.Kind != yamlv3.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(targets.Content); i += 2 {
+			_, stages := mappingEntry(targets.Content[i+1], "stages")
+			walk(fmt.Sprintf("%v.%v.stages", section, targets.Content[i].Value), stages)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,269 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	t.Run("RenamesDeprecatedPipelinesToStages", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+pipelines:
+  build:
+    image: golang:1.17-alpine`, true)
+		assert.Nil(t, err)
+
+		// act
+		report := Migrate(&manifest)
+
+		if assert.Equal(t, 1, len(report.Changes)) {
+			assert.Equal(t, "pipelines", report.Changes[0].Path)
+		}
+		assert.Equal(t, 0, len(Lint(&manifest, *GetDefaultManifestPreferences())))
+	})
+
+	t.Run("MovesLegacyReadinessToReadinessProbeWithHttpGet", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  integration-test:
+    image: golang:1.17-alpine
+    services:
+    - name: kubernetes
+      image: bsycorp/kind:latest-1.15
+      readiness:
+        path: /kubernetes-ready
+        port: 10080
+        hostname: kubernetes.kube-system.svc.cluster.local`, true)
+		assert.Nil(t, err)
+
+		// act
+		report := Migrate(&manifest)
+
+		assert.Equal(t, 2, len(report.Changes))
+		service := manifest.Stages[0].Services[0]
+		assert.Nil(t, service.Readiness)
+		if assert.NotNil(t, service.ReadinessProbe) && assert.NotNil(t, service.ReadinessProbe.HttpGet) {
+			assert.Equal(t, "/kubernetes-ready", service.ReadinessProbe.HttpGet.Path)
+			assert.Equal(t, 10080, service.ReadinessProbe.HttpGet.Port)
+			assert.Equal(t, "kubernetes.kube-system.svc.cluster.local", service.ReadinessProbe.HttpGet.Host)
+			assert.Equal(t, "http", service.ReadinessProbe.HttpGet.Scheme)
+			assert.Equal(t, "", service.ReadinessProbe.Path)
+			assert.Equal(t, 0, service.ReadinessProbe.Port)
+		}
+	})
+
+	t.Run("LeavesProbeWithoutLegacyFieldsAsIsAfterDefaultsAreApplied", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  integration-test:
+    image: golang:1.17-alpine
+    services:
+    - name: kubernetes
+      image: bsycorp/kind:v1.15.0
+      readinessProbe:
+        timeoutSeconds: 30`, true)
+		assert.Nil(t, err)
+
+		// act
+		report := Migrate(&manifest)
+
+		assert.False(t, report.HasChanges())
+		probe := manifest.Stages[0].Services[0].ReadinessProbe
+		assert.Nil(t, probe.HttpGet)
+		assert.Equal(t, 30, probe.TimeoutSeconds)
+	})
+
+	t.Run("MigratesServicesOfStageAndBotTemplates", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stageTemplates:
+  integration-test:
+    image: golang:1.17-alpine
+    services:
+    - name: database
+      image: cockroachdb/cockroach:v19.1.5
+      readiness:
+        path: /health
+
+stages:
+  build:
+    image: golang:1.17-alpine
+
+botTemplates:
+  base:
+    stages:
+      check:
+        image: golang:1.17-alpine
+        services:
+        - name: database
+          image: cockroachdb/cockroach:v19.1.5
+          readinessProbe:
+            path: /health`, true)
+		assert.Nil(t, err)
+
+		// act
+		report := Migrate(&manifest)
+
+		if assert.Equal(t, 3, len(report.Changes)) {
+			assert.Equal(t, "stageTemplates.integration-test.services[0].readiness", report.Changes[0].Path)
+			assert.Equal(t, "stageTemplates.integration-test.services[0].readinessProbe", report.Changes[1].Path)
+			assert.Equal(t, "botTemplates.base.stages.check.services[0].readinessProbe", report.Changes[2].Path)
+		}
+		assert.Equal(t, "/health", manifest.StageTemplates[0].Stage.Services[0].ReadinessProbe.HttpGet.Path)
+		assert.Equal(t, "/health", manifest.BotTemplates[0].Stages[0].Services[0].ReadinessProbe.HttpGet.Path)
+	})
+
+	t.Run("ReturnsEmptyReportForCurrentSyntax", func(t *testing.T) {
+
+		manifest, err := ReadManifestFromFile(GetDefaultManifestPreferences(), "test-manifest-with-bots.yaml", true)
+		assert.Nil(t, err)
+
+		// act
+		report := Migrate(&manifest)
+
+		assert.False(t, report.HasChanges())
+	})
+}
+
+func TestEditableManifestMigrate(t *testing.T) {
+	t.Run("RewritesDeprecatedConstructsKeepingComments", func(t *testing.T) {
+
+		input := `# our pipeline
+pipelines:
+  integration-test:
+    image: golang:1.17-alpine
+    services:
+    - name: kubernetes
+      image: bsycorp/kind:latest-1.15
+      # wait for the cluster to come up
+      readiness:
+        path: /kubernetes-ready
+        timeoutSeconds: 120
+        port: 10080 # exposed by kind
+        protocol: http
+    - name: database
+      image: cockroachdb/cockroach:v19.1.5
+      readiness:
+        path: /health
+      readinessProbe:
+        httpGet:
+          path: /health?ready=1
+          port: 8080
+`
+		manifest, err := ReadEditableManifest(input)
+		assert.Nil(t, err)
+
+		// act
+		report, err := manifest.Migrate()
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 4, len(report.Changes)) {
+			assert.Equal(t, "pipelines", report.Changes[0].Path)
+			assert.Equal(t, "stages.integration-test.services[0].readiness", report.Changes[1].Path)
+			assert.Equal(t, "stages.integration-test.services[0].readinessProbe", report.Changes[2].Path)
+			assert.Equal(t, "stages.integration-test.services[1].readiness", report.Changes[3].Path)
+		}
+		assert.Equal(t, `# our pipeline
+stages:
+  integration-test:
+    image: golang:1.17-alpine
+    services:
+    - name: kubernetes
+      image: bsycorp/kind:latest-1.15
+      # wait for the cluster to come up
+      readinessProbe:
+        httpGet:
+          path: /kubernetes-ready
+          port: 10080
+          scheme: http
+        timeoutSeconds: 120
+    - name: database
+      image: cockroachdb/cockroach:v19.1.5
+      readinessProbe:
+        httpGet:
+          path: /health?ready=1
+          port: 8080
+`, manifest.String())
+
+		migrated, err := manifest.Manifest(nil, true)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(Lint(&migrated, *GetDefaultManifestPreferences())))
+	})
+
+	t.Run("RewritesServicesOfStageAndBotTemplates", func(t *testing.T) {
+
+		input := `stageTemplates:
+  integration-test:
+    image: golang:1.17-alpine
+    services:
+    - name: database
+      image: cockroachdb/cockroach:v19.1.5
+      readiness:
+        path: /health
+botTemplates:
+  base:
+    stages:
+      check:
+        image: golang:1.17-alpine
+        services:
+        - name: database
+          image: cockroachdb/cockroach:v19.1.5
+          readinessProbe:
+            path: /health
+`
+		manifest, err := ReadEditableManifest(input)
+		assert.Nil(t, err)
+
+		// act
+		report, err := manifest.Migrate()
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 3, len(report.Changes)) {
+			assert.Equal(t, "stageTemplates.integration-test.services[0].readiness", report.Changes[0].Path)
+			assert.Equal(t, "stageTemplates.integration-test.services[0].readinessProbe", report.Changes[1].Path)
+			assert.Equal(t, "botTemplates.base.stages.check.services[0].readinessProbe", report.Changes[2].Path)
+		}
+		assert.Equal(t, `stageTemplates:
+  integration-test:
+    image: golang:1.17-alpine
+    services:
+    - name: database
+      image: cockroachdb/cockroach:v19.1.5
+      readinessProbe:
+        httpGet:
+          path: /health
+botTemplates:
+  base:
+    stages:
+      check:
+        image: golang:1.17-alpine
+        services:
+        - name: database
+          image: cockroachdb/cockroach:v19.1.5
+          readinessProbe:
+            httpGet:
+              path: /health
+`, manifest.String())
+	})
+
+	t.Run("ReturnsEmptyReportForCurrentSyntax", func(t *testing.T) {
+
+		input := `stages:
+  build:
+    image: golang:1.17-alpine
+`
+		manifest, err := ReadEditableManifest(input)
+		assert.Nil(t, err)
+
+		// act
+		report, err := manifest.Migrate()
+
+		assert.Nil(t, err)
+		assert.False(t, report.HasChanges())
+		assert.Equal(t, input, manifest.String())
+	})
+}
@@ -0,0 +1,250 @@
+package manifest
+
+import (
+	"fmt"
+)
+
+// PlanContext describes the event to compute an execution plan for
+type PlanContext struct {
+	// Release is the release target to plan and Bot the bot; with neither set the build stages are planned
+	Release string
+	Bot     string
+
+	Branch string
+	Action string
+	Server string
+	Event  *EstafetteEvent
+
+	// StageStatuses holds the status stages finish with, by graph node id; stages that aren't in it succeed
+	StageStatuses map[string]string
+}
+
+// ExecutionPlan lists what would run for a PlanContext, without running anything
+type ExecutionPlan struct {
+	// Steps holds the stages in the order they're started; stages with the same level can run at the same time
+	Steps []*PlanStep
+	// Services holds the services of all stages that would run, in the order they're started
+	Services []*PlanService
+}
+
+// PlanStep is a stage in an ExecutionPlan
+type PlanStep struct {
+	ID    string
+	Stage *EstafetteStage
+	// Group is the name of the stage holding the parallel stage, empty for other stages
+	Group string
+	Level int
+	// Status is the status the when expression is evaluated with: failed if a stage it depends on failed, succeeded otherwise
+	Status string
+	When   string
+	Runs   bool
+	// Result is succeeded or failed for stages that run and skipped for others
+	Result string
+}
+
+// PlanService is a service in an ExecutionPlan
+type PlanService struct {
+	Service *EstafetteService
+	// Stage is the id of the step starting the service
+	Stage      string
+	When       string
+	Runs       bool
+	MultiStage bool
+	// Stages holds the ids of the running steps the service is available to; multi-stage services keep running until the end of the pipeline
+	Stages []string
+}
+
+const (
+	planStatusSucceeded = "succeeded"
+	planStatusFailed    = "failed"
+	planStatusSkipped   = "skipped"
+)
+
+// Plan computes which stages and services would run for context; it returns an error if the release or bot doesn't exist, the stages
+// don't form a valid graph or a when expression is invalid
+func Plan(manifest *EstafetteManifest, context PlanContext) (*ExecutionPlan, error) {
+
+	graph, err := planGraph(manifest, context)
+	if err != nil {
+		return nil, err
+	}
+
+	whenContext := WhenContext{
+		Status:  planStatusSucceeded,
+		Branch:  context.Branch,
+		Action:  context.Action,
+		Server:  context.Server,
+		Trigger: planTrigger(context.Event),
+		Labels:  manifest.Labels,
+	}
+
+	levels := map[string]int{}
+	for i, level := range graph.Levels() {
+		for _, n := range level {
+			levels[n.ID] = i
+		}
+	}
+
+	plan := &ExecutionPlan{}
+	failed := map[string]bool{}
+
+	for _, n := range graph.Nodes {
+
+		// a stage sees failures of all stages it directly or indirectly depends on
+		for _, d := range n.DependsOn {
+			if failed[d] {
+				failed[n.ID] = true
+			}
+		}
+
+		step := &PlanStep{
+			ID:     n.ID,
+			Stage:  n.Stage,
+			Group:  n.Group,
+			Level:  levels[n.ID],
+			Status: planStatusSucceeded,
+			When:   planStepWhen(n),
+		}
+		if failed[n.ID] {
+			step.Status = planStatusFailed
+		}
+
+		whenContext.Status = step.Status
+		step.Runs, err = EvaluateWhen(step.When, whenContext)
+		if err != nil {
+			return nil, fmt.Errorf("Evaluating when of stage %v failed: %w", n.ID, err)
+		}
+
+		step.Result = planStatusSkipped
+		if step.Runs {
+			step.Result = planStatusSucceeded
+			if status, ok := context.StageStatuses[n.ID]; ok && status != "" {
+				step.Result = status
+			}
+			if step.Result == planStatusFailed {
+				failed[n.ID] = true
+			}
+
+			for _, s := range n.Stage.Services {
+				service := &PlanService{
+					Service:    s,
+					Stage:      n.ID,
+					When:       planWhen(s.When),
+					MultiStage: planMultiStage(s, n.Stage),
+				}
+				service.Runs, err = EvaluateWhen(service.When, whenContext)
+				if err != nil {
+					return nil, fmt.Errorf("Evaluating when of service %v of stage %v failed: %w", s.Name, n.ID, err)
+				}
+				plan.Services = append(plan.Services, service)
+			}
+		}
+
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	for _, service := range plan.Services {
+		if !service.Runs {
+			continue
+		}
+		started := false
+		for _, step := range plan.Steps {
+			if step.ID == service.Stage {
+				started = true
+			}
+			if started && step.Runs && (step.ID == service.Stage || service.MultiStage) {
+				service.Stages = append(service.Stages, step.ID)
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// Step returns the step with id, or nil if there's no such step
+func (plan *ExecutionPlan) Step(id string) *PlanStep {
+	for _, s := range plan.Steps {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// planGraph returns the stage graph of the release, bot or build stages selected by context
+func planGraph(manifest *EstafetteManifest, context PlanContext) (*StageGraph, error) {
+
+	if context.Release != "" {
+		for _, r := range manifest.Releases {
+			if r.Name == context.Release {
+				return r.StageGraph()
+			}
+		}
+		return nil, fmt.Errorf("Release %v does not exist", context.Release)
+	}
+
+	if context.Bot != "" {
+		for _, b := range manifest.Bots {
+			if b.Name == context.Bot {
+				return b.StageGraph()
+			}
+		}
+		return nil, fmt.Errorf("Bot %v does not exist", context.Bot)
+	}
+
+	return manifest.StageGraph()
+}
+
+// planTrigger returns the type of the event, which is what the trigger identifier in when expressions evaluates to
+func planTrigger(event *EstafetteEvent) string {
+	switch {
+	case event == nil:
+		return ""
+	case event.Pipeline != nil:
+		return "pipeline"
+	case event.Release != nil:
+		return "release"
+	case event.Git != nil:
+		return "git"
+	case event.Docker != nil:
+		return "docker"
+	case event.Cron != nil:
+		return "cron"
+	case event.PubSub != nil:
+		return "pubsub"
+	case event.Github != nil:
+		return "github"
+	case event.Bitbucket != nil:
+		return "bitbucket"
+	case event.Manual != nil:
+		return "manual"
+	}
+	return ""
+}
+
+// planWhen returns when, or the default stages and services get from SetDefaults if it's empty
+func planWhen(when string) string {
+	if when == "" {
+		return "status == 'succeeded'"
+	}
+	return when
+}
+
+// planStepWhen returns the when of the node's stage; a parallel stage only runs if the when of the stage holding it is true as well
+func planStepWhen(n *StageGraphNode) string {
+	when := planWhen(n.Stage.When)
+	if n.group != nil {
+		if groupWhen := planWhen(n.group.When); groupWhen != when {
+			return fmt.Sprintf("(%v) && (%v)", groupWhen, when)
+		}
+	}
+	return when
+}
+
+// planMultiStage returns whether the service keeps running after its stage, defaulting it like SetDefaults does if it isn't set
+func planMultiStage(service *EstafetteService, stage *EstafetteStage) bool {
+	if service.MultiStage != nil {
+		return *service.MultiStage
+	}
+	return stage.ContainerImage == ""
+}
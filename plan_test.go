@@ -0,0 +1,194 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const planManifest = `
+labels:
+  team: estafette-team
+
+stages:
+  build:
+    image: golang:1.17-alpine
+  test:
+    services:
+    - name: cockroachdb
+      image: cockroachdb/cockroach:v19.2.0
+    - name: redis
+      image: redis:6
+      when: branch == 'main'
+  integration-tests:
+    parallelStages:
+      api:
+        image: golang:1.17-alpine
+      ui:
+        image: node:16-alpine
+        services:
+        - name: selenium
+          image: selenium/standalone-chrome:latest
+  push:
+    image: extensions/docker:stable
+    when: status == 'succeeded' && branch == 'main'
+  notify:
+    image: extensions/slack-build-status:stable
+    when: status == 'failed' || labels.team == 'estafette-team'
+
+releases:
+  production:
+    stages:
+      deploy:
+        image: extensions/gke:stable
+      rollback:
+        image: extensions/gke:stable
+        when: status == 'failed' && action == 'deploy-canary'
+`
+
+func planSteps(plan *ExecutionPlan) (ids []string) {
+	for _, s := range plan.Steps {
+		if s.Runs {
+			ids = append(ids, s.ID)
+		}
+	}
+	return
+}
+
+func TestPlan(t *testing.T) {
+
+	manifest, err := ReadManifest(nil, planManifest, true)
+	assert.Nil(t, err)
+
+	t.Run("ReturnsStagesThatRunForBranch", func(t *testing.T) {
+
+		// act
+		plan, err := Plan(&manifest, PlanContext{Branch: "feature"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"build", "test", "integration-tests/api", "integration-tests/ui", "notify"}, planSteps(plan))
+		push := plan.Step("push")
+		assert.False(t, push.Runs)
+		assert.Equal(t, "skipped", push.Result)
+		assert.Equal(t, "status == 'succeeded' && branch == 'main'", push.When)
+	})
+
+	t.Run("ReturnsParallelStagesInTheSameLevel", func(t *testing.T) {
+
+		// act
+		plan, err := Plan(&manifest, PlanContext{Branch: "main"})
+
+		assert.Nil(t, err)
+		api, ui := plan.Step("integration-tests/api"), plan.Step("integration-tests/ui")
+		assert.Equal(t, "integration-tests", api.Group)
+		assert.Equal(t, api.Level, ui.Level)
+		assert.Equal(t, api.Level+1, plan.Step("push").Level)
+	})
+
+	t.Run("ReturnsServicesWithTheStagesTheySpan", func(t *testing.T) {
+
+		// act
+		plan, err := Plan(&manifest, PlanContext{Branch: "main"})
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 3, len(plan.Services)) {
+			cockroach := plan.Services[0]
+			assert.Equal(t, "test", cockroach.Stage)
+			assert.True(t, cockroach.Runs)
+			assert.True(t, cockroach.MultiStage)
+			assert.Equal(t, []string{"test", "integration-tests/api", "integration-tests/ui", "push", "notify"}, cockroach.Stages)
+
+			assert.True(t, plan.Services[1].Runs)
+
+			selenium := plan.Services[2]
+			assert.Equal(t, "integration-tests/ui", selenium.Stage)
+			assert.False(t, selenium.MultiStage)
+			assert.Equal(t, []string{"integration-tests/ui"}, selenium.Stages)
+		}
+	})
+
+	t.Run("EvaluatesServiceWhen", func(t *testing.T) {
+
+		// act
+		plan, err := Plan(&manifest, PlanContext{Branch: "feature"})
+
+		assert.Nil(t, err)
+		redis := plan.Services[1]
+		assert.False(t, redis.Runs)
+		assert.Equal(t, 0, len(redis.Stages))
+	})
+
+	t.Run("PropagatesFailedStatusToLaterStages", func(t *testing.T) {
+
+		// act
+		plan, err := Plan(&manifest, PlanContext{Branch: "main", StageStatuses: map[string]string{"integration-tests/ui": "failed"}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"build", "test", "integration-tests/api", "integration-tests/ui", "notify"}, planSteps(plan))
+		assert.Equal(t, "succeeded", plan.Step("integration-tests/api").Status)
+		assert.Equal(t, "failed", plan.Step("integration-tests/ui").Result)
+		assert.Equal(t, "failed", plan.Step("push").Status)
+		assert.Equal(t, "failed", plan.Step("notify").Status)
+	})
+
+	t.Run("PlansReleaseWithAction", func(t *testing.T) {
+
+		// act
+		plan, err := Plan(&manifest, PlanContext{Release: "production", Action: "deploy-canary", StageStatuses: map[string]string{"deploy": "failed"}, Event: &EstafetteEvent{Manual: &EstafetteManualEvent{UserID: "me@estafette.io"}}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"deploy", "rollback"}, planSteps(plan))
+	})
+
+	t.Run("EvaluatesTriggerFromEvent", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+    when: trigger == 'cron'`, true)
+		assert.Nil(t, err)
+
+		// act
+		cron, err := Plan(&manifest, PlanContext{Event: &EstafetteEvent{Cron: &EstafetteCronEvent{}}})
+		assert.Nil(t, err)
+		git, err := Plan(&manifest, PlanContext{Event: &EstafetteEvent{Git: &EstafetteGitEvent{}}})
+		assert.Nil(t, err)
+
+		assert.True(t, cron.Steps[0].Runs)
+		assert.False(t, git.Steps[0].Runs)
+	})
+
+	t.Run("CombinesWhenOfParallelStagesWithWhenOfTheirGroup", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  deploy:
+    when: branch == 'main'
+    parallelStages:
+      a:
+        image: extensions/gke:stable
+      b:
+        image: extensions/gke:stable
+        when: status == 'succeeded' && action == 'deploy'`, true)
+		assert.Nil(t, err)
+
+		// act
+		feature, err := Plan(&manifest, PlanContext{Branch: "feature-x", Action: "deploy"})
+		assert.Nil(t, err)
+		main, err := Plan(&manifest, PlanContext{Branch: "main", Action: "deploy"})
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string(nil), planSteps(feature))
+		assert.Equal(t, []string{"deploy/a", "deploy/b"}, planSteps(main))
+		assert.Equal(t, "(branch == 'main') && (status == 'succeeded' && action == 'deploy')", main.Step("deploy/b").When)
+	})
+
+	t.Run("ReturnsErrorForUnknownRelease", func(t *testing.T) {
+
+		// act
+		_, err := Plan(&manifest, PlanContext{Release: "staging"})
+
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,181 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Position is a location in a manifest file
+type Position struct {
+	File   string `yaml:"file,omitempty" json:"file,omitempty"`
+	Line   int    `yaml:"line,omitempty" json:"line,omitempty"`
+	Column int    `yaml:"column,omitempty" json:"column,omitempty"`
+}
+
+// String returns the position as file:line:column, leaving out the parts that are unknown
+func (p Position) String() string {
+	parts := []string{}
+	if p.File != "" {
+		parts = append(parts, p.File)
+	}
+	if p.Line > 0 {
+		parts = append(parts, strconv.Itoa(p.Line))
+		if p.Column > 0 {
+			parts = append(parts, strconv.Itoa(p.Column))
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+// positionIndex maps structured paths like releases.production.triggers[1] to their position in the manifest file
+type positionIndex map[string]Position
+
+var (
+	yamlLineRegex       = regexp.MustCompile(`^line (\d+): `)
+	yamlSyntaxLineRegex = regexp.MustCompile(`^yaml: line (\d+): `)
+	yamlFieldTypeRegex  = regexp.MustCompile(`^(field \S+ not found) in type .*$`)
+)
+
+// newPositionIndex parses the manifest into a node tree, which unlike the struct based unmarshalling keeps track of line and column for every node
+func newPositionIndex(data []byte, file string) positionIndex {
+
+	index := positionIndex{}
+
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &document); err != nil || len(document.Content) == 0 {
+		return index
+	}
+
+	index.addNode("", document.Content[0], file)
+
+	// provide backwards compatibility for the deprecated pipelines section now renamed to stages
+	if _, hasStages := index["stages"]; !hasStages {
+		for path, position := range index {
+			if path == "pipelines" || strings.HasPrefix(path, "pipelines.") {
+				index["stages"+strings.TrimPrefix(path, "pipelines")] = position
+			}
+		}
+	}
+
+	return index
+}
+
+func (index positionIndex) addNode(path string, node *yamlv3.Node, file string) {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			keyPath := joinPath(path, key.Value)
+			index[keyPath] = Position{File: file, Line: key.Line, Column: key.Column}
+			index.addNode(keyPath, node.Content[i+1], file)
+		}
+	case yamlv3.SequenceNode:
+		for i, item := range node.Content {
+			itemPath := fmt.Sprintf("%v[%v]", path, i)
+			index[itemPath] = Position{File: file, Line: item.Line, Column: item.Column}
+			index.addNode(itemPath, item, file)
+		}
+	}
+}
+
+// lookup returns the position of the path, or of its closest parent that is present in the file
+func (index positionIndex) lookup(path string) (Position, bool) {
+	for path != "" {
+		if position, ok := index[path]; ok {
+			return position, true
+		}
+		cut := strings.LastIndexAny(path, ".[")
+		if cut < 0 {
+			break
+		}
+		path = path[:cut]
+	}
+	return Position{}, false
+}
+
+// lookupLine returns the position of the left-most node on a line
+func (index positionIndex) lookupLine(line int, file string) Position {
+	position := Position{File: file, Line: line}
+	for _, p := range index {
+		if p.Line == line && (position.Column == 0 || p.Column < position.Column) {
+			position.Column = p.Column
+		}
+	}
+	return position
+}
+
+// wrapUnmarshalError locates an error returned while unmarshalling a re-marshalled section of the manifest at path; the line numbers
+// reported by yaml refer to the re-marshalled section rather than the file, so they're dropped in favour of the path
+func wrapUnmarshalError(path string, err error) error {
+	var errs ValidationErrors
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		for _, message := range typeErr.Errors {
+			errs.addf(path, "%v", tidyTypeErrorMessage(message))
+		}
+		return errs
+	}
+	errs.add(path, err)
+	return errs
+}
+
+// withPositions attaches the position in the manifest file to every error returned from unmarshalling or validating it
+func withPositions(err error, data []byte, file string) error {
+
+	if err == nil {
+		return nil
+	}
+
+	index := newPositionIndex(data, file)
+
+	var errs ValidationErrors
+
+	switch e := err.(type) {
+	case ValidationErrors:
+		errs = e
+	case *ValidationError:
+		errs = ValidationErrors{e}
+	case *yaml.TypeError:
+		// type errors for the root of the manifest do refer to lines in the file
+		for _, message := range e.Errors {
+			if match := yamlLineRegex.FindStringSubmatch(message); match != nil {
+				line, _ := strconv.Atoi(match[1])
+				position := index.lookupLine(line, file)
+				errs = append(errs, &ValidationError{Message: tidyTypeErrorMessage(message), Position: &position})
+			} else {
+				errs.addf("", "%v", tidyTypeErrorMessage(message))
+			}
+		}
+		return errs
+	default:
+		if match := yamlSyntaxLineRegex.FindStringSubmatch(err.Error()); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			position := index.lookupLine(line, file)
+			return ValidationErrors{{Message: yamlSyntaxLineRegex.ReplaceAllString(err.Error(), "yaml: "), Position: &position}}
+		}
+		return err
+	}
+
+	for _, e := range errs {
+		if e.Position != nil {
+			continue
+		}
+		if position, ok := index.lookup(e.Path); ok {
+			e.Position = &position
+		} else if file != "" {
+			e.Position = &Position{File: file}
+		}
+	}
+
+	return errs
+}
+
+// tidyTypeErrorMessage strips the line number and the go type - which for auxiliary structs lists all of their fields - from a yaml type error
+func tidyTypeErrorMessage(message string) string {
+	message = yamlLineRegex.ReplaceAllString(message, "")
+	return yamlFieldTypeRegex.ReplaceAllString(message, "$1")
+}
@@ -0,0 +1,163 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPosition(t *testing.T) {
+	t.Run("StringReturnsFileLineAndColumn", func(t *testing.T) {
+
+		position := Position{File: ".estafette.yaml", Line: 12, Column: 5}
+
+		// act
+		value := position.String()
+
+		assert.Equal(t, ".estafette.yaml:12:5", value)
+	})
+
+	t.Run("StringLeavesOutUnknownParts", func(t *testing.T) {
+
+		assert.Equal(t, "12:5", Position{Line: 12, Column: 5}.String())
+		assert.Equal(t, ".estafette.yaml", Position{File: ".estafette.yaml"}.String())
+	})
+}
+
+func TestPositionIndex(t *testing.T) {
+	t.Run("ReturnsPositionsForStagesReleasesTriggersAndServices", func(t *testing.T) {
+
+		input := `stages:
+  build:
+    image: golang
+    services:
+    - name: database
+      image: cockroachdb/cockroach
+
+releases:
+  production:
+    triggers:
+    - pipeline:
+        name: self
+    - cron:
+        schedule: '0 10 * * *'
+`
+
+		// act
+		index := newPositionIndex([]byte(input), ".estafette.yaml")
+
+		assert.Equal(t, Position{File: ".estafette.yaml", Line: 2, Column: 3}, index["stages.build"])
+		assert.Equal(t, Position{File: ".estafette.yaml", Line: 5, Column: 7}, index["stages.build.services[0]"])
+		assert.Equal(t, Position{File: ".estafette.yaml", Line: 9, Column: 3}, index["releases.production"])
+		assert.Equal(t, Position{File: ".estafette.yaml", Line: 13, Column: 7}, index["releases.production.triggers[1]"])
+	})
+
+	t.Run("LookupFallsBackToClosestParent", func(t *testing.T) {
+
+		input := `stages:
+  build:
+    commands:
+    - go build
+`
+		index := newPositionIndex([]byte(input), "")
+
+		// act
+		position, ok := index.lookup("stages.build.image")
+
+		assert.True(t, ok)
+		assert.Equal(t, Position{Line: 2, Column: 3}, position)
+	})
+
+	t.Run("MapsDeprecatedPipelinesToStages", func(t *testing.T) {
+
+		input := `pipelines:
+  build:
+    image: golang
+`
+		index := newPositionIndex([]byte(input), "")
+
+		// act
+		position, ok := index.lookup("stages.build.image")
+
+		assert.True(t, ok)
+		assert.Equal(t, Position{Line: 3, Column: 5}, position)
+	})
+}
+
+func TestReadManifestErrorPositions(t *testing.T) {
+	t.Run("ReturnsFileLineAndColumnForUnknownRootProperty", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifestFromFile(GetDefaultManifestPreferences(), "test-non-strict-manifest.yaml", true)
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "test-non-strict-manifest.yaml:1:1: field unknownProperty not found", err.Error())
+		}
+	})
+
+	t.Run("ReturnsLineAndColumnOfStageForErrorInsideStage", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang
+    commands:
+      go: build
+`, true)
+
+		if assert.NotNil(t, err) {
+			validationErrors, ok := err.(ValidationErrors)
+			if assert.True(t, ok) && assert.Equal(t, 1, len(validationErrors)) {
+				assert.Equal(t, "stages.build", validationErrors[0].Path)
+				assert.Equal(t, &Position{Line: 3, Column: 3}, validationErrors[0].Position)
+			}
+		}
+	})
+
+	t.Run("ReturnsLineAndColumnForValidationErrors", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang
+
+releases:
+  production:
+    triggers:
+    - pipeline:
+        name: self
+        status: unknown
+    stages:
+      deploy:
+        image: extensions/gke:stable
+`, true)
+
+		if assert.NotNil(t, err) {
+			validationErrors, ok := err.(ValidationErrors)
+			if assert.True(t, ok) && assert.Equal(t, 1, len(validationErrors)) {
+				assert.Equal(t, "releases.production.triggers[0].pipeline.status", validationErrors[0].Path)
+				assert.Equal(t, &Position{Line: 11, Column: 9}, validationErrors[0].Position)
+			}
+		}
+	})
+
+	t.Run("ReturnsLineForSyntaxErrors", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang
+   commands: [
+`, true)
+
+		if assert.NotNil(t, err) {
+			validationErrors, ok := err.(ValidationErrors)
+			if assert.True(t, ok) && assert.Equal(t, 1, len(validationErrors)) {
+				assert.Equal(t, 4, validationErrors[0].Position.Line)
+			}
+		}
+	})
+}
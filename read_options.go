@@ -0,0 +1,110 @@
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ReadOptions configures how ReadManifestWithOptions reads a manifest; the zero value reads strictly, applies defaults and validates
+type ReadOptions struct {
+	// Preferences are used for defaults and validation; the default preferences are used if not set
+	Preferences *EstafetteManifestPreferences
+	// Lenient ignores unknown properties instead of failing on them, so manifests written for newer versions can still be read
+	Lenient bool
+	// SkipDefaults leaves the manifest exactly as it's written
+	SkipDefaults bool
+	// SkipValidation returns the manifest without checking whether it's valid
+	SkipValidation bool
+	// File is the path of the manifest, used in error positions and to read the manifest from FS when no reader is passed
+	File string
+	// FS is the file system to read File and any files included by the manifest from
+	FS fs.FS
+	// IncludeResolver reads the files included by the manifest; if not set they're read from FS relative to File
+	IncludeResolver IncludeResolver
+	// Logger is used for debug logging; the global zerolog logger is used if not set
+	Logger *zerolog.Logger
+}
+
+// ReadManifestWithOptions reads .estafette.yaml from reader into an EstafetteManifest object; if reader is nil options.File is read from options.FS
+func ReadManifestWithOptions(reader io.Reader, options ReadOptions) (manifest EstafetteManifest, err error) {
+
+	logger := options.Logger
+	if logger == nil {
+		logger = &log.Logger
+	}
+
+	// default preferences if not passed
+	preferences := options.Preferences
+	if preferences == nil {
+		preferences = GetDefaultManifestPreferences()
+	}
+
+	if reader == nil {
+		if options.FS == nil || options.File == "" {
+			return manifest, fmt.Errorf("Either pass a reader or set both FS and File in the read options")
+		}
+		file, err := options.FS.Open(options.File)
+		if err != nil {
+			return manifest, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	if options.File != "" {
+		logger.Debug().Msgf("Reading %v file...", options.File)
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return manifest, err
+	}
+
+	// unmarshal strict by default, so non-defined properties or incorrect nesting will fail
+	unmarshal := yaml.UnmarshalStrict
+	if options.Lenient {
+		unmarshal = yaml.Unmarshal
+	}
+
+	if err := unmarshal(data, &manifest); err != nil {
+		return manifest, withPositions(err, data, options.File)
+	}
+
+	includeResolver := options.IncludeResolver
+	if includeResolver == nil && options.FS != nil {
+		includeResolver = NewFSIncludeResolver(options.FS)
+	}
+	includes, err := manifest.resolveIncludes(data, options.File, includeResolver, unmarshal)
+	if err != nil {
+		return manifest, err
+	}
+
+	if !options.SkipDefaults {
+		manifest.SetDefaults(*preferences)
+	}
+
+	if !options.SkipValidation {
+		// check if the preferences are valid, so for instance unknown lint severities don't go unnoticed
+		if err := preferences.Validate(); err != nil {
+			return manifest, err
+		}
+
+		// check if manifest is valid
+		err = manifest.Validate(*preferences)
+		if err != nil {
+			return manifest, includes.withPositions(err, data, options.File)
+		}
+	}
+
+	if options.File != "" {
+		logger.Debug().Msgf("Finished reading %v file successfully", options.File)
+	}
+
+	return
+}
@@ -0,0 +1,105 @@
+package manifest
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadManifestWithOptions(t *testing.T) {
+	t.Run("ReturnsErrorForUnknownPropertiesByDefault", func(t *testing.T) {
+
+		file, err := os.Open("test-non-strict-manifest.yaml")
+		assert.Nil(t, err)
+		defer file.Close()
+
+		// act
+		_, err = ReadManifestWithOptions(file, ReadOptions{File: "test-non-strict-manifest.yaml"})
+
+		if assert.NotNil(t, err) {
+			assert.True(t, strings.HasPrefix(err.Error(), "test-non-strict-manifest.yaml:1:1"), err.Error())
+		}
+	})
+
+	t.Run("IgnoresUnknownPropertiesWhenLenient", func(t *testing.T) {
+
+		file, err := os.Open("test-non-strict-manifest.yaml")
+		assert.Nil(t, err)
+		defer file.Close()
+
+		// act
+		manifest, err := ReadManifestWithOptions(file, ReadOptions{Lenient: true})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "estafette-ci-builder", manifest.Labels["app"])
+		assert.Equal(t, "build", manifest.Stages[0].Name)
+	})
+
+	t.Run("DoesNotApplyDefaultsWhenSkipDefaultsIsTrue", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifestWithOptions(strings.NewReader(`
+stages:
+  build:
+    image: golang:1.17-alpine`), ReadOptions{SkipDefaults: true, SkipValidation: true})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "", manifest.Stages[0].WorkingDirectory)
+		assert.Equal(t, "", string(manifest.Builder.Track))
+	})
+
+	t.Run("DoesNotValidateWhenSkipValidationIsTrue", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifestWithOptions(strings.NewReader(`
+stages:
+  build:
+    workDir: /go/src`), ReadOptions{SkipValidation: true})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(manifest.Stages))
+	})
+
+	t.Run("ReturnsErrorForInvalidPreferences", func(t *testing.T) {
+
+		preferences := GetDefaultManifestPreferences()
+		preferences.LintRules = map[string]LintSeverity{"latest-image-tag": "warn"}
+
+		// act
+		_, err := ReadManifestWithOptions(strings.NewReader(`
+stages:
+  build:
+    image: golang:1.17-alpine`), ReadOptions{Preferences: preferences})
+
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "Lint rule latest-image-tag has unknown severity warn")
+		}
+	})
+
+	t.Run("ReadsFileFromFileSystemWhenReaderIsNil", func(t *testing.T) {
+
+		fsys := fstest.MapFS{
+			"app/.estafette.yaml": &fstest.MapFile{Data: []byte(`
+stages:
+  build:
+    image: golang:1.17-alpine`)},
+		}
+
+		// act
+		manifest, err := ReadManifestWithOptions(nil, ReadOptions{FS: fsys, File: "app/.estafette.yaml"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "golang:1.17-alpine", manifest.Stages[0].ContainerImage)
+	})
+
+	t.Run("ReturnsErrorWhenReaderIsNilWithoutFileSystem", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifestWithOptions(nil, ReadOptions{File: ".estafette.yaml"})
+
+		assert.NotNil(t, err)
+	})
+}
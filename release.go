@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	"fmt"
+
 	"github.com/jinzhu/copier"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -12,8 +14,13 @@ type EstafetteRelease struct {
 	CloneRepository *bool                     `yaml:"clone,omitempty" json:",omitempty"`
 	Actions         []*EstafetteReleaseAction `yaml:"actions,omitempty" json:",omitempty"`
 	Triggers        []*EstafetteTrigger       `yaml:"triggers,omitempty" json:",omitempty"`
+	EnvVars         map[string]string         `yaml:"env,omitempty" json:",omitempty"`
 	Stages          []*EstafetteStage         `yaml:"-" json:",omitempty"`
 	Template        string                    `yaml:"template,omitempty"`
+	StagesMerge     StagesMergeMode           `yaml:"stagesMerge,omitempty" json:",omitempty"`
+
+	// ResolvedTemplates lists the release templates the release got its values from, starting with its own template followed by its parents
+	ResolvedTemplates []string `yaml:"-" json:",omitempty"`
 }
 
 // UnmarshalYAML customizes unmarshalling an EstafetteRelease
@@ -25,8 +32,10 @@ func (release *EstafetteRelease) UnmarshalYAML(unmarshal func(interface{}) error
 		CloneRepository *bool                     `yaml:"clone"`
 		Actions         []*EstafetteReleaseAction `yaml:"actions"`
 		Triggers        []*EstafetteTrigger       `yaml:"triggers"`
+		EnvVars         map[string]string         `yaml:"env"`
 		Stages          yaml.MapSlice             `yaml:"stages"`
 		Template        string                    `yaml:"template"`
+		StagesMerge     StagesMergeMode           `yaml:"stagesMerge"`
 	}
 
 	// unmarshal to auxiliary type
@@ -40,18 +49,20 @@ func (release *EstafetteRelease) UnmarshalYAML(unmarshal func(interface{}) error
 	release.CloneRepository = aux.CloneRepository
 	release.Actions = aux.Actions
 	release.Triggers = aux.Triggers
+	release.EnvVars = aux.EnvVars
 	release.Template = aux.Template
+	release.StagesMerge = aux.StagesMerge
 
 	for _, mi := range aux.Stages {
 
 		bytes, err := yaml.Marshal(mi.Value)
 		if err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("stages.%v", mi.Key), err)
 		}
 
 		var stage *EstafetteStage
 		if err := yaml.Unmarshal(bytes, &stage); err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("stages.%v", mi.Key), err)
 		}
 		if stage == nil {
 			stage = &EstafetteStage{}
@@ -75,8 +86,10 @@ func (release EstafetteRelease) MarshalYAML() (out interface{}, err error) {
 		CloneRepository *bool                     `yaml:"clone,omitempty"`
 		Actions         []*EstafetteReleaseAction `yaml:"actions,omitempty"`
 		Triggers        []*EstafetteTrigger       `yaml:"triggers,omitempty"`
+		EnvVars         map[string]string         `yaml:"env,omitempty"`
 		Stages          yaml.MapSlice             `yaml:"stages,omitempty"`
 		Template        string                    `yaml:"template,omitempty"`
+		StagesMerge     StagesMergeMode           `yaml:"stagesMerge,omitempty"`
 	}
 
 	// map auxiliary properties
@@ -84,7 +97,9 @@ func (release EstafetteRelease) MarshalYAML() (out interface{}, err error) {
 	aux.CloneRepository = release.CloneRepository
 	aux.Actions = release.Actions
 	aux.Triggers = release.Triggers
+	aux.EnvVars = release.EnvVars
 	aux.Template = release.Template
+	aux.StagesMerge = release.StagesMerge
 
 	for _, stage := range release.Stages {
 		aux.Stages = append(aux.Stages, yaml.MapItem{
@@ -104,7 +119,8 @@ func (release EstafetteRelease) DeepCopy() (target EstafetteRelease) {
 	return
 }
 
-// InitFromTemplate uses template values for
+// InitFromTemplate uses template values for everything the release doesn't set itself, including the values the template inherits from its
+// own parent templates
 func (release *EstafetteRelease) InitFromTemplate(releaseTemplates map[string]*EstafetteReleaseTemplate) {
 
 	if release.Template != "" {
@@ -112,38 +128,30 @@ func (release *EstafetteRelease) InitFromTemplate(releaseTemplates map[string]*E
 
 		if releaseTemplate, found := releaseTemplates[release.Template]; found && releaseTemplate != nil {
 
-			// deep copy so there's no pointers shared with other releases
-			template := releaseTemplate.DeepCopy()
-
-			if release.Builder != nil {
-				template.Builder = release.Builder
-			} else {
-				release.Builder = template.Builder
-			}
-
-			if release.CloneRepository != nil {
-				template.CloneRepository = release.CloneRepository
-			} else {
-				release.CloneRepository = template.CloneRepository
-			}
-
-			if release.Actions != nil && len(release.Actions) > 0 {
-				template.Actions = release.Actions
-			} else {
-				release.Actions = template.Actions
-			}
-
-			if release.Triggers != nil && len(release.Triggers) > 0 {
-				template.Triggers = release.Triggers
-			} else {
-				release.Triggers = template.Triggers
+			// unknown or circular parent templates are reported by Validate
+			template, resolvedTemplates, err := releaseTemplate.resolve(releaseTemplates)
+			if err != nil {
+				return
 			}
 
-			if release.Stages != nil && len(release.Stages) > 0 {
-				template.Stages = release.Stages
-			} else {
-				release.Stages = template.Stages
+			own := EstafetteReleaseTemplate{
+				Builder:         release.Builder,
+				CloneRepository: release.CloneRepository,
+				Actions:         release.Actions,
+				Triggers:        release.Triggers,
+				EnvVars:         release.EnvVars,
+				Stages:          release.Stages,
+				StagesMerge:     release.StagesMerge,
 			}
+			own.inheritFrom(template)
+
+			release.Builder = own.Builder
+			release.CloneRepository = own.CloneRepository
+			release.Actions = own.Actions
+			release.Triggers = own.Triggers
+			release.EnvVars = own.EnvVars
+			release.Stages = own.Stages
+			release.ResolvedTemplates = resolvedTemplates
 		}
 	}
 }
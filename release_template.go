@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	"fmt"
+
 	"github.com/jinzhu/copier"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -12,7 +14,10 @@ type EstafetteReleaseTemplate struct {
 	CloneRepository *bool                     `yaml:"clone,omitempty" json:",omitempty"`
 	Actions         []*EstafetteReleaseAction `yaml:"actions,omitempty" json:",omitempty"`
 	Triggers        []*EstafetteTrigger       `yaml:"triggers,omitempty" json:",omitempty"`
+	EnvVars         map[string]string         `yaml:"env,omitempty" json:",omitempty"`
 	Stages          []*EstafetteStage         `yaml:"-"`
+	Template        string                    `yaml:"template,omitempty" json:",omitempty"`
+	StagesMerge     StagesMergeMode           `yaml:"stagesMerge,omitempty" json:",omitempty"`
 }
 
 // UnmarshalYAML customizes unmarshalling an EstafetteRelease
@@ -24,7 +29,10 @@ func (releaseTemplate *EstafetteReleaseTemplate) UnmarshalYAML(unmarshal func(in
 		CloneRepository *bool                     `yaml:"clone"`
 		Actions         []*EstafetteReleaseAction `yaml:"actions"`
 		Triggers        []*EstafetteTrigger       `yaml:"triggers"`
+		EnvVars         map[string]string         `yaml:"env"`
 		Stages          yaml.MapSlice             `yaml:"stages"`
+		Template        string                    `yaml:"template"`
+		StagesMerge     StagesMergeMode           `yaml:"stagesMerge"`
 	}
 
 	// unmarshal to auxiliary type
@@ -38,17 +46,20 @@ func (releaseTemplate *EstafetteReleaseTemplate) UnmarshalYAML(unmarshal func(in
 	releaseTemplate.CloneRepository = aux.CloneRepository
 	releaseTemplate.Actions = aux.Actions
 	releaseTemplate.Triggers = aux.Triggers
+	releaseTemplate.EnvVars = aux.EnvVars
+	releaseTemplate.Template = aux.Template
+	releaseTemplate.StagesMerge = aux.StagesMerge
 
 	for _, mi := range aux.Stages {
 
 		bytes, err := yaml.Marshal(mi.Value)
 		if err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("stages.%v", mi.Key), err)
 		}
 
 		var stage *EstafetteStage
 		if err := yaml.Unmarshal(bytes, &stage); err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("stages.%v", mi.Key), err)
 		}
 		if stage == nil {
 			stage = &EstafetteStage{}
@@ -72,7 +83,10 @@ func (releaseTemplate EstafetteReleaseTemplate) MarshalYAML() (out interface{},
 		CloneRepository *bool                     `yaml:"clone,omitempty"`
 		Actions         []*EstafetteReleaseAction `yaml:"actions,omitempty"`
 		Triggers        []*EstafetteTrigger       `yaml:"triggers,omitempty"`
+		EnvVars         map[string]string         `yaml:"env,omitempty"`
 		Stages          yaml.MapSlice             `yaml:"stages,omitempty"`
+		Template        string                    `yaml:"template,omitempty"`
+		StagesMerge     StagesMergeMode           `yaml:"stagesMerge,omitempty"`
 	}
 
 	// map auxiliary properties
@@ -80,6 +94,9 @@ func (releaseTemplate EstafetteReleaseTemplate) MarshalYAML() (out interface{},
 	aux.CloneRepository = releaseTemplate.CloneRepository
 	aux.Actions = releaseTemplate.Actions
 	aux.Triggers = releaseTemplate.Triggers
+	aux.EnvVars = releaseTemplate.EnvVars
+	aux.Template = releaseTemplate.Template
+	aux.StagesMerge = releaseTemplate.StagesMerge
 
 	for _, stage := range releaseTemplate.Stages {
 		aux.Stages = append(aux.Stages, yaml.MapItem{
@@ -98,3 +115,75 @@ func (releaseTemplate EstafetteReleaseTemplate) DeepCopy() (target EstafetteRele
 
 	return
 }
+
+// resolve returns a copy of the template with everything it inherits from its parent templates filled in, together with the names of all
+// templates involved, starting with this one
+func (releaseTemplate *EstafetteReleaseTemplate) resolve(releaseTemplates map[string]*EstafetteReleaseTemplate) (resolved EstafetteReleaseTemplate, resolvedTemplates []string, err error) {
+
+	template, resolvedTemplates, err := resolveTemplate("release", releaseTemplate, releaseTemplateLookup(releaseTemplates))
+	if err != nil {
+		return resolved, nil, err
+	}
+
+	return *template.(*EstafetteReleaseTemplate), resolvedTemplates, nil
+}
+
+// validate checks that the parent template exists and that the template doesn't inherit from itself
+func (releaseTemplate *EstafetteReleaseTemplate) validate(releaseTemplates map[string]*EstafetteReleaseTemplate) (err error) {
+
+	var errs ValidationErrors
+
+	errs.add("", validateTemplateParent("release", releaseTemplate, releaseTemplateLookup(releaseTemplates)))
+	errs.add("stagesMerge", validateStagesMerge(releaseTemplate.StagesMerge))
+
+	return errs.errorOrNil()
+}
+
+// inheritFrom uses the values of parent for everything the template doesn't set itself; env vars are merged key by key and stages are combined
+// according to StagesMerge
+func (releaseTemplate *EstafetteReleaseTemplate) inheritFrom(parent EstafetteReleaseTemplate) {
+	releaseTemplate.inheritedFields().inheritFrom(parent.inheritedFields())
+
+	if len(releaseTemplate.Actions) == 0 {
+		releaseTemplate.Actions = parent.Actions
+	}
+}
+
+func (releaseTemplate *EstafetteReleaseTemplate) templateName() string {
+	return releaseTemplate.Name
+}
+
+func (releaseTemplate *EstafetteReleaseTemplate) parentTemplateName() string {
+	return releaseTemplate.Template
+}
+
+func (releaseTemplate *EstafetteReleaseTemplate) deepCopy() inheritableTemplate {
+	copied := releaseTemplate.DeepCopy()
+	return &copied
+}
+
+func (releaseTemplate *EstafetteReleaseTemplate) inheritFromTemplate(parent inheritableTemplate) {
+	releaseTemplate.inheritFrom(*parent.(*EstafetteReleaseTemplate))
+}
+
+func (releaseTemplate *EstafetteReleaseTemplate) inheritedFields() inheritedTemplateFields {
+	return inheritedTemplateFields{
+		builder:         &releaseTemplate.Builder,
+		cloneRepository: &releaseTemplate.CloneRepository,
+		triggers:        &releaseTemplate.Triggers,
+		envVars:         &releaseTemplate.EnvVars,
+		stages:          &releaseTemplate.Stages,
+		stagesMerge:     releaseTemplate.StagesMerge,
+	}
+}
+
+// releaseTemplateLookup returns a lookup of release templates by name for resolveTemplate and validateTemplateParent
+func releaseTemplateLookup(releaseTemplates map[string]*EstafetteReleaseTemplate) templateLookup {
+	return func(name string) (inheritableTemplate, bool) {
+		releaseTemplate, found := releaseTemplates[name]
+		if !found || releaseTemplate == nil {
+			return nil, false
+		}
+		return releaseTemplate, true
+	}
+}
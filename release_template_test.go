@@ -0,0 +1,197 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseTemplateInheritance(t *testing.T) {
+	t.Run("ResolvesTheWholeTemplateChain", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+releaseTemplates:
+  base:
+    clone: true
+    actions:
+    - name: deploy-canary
+    - name: deploy-stable
+    stages:
+      deploy:
+        image: extensions/gke:stable
+        env:
+          REGION: europe-west1
+  gke-notify:
+    template: base
+    stagesMerge: append
+    stages:
+      notify:
+        image: extensions/slack-build-status:stable
+  gke-production:
+    template: gke-notify
+    stagesMerge: patch
+    stages:
+      deploy:
+        env:
+          REGION: europe-west4
+
+releases:
+  production:
+    template: gke-production
+  staging:
+    template: base`, true)
+
+		assert.Nil(t, err)
+
+		production := manifest.Releases[0]
+		assert.Equal(t, []string{"gke-production", "gke-notify", "base"}, production.ResolvedTemplates)
+		assert.True(t, *production.CloneRepository)
+		assert.Equal(t, 2, len(production.Actions))
+		if assert.Equal(t, 2, len(production.Stages)) {
+			assert.Equal(t, "deploy", production.Stages[0].Name)
+			assert.Equal(t, "extensions/gke:stable", production.Stages[0].ContainerImage)
+			assert.Equal(t, "europe-west4", production.Stages[0].EnvVars["REGION"])
+			assert.Equal(t, "notify", production.Stages[1].Name)
+		}
+
+		staging := manifest.Releases[1]
+		assert.Equal(t, []string{"base"}, staging.ResolvedTemplates)
+		if assert.Equal(t, 1, len(staging.Stages)) {
+			assert.Equal(t, "europe-west1", staging.Stages[0].EnvVars["REGION"])
+		}
+	})
+
+	t.Run("LeavesResolvedTemplatesEmptyForReleasesWithoutTemplate", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifestFromFile(GetDefaultManifestPreferences(), "test-manifest.yaml", true)
+
+		assert.Nil(t, err)
+		for _, r := range manifest.Releases {
+			assert.Equal(t, 0, len(r.ResolvedTemplates))
+		}
+	})
+
+	t.Run("ReturnsErrorForUnknownTemplateOfRelease", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+releaseTemplates:
+  gke:
+    stages:
+      deploy:
+        image: extensions/gke:stable
+
+releases:
+  production:
+    template: gek
+    stages:
+      deploy:
+        image: extensions/gke:stable`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "releases.production.template", errs[0].Path)
+				assert.Equal(t, "Release production uses unknown release template gek", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForUnknownParentTemplate", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+releaseTemplates:
+  gke:
+    template: bsae
+    stages:
+      deploy:
+        image: extensions/gke:stable`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "releaseTemplates.gke.template", errs[0].Path)
+				assert.Equal(t, "Release template gke uses unknown release template bsae", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForTemplatesInheritingFromThemselves", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+releaseTemplates:
+  a:
+    template: b
+  b:
+    template: a
+
+releases:
+  production:
+    template: a
+    stages:
+      deploy:
+        image: extensions/gke:stable`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 2, len(errs)) {
+				assert.Equal(t, "releaseTemplates.a.template", errs[0].Path)
+				assert.Equal(t, "Release template a inherits from itself through a -> b -> a", errs[0].Message)
+				assert.Equal(t, "releaseTemplates.b.template", errs[1].Path)
+			}
+		}
+		assert.Equal(t, 0, len(manifest.Releases[0].ResolvedTemplates))
+	})
+
+	t.Run("MergesEnvVarsKeyByKeyThroughTheTemplateChain", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+releaseTemplates:
+  base:
+    env:
+      REGION: europe-west1
+      ZONE: europe-west1-b
+    stages:
+      deploy:
+        image: extensions/gke:stable
+  production:
+    template: base
+    env:
+      ZONE: europe-west1-c
+      REPLICAS: "3"
+
+releases:
+  production:
+    template: production
+    env:
+      REPLICAS: "5"`, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"REGION": "europe-west1", "ZONE": "europe-west1-c", "REPLICAS": "5"}, manifest.Releases[0].EnvVars)
+	})
+}
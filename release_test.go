@@ -58,3 +58,164 @@ func TestReleaseToYamlMarshalling(t *testing.T) {
 		assert.Equal(t, input, string(output))
 	})
 }
+
+func TestInitFromTemplate(t *testing.T) {
+
+	template := `
+releaseTemplates:
+  gke:
+    stages:
+      deploy:
+        image: extensions/gke:stable
+        env:
+          REGION: europe-west1
+          ZONE: europe-west1-b
+        container:
+          repository: extensions
+          cpu:
+            request: 100m
+            limit: 200m
+      notify:
+        image: extensions/slack-build-status:stable
+`
+
+	tests := []struct {
+		name          string
+		release       string
+		expectedNames []string
+		assertStages  func(t *testing.T, stages []*EstafetteStage)
+	}{
+		{
+			name: "ReplacesAllTemplateStagesByDefault",
+			release: `
+releases:
+  production:
+    template: gke
+    stages:
+      deploy:
+        image: extensions/gke:dev`,
+			expectedNames: []string{"deploy"},
+			assertStages: func(t *testing.T, stages []*EstafetteStage) {
+				assert.Equal(t, "extensions/gke:dev", stages[0].ContainerImage)
+				assert.Equal(t, 0, len(stages[0].EnvVars))
+			},
+		},
+		{
+			name: "ReplacesAllTemplateStagesForReplace",
+			release: `
+releases:
+  production:
+    template: gke
+    stagesMerge: replace
+    stages:
+      smoke-test:
+        image: extensions/smoke-test:stable`,
+			expectedNames: []string{"smoke-test"},
+		},
+		{
+			name: "UsesTemplateStagesIfReleaseHasNone",
+			release: `
+releases:
+  production:
+    template: gke
+    stagesMerge: patch`,
+			expectedNames: []string{"deploy", "notify"},
+		},
+		{
+			name: "AddsStagesAfterTemplateStagesForAppend",
+			release: `
+releases:
+  production:
+    template: gke
+    stagesMerge: append
+    stages:
+      smoke-test:
+        image: extensions/smoke-test:stable
+      deploy:
+        image: extensions/gke:dev`,
+			expectedNames: []string{"deploy", "notify", "smoke-test"},
+			assertStages: func(t *testing.T, stages []*EstafetteStage) {
+				// a stage with the same name replaces the template stage as a whole
+				assert.Equal(t, "extensions/gke:dev", stages[0].ContainerImage)
+				assert.Equal(t, 0, len(stages[0].EnvVars))
+				assert.Equal(t, 0, len(stages[0].CustomProperties))
+			},
+		},
+		{
+			name: "MergesStagesWithTheSameNameForPatch",
+			release: `
+releases:
+  production:
+    template: gke
+    stagesMerge: patch
+    stages:
+      deploy:
+        env:
+          ZONE: europe-west1-c
+        container:
+          cpu:
+            limit: 500m
+      smoke-test:
+        image: extensions/smoke-test:stable`,
+			expectedNames: []string{"deploy", "notify", "smoke-test"},
+			assertStages: func(t *testing.T, stages []*EstafetteStage) {
+				assert.Equal(t, "extensions/gke:stable", stages[0].ContainerImage)
+				assert.Equal(t, map[string]string{"REGION": "europe-west1", "ZONE": "europe-west1-c"}, stages[0].EnvVars)
+				assert.Equal(t, map[string]interface{}{
+					"repository": "extensions",
+					"cpu": map[string]interface{}{
+						"request": "100m",
+						"limit":   "500m",
+					},
+				}, stages[0].CustomProperties["container"])
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var manifest EstafetteManifest
+
+			// act
+			err := yaml.Unmarshal([]byte(template+tt.release), &manifest)
+
+			assert.Nil(t, err)
+			stages := manifest.Releases[0].Stages
+			names := []string{}
+			for _, s := range stages {
+				names = append(names, s.Name)
+			}
+			assert.Equal(t, tt.expectedNames, names)
+			if tt.assertStages != nil {
+				tt.assertStages(t, stages)
+			}
+
+			// applying the template again, for instance after resolving includes, doesn't change the release anymore
+			before := manifest.Releases[0].DeepCopy()
+			manifest.Releases[0].InitFromTemplate(map[string]*EstafetteReleaseTemplate{"gke": manifest.ReleaseTemplates[0]})
+			assert.Equal(t, before.Stages, manifest.Releases[0].Stages)
+		})
+	}
+
+	t.Run("ReturnsErrorForUnknownStagesMerge", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+`+template+`
+releases:
+  production:
+    template: gke
+    stagesMerge: merge`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "releases.production.stagesMerge", errs[0].Path)
+			}
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package manifest
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+)
+
+// Quantity is an amount of cpu or memory in kubernetes syntax, like 100m, 0.5, 1e3 or 256Mi
+type Quantity string
+
+// EstafetteResource holds the amount of cpu or memory a stage or service requests and the amount it's limited to
+type EstafetteResource struct {
+	Request Quantity `yaml:"request,omitempty" json:",omitempty"`
+	Limit   Quantity `yaml:"limit,omitempty" json:",omitempty"`
+}
+
+var quantityRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]*)?|\.[0-9]+)(?:([eE][+-]?[0-9]+)|(Ki|Mi|Gi|Ti|Pi|Ei|n|u|m|k|M|G|T|P|E))?$`)
+
+var quantitySuffixes = map[string]*big.Rat{
+	"n":  big.NewRat(1, 1000000000),
+	"u":  big.NewRat(1, 1000000),
+	"m":  big.NewRat(1, 1000),
+	"k":  big.NewRat(1000, 1),
+	"M":  new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(6), nil)),
+	"G":  new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(9), nil)),
+	"T":  new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(12), nil)),
+	"P":  new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(15), nil)),
+	"E":  new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)),
+	"Ki": new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 10)),
+	"Mi": new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 20)),
+	"Gi": new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 30)),
+	"Ti": new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 40)),
+	"Pi": new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 50)),
+	"Ei": new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 60)),
+}
+
+// Value returns the quantity as a number, so 100m is 1/10 and 1Ki is 1024
+func (q Quantity) Value() (*big.Rat, error) {
+
+	matches := quantityRegex.FindStringSubmatch(string(q))
+	if matches == nil {
+		return nil, fmt.Errorf("Quantity %v is not valid, use for instance 100m, 0.5 or 256Mi", q)
+	}
+
+	value, ok := new(big.Rat).SetString(matches[1] + matches[2])
+	if !ok {
+		return nil, fmt.Errorf("Quantity %v is not valid, use for instance 100m, 0.5 or 256Mi", q)
+	}
+	if suffix, ok := quantitySuffixes[matches[3]]; ok {
+		value.Mul(value, suffix)
+	}
+
+	return value, nil
+}
+
+// Cmp compares q to other like big.Rat.Cmp does; quantities that aren't valid count as zero
+func (q Quantity) Cmp(other Quantity) int {
+	v, err := q.Value()
+	if err != nil {
+		v = new(big.Rat)
+	}
+	o, err := other.Value()
+	if err != nil {
+		o = new(big.Rat)
+	}
+	return v.Cmp(o)
+}
+
+// withDefaults returns resource with the request and limit it doesn't set taken from defaults; a default request above the limit is lowered
+// to the limit and a default limit below the request is raised to the request
+func (resource *EstafetteResource) withDefaults(defaults *EstafetteResource) *EstafetteResource {
+
+	if defaults == nil {
+		return resource
+	}
+	if resource == nil {
+		return &EstafetteResource{Request: defaults.Request, Limit: defaults.Limit}
+	}
+
+	merged := &EstafetteResource{Request: resource.Request, Limit: resource.Limit}
+	if merged.Request == "" {
+		merged.Request = defaults.Request
+		if merged.Limit != "" && merged.Request.Cmp(merged.Limit) > 0 {
+			merged.Request = merged.Limit
+		}
+	}
+	if merged.Limit == "" {
+		merged.Limit = defaults.Limit
+		if merged.Limit != "" && merged.Request != "" && merged.Limit.Cmp(merged.Request) < 0 {
+			merged.Limit = merged.Request
+		}
+	}
+
+	return merged
+}
+
+// validate checks the request and limit are valid quantities and the request isn't more than the limit
+func (resource *EstafetteResource) validate(name string) (err error) {
+
+	var errs ValidationErrors
+
+	valid := true
+	if resource.Request != "" {
+		if _, err := resource.Request.Value(); err != nil {
+			errs.add("request", err)
+			valid = false
+		}
+	}
+	if resource.Limit != "" {
+		if _, err := resource.Limit.Value(); err != nil {
+			errs.add("limit", err)
+			valid = false
+		}
+	}
+
+	if valid && resource.Request != "" && resource.Limit != "" && resource.Request.Cmp(resource.Limit) > 0 {
+		errs.addf("request", "The %v request %v is more than the %v limit %v", name, resource.Request, name, resource.Limit)
+	}
+
+	return errs.errorOrNil()
+}
+
+// validateMaximum checks neither the request nor the limit is more than maximum
+func (resource *EstafetteResource) validateMaximum(name string, maximum Quantity) (err error) {
+
+	var errs ValidationErrors
+
+	if resource == nil || maximum == "" {
+		return nil
+	}
+	if resource.Request != "" && resource.Request.Cmp(maximum) > 0 {
+		errs.addf("request", "The %v request %v is more than the maximum of %v", name, resource.Request, maximum)
+	}
+	if resource.Limit != "" && resource.Limit.Cmp(maximum) > 0 {
+		errs.addf("limit", "The %v limit %v is more than the maximum of %v", name, resource.Limit, maximum)
+	}
+
+	return errs.errorOrNil()
+}
+
+// validateResources checks the cpu and memory shared by stages and services
+func validateResources(cpu, memory *EstafetteResource) (err error) {
+
+	var errs ValidationErrors
+
+	if cpu != nil {
+		errs.add("cpu", cpu.validate("cpu"))
+	}
+	if memory != nil {
+		errs.add("memory", memory.validate("memory"))
+	}
+
+	return errs.errorOrNil()
+}
+
+// validateResourceMaximums checks the cpu and memory shared by stages and services against the maximums in preferences
+func validateResourceMaximums(cpu, memory *EstafetteResource, preferences EstafetteManifestPreferences) (err error) {
+
+	var errs ValidationErrors
+
+	errs.add("cpu", cpu.validateMaximum("cpu", preferences.MaxCPU))
+	errs.add("memory", memory.validateMaximum("memory", preferences.MaxMemory))
+
+	return errs.errorOrNil()
+}
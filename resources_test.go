@@ -0,0 +1,171 @@
+package manifest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantityValue(t *testing.T) {
+
+	tests := []struct {
+		quantity Quantity
+		expected *big.Rat
+	}{
+		{"100m", big.NewRat(1, 10)},
+		{"0.5", big.NewRat(1, 2)},
+		{"2", big.NewRat(2, 1)},
+		{"1e3", big.NewRat(1000, 1)},
+		{"1k", big.NewRat(1000, 1)},
+		{"256Mi", big.NewRat(256*1024*1024, 1)},
+		{"1G", big.NewRat(1000000000, 1)},
+		{"1E", big.NewRat(1000000000000000000, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.quantity), func(t *testing.T) {
+
+			// act
+			value, err := tt.quantity.Value()
+
+			assert.Nil(t, err)
+			assert.Equal(t, 0, tt.expected.Cmp(value), "expected %v, got %v", tt.expected, value)
+		})
+	}
+
+	t.Run("ReturnsErrorForInvalidQuantity", func(t *testing.T) {
+
+		// act
+		_, err := Quantity("256MB").Value()
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Quantity 256MB is not valid, use for instance 100m, 0.5 or 256Mi", err.Error())
+		}
+	})
+}
+
+const resourcesManifest = `
+stages:
+  build:
+    image: golang:1.17-alpine
+    cpu:
+      request: 500m
+      limit: 2
+    memory:
+      request: 256Mi
+    services:
+    - name: database
+      image: postgres:14
+      memory:
+        limit: 1Gi
+  test:
+    cpu:
+      limit: 1
+    parallelStages:
+      unit:
+        image: golang:1.17-alpine
+      integration:
+        image: golang:1.17-alpine
+        cpu:
+          request: 1500m`
+
+func TestResources(t *testing.T) {
+	t.Run("ReturnsTypedCpuAndMemory", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, resourcesManifest, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &EstafetteResource{Request: "500m", Limit: "2"}, manifest.Stages[0].CPU)
+		assert.Equal(t, &EstafetteResource{Request: "256Mi"}, manifest.Stages[0].Memory)
+		assert.Equal(t, &EstafetteResource{Limit: "1Gi"}, manifest.Stages[0].Services[0].Memory)
+		assert.Nil(t, manifest.Stages[0].CustomProperties["cpu"])
+	})
+
+	t.Run("ParallelStagesInheritResourcesTheyDoNotSet", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, resourcesManifest, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &EstafetteResource{Limit: "1"}, manifest.Stages[1].ParallelStages[0].CPU)
+		assert.Equal(t, &EstafetteResource{Request: "1500m"}, manifest.Stages[1].ParallelStages[1].CPU)
+	})
+
+	t.Run("SetsDefaultsFromPreferences", func(t *testing.T) {
+
+		preferences := GetDefaultManifestPreferences()
+		preferences.DefaultCPU = &EstafetteResource{Request: "200m", Limit: "1"}
+		preferences.DefaultMemory = &EstafetteResource{Request: "128Mi", Limit: "512Mi"}
+
+		// act
+		manifest, err := ReadManifest(preferences, resourcesManifest, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &EstafetteResource{Request: "500m", Limit: "2"}, manifest.Stages[0].CPU)
+		assert.Equal(t, &EstafetteResource{Request: "256Mi", Limit: "512Mi"}, manifest.Stages[0].Memory)
+		assert.Equal(t, &EstafetteResource{Request: "128Mi", Limit: "1Gi"}, manifest.Stages[0].Services[0].Memory)
+		assert.Equal(t, &EstafetteResource{Request: "200m", Limit: "1"}, manifest.Stages[0].Services[0].CPU)
+		// a stage grouping parallel stages doesn't run itself
+		assert.Equal(t, &EstafetteResource{Limit: "1"}, manifest.Stages[1].CPU)
+		assert.Nil(t, manifest.Stages[1].Memory)
+		assert.Equal(t, &EstafetteResource{Request: "200m", Limit: "1"}, manifest.Stages[1].ParallelStages[0].CPU)
+		// the default limit is raised to the request
+		assert.Equal(t, &EstafetteResource{Request: "1500m", Limit: "1500m"}, manifest.Stages[1].ParallelStages[1].CPU)
+	})
+
+	t.Run("ReturnsErrorsForInvalidQuantitiesAndRequestsAboveLimits", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+    cpu:
+      request: 2
+      limit: 500m
+    memory:
+      request: 256MB
+    services:
+    - name: database
+      image: postgres:14
+      memory:
+        request: 2Gi
+        limit: 1Gi`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 3, len(errs)) {
+				assert.Equal(t, "stages.build.cpu.request", errs[0].Path)
+				assert.Equal(t, "The cpu request 2 is more than the cpu limit 500m", errs[0].Message)
+				assert.Equal(t, "stages.build.memory.request", errs[1].Path)
+				assert.Equal(t, "Quantity 256MB is not valid, use for instance 100m, 0.5 or 256Mi", errs[1].Message)
+				assert.Equal(t, "stages.build.services[0].memory.request", errs[2].Path)
+				assert.Equal(t, "The memory request 2Gi is more than the memory limit 1Gi", errs[2].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorsForResourcesAboveTheMaximumsInPreferences", func(t *testing.T) {
+
+		preferences := GetDefaultManifestPreferences()
+		preferences.MaxCPU = "1"
+		preferences.MaxMemory = "512Mi"
+
+		// act
+		_, err := ReadManifest(preferences, resourcesManifest, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 3, len(errs)) {
+				assert.Equal(t, "stages.build.cpu.limit", errs[0].Path)
+				assert.Equal(t, "The cpu limit 2 is more than the maximum of 1", errs[0].Message)
+				assert.Equal(t, "stages.build.services[0].memory.limit", errs[1].Path)
+				assert.Equal(t, "The memory limit 1Gi is more than the maximum of 512Mi", errs[1].Message)
+				assert.Equal(t, "stages.test.parallelStages.integration.cpu.request", errs[2].Path)
+				assert.Equal(t, "The cpu request 1500m is more than the maximum of 1", errs[2].Message)
+			}
+		}
+	})
+}
@@ -12,6 +12,11 @@ type EstafetteService struct {
 	EnvVars                 map[string]string      `yaml:"env,omitempty"`
 	Readiness               *ReadinessProbe        `yaml:"readiness,omitempty"`
 	ReadinessProbe          *ReadinessProbe        `yaml:"readinessProbe,omitempty"`
+	Timeout                 *Duration              `yaml:"timeout,omitempty" json:",omitempty"`
+	Retries                 *EstafetteRetries      `yaml:"retries,omitempty" json:",omitempty"`
+	AllowFailure            *bool                  `yaml:"allowFailure,omitempty" json:",omitempty"`
+	CPU                     *EstafetteResource     `yaml:"cpu,omitempty" json:",omitempty"`
+	Memory                  *EstafetteResource     `yaml:"memory,omitempty" json:",omitempty"`
 	CustomProperties        map[string]interface{} `yaml:",inline"`
 }
 
@@ -26,6 +31,42 @@ type ReadinessProbe struct {
 	Port     int    `yaml:"port,omitempty"`     // httpGet.port
 	Protocol string `yaml:"protocol,omitempty"` // httpGet.scheme
 	Hostname string `yaml:"hostname,omitempty"` // httpGet.host
+
+	// SetsDeprecatedFields records whether the manifest itself sets any of the deprecated fields, since SetDefaults fills them in as well
+	SetsDeprecatedFields bool `yaml:"-" json:"-"`
+}
+
+// UnmarshalYAML customizes unmarshalling a ReadinessProbe
+func (readiness *ReadinessProbe) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
+
+	var aux struct {
+		HttpGet        *HttpGetProbe `yaml:"httpGet,omitempty"`
+		Exec           *ExecProbe    `yaml:"exec,omitempty"`
+		TimeoutSeconds int           `yaml:"timeoutSeconds,omitempty"`
+		Path           string        `yaml:"path,omitempty"`
+		Port           int           `yaml:"port,omitempty"`
+		Protocol       string        `yaml:"protocol,omitempty"`
+		Hostname       string        `yaml:"hostname,omitempty"`
+	}
+
+	// unmarshal to auxiliary type
+	if err := unmarshal(&aux); err != nil {
+		return err
+	}
+
+	// map auxiliary properties
+	readiness.HttpGet = aux.HttpGet
+	readiness.Exec = aux.Exec
+	readiness.TimeoutSeconds = aux.TimeoutSeconds
+	readiness.Path = aux.Path
+	readiness.Port = aux.Port
+	readiness.Protocol = aux.Protocol
+	readiness.Hostname = aux.Hostname
+
+	// record this before SetDefaults fills in the deprecated fields; as an exported field it survives deep copies
+	readiness.SetsDeprecatedFields = readiness.usesDeprecatedFields()
+
+	return nil
 }
 
 type HttpGetProbe struct {
@@ -53,6 +94,11 @@ func (service *EstafetteService) UnmarshalYAML(unmarshal func(interface{}) error
 		EnvVars                 map[string]string      `yaml:"env,omitempty"`
 		Readiness               *ReadinessProbe        `yaml:"readiness,omitempty"`
 		ReadinessProbe          *ReadinessProbe        `yaml:"readinessProbe,omitempty"`
+		Timeout                 *Duration              `yaml:"timeout,omitempty"`
+		Retries                 *EstafetteRetries      `yaml:"retries,omitempty"`
+		AllowFailure            *bool                  `yaml:"allowFailure,omitempty"`
+		CPU                     *EstafetteResource     `yaml:"cpu,omitempty"`
+		Memory                  *EstafetteResource     `yaml:"memory,omitempty"`
 		CustomProperties        map[string]interface{} `yaml:",inline"`
 	}
 
@@ -72,6 +118,11 @@ func (service *EstafetteService) UnmarshalYAML(unmarshal func(interface{}) error
 	service.EnvVars = aux.EnvVars
 	service.Readiness = aux.Readiness
 	service.ReadinessProbe = aux.ReadinessProbe
+	service.Timeout = aux.Timeout
+	service.Retries = aux.Retries
+	service.AllowFailure = aux.AllowFailure
+	service.CPU = aux.CPU
+	service.Memory = aux.Memory
 
 	// fix for map[interface{}]interface breaking json.marshal - see https://github.com/go-yaml/yaml/issues/139
 	service.CustomProperties = cleanUpStringMap(aux.CustomProperties)
@@ -114,6 +165,25 @@ func (service *EstafetteService) SetDefaults(builder EstafetteBuilder, parentSta
 	}
 }
 
+// Validate checks whether the service has valid parameters
+func (service *EstafetteService) Validate() (err error) {
+
+	var errs ValidationErrors
+
+	if service.ContainerImage == "" {
+		errs.addf("image", "Service %v has no image set", service.Name)
+	}
+	errs.add("", validateExecutionLimits(service.Timeout, service.Retries))
+	errs.add("", validateResources(service.CPU, service.Memory))
+	if service.When != "" {
+		if _, err := ParseWhen(service.When); err != nil {
+			errs.addf("when", "Service %v has an invalid when expression: %v", service.Name, err)
+		}
+	}
+
+	return errs.errorOrNil()
+}
+
 // SetDefaults sets default values for properties of EstafetteService if not defined
 func (readiness *ReadinessProbe) SetDefaults(serviceName string) {
 
@@ -156,3 +226,13 @@ func (readiness *ReadinessProbe) SetDefaults(serviceName string) {
 		}
 	}
 }
+
+// usesDeprecatedFields checks whether the probe uses the legacy path, port, protocol and hostname fields instead of httpGet or exec
+func (readiness *ReadinessProbe) usesDeprecatedFields() bool {
+	return readiness.Path != "" || readiness.Port != 0 || readiness.Protocol != "" || readiness.Hostname != ""
+}
+
+// setsDeprecatedFieldsExplicitly checks whether the manifest itself sets the legacy fields, ignoring the defaults SetDefaults fills in for them
+func (readiness *ReadinessProbe) setsDeprecatedFieldsExplicitly() bool {
+	return readiness.SetsDeprecatedFields
+}
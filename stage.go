@@ -15,10 +15,20 @@ type EstafetteStage struct {
 	Commands                []string               `yaml:"commands,omitempty" json:",omitempty"`
 	RunCommandsInForeground bool                   `yaml:"runCommandsInForeground,omitempty" json:",omitempty"`
 	When                    string                 `yaml:"when,omitempty" json:",omitempty"`
+	DependsOn               []string               `yaml:"dependsOn,omitempty" json:",omitempty"`
+	Matrix                  *EstafetteMatrix       `yaml:"matrix,omitempty" json:",omitempty"`
+	MatrixValues            map[string]string      `yaml:"-" json:",omitempty"`
+	Timeout                 *Duration              `yaml:"timeout,omitempty" json:",omitempty"`
+	Retries                 *EstafetteRetries      `yaml:"retries,omitempty" json:",omitempty"`
+	AllowFailure            *bool                  `yaml:"allowFailure,omitempty" json:",omitempty"`
+	CPU                     *EstafetteResource     `yaml:"cpu,omitempty" json:",omitempty"`
+	Memory                  *EstafetteResource     `yaml:"memory,omitempty" json:",omitempty"`
 	EnvVars                 map[string]string      `yaml:"env,omitempty" json:",omitempty"`
 	AutoInjected            bool                   `yaml:"autoInjected,omitempty" json:",omitempty"`
 	ParallelStages          []*EstafetteStage      `yaml:"parallelStages,omitempty" json:",omitempty"`
 	Services                []*EstafetteService    `yaml:"services,omitempty" json:",omitempty"`
+	Template                string                 `yaml:"template,omitempty" json:",omitempty"`
+	With                    map[string]string      `yaml:"with,omitempty" json:",omitempty"`
 	CustomProperties        map[string]interface{} `yaml:",inline" json:",omitempty"`
 }
 
@@ -33,10 +43,19 @@ func (stage *EstafetteStage) UnmarshalYAML(unmarshal func(interface{}) error) (e
 		Commands                []string               `yaml:"commands,omitempty"`
 		RunCommandsInForeground bool                   `yaml:"runCommandsInForeground,omitempty"`
 		When                    string                 `yaml:"when,omitempty"`
+		DependsOn               []string               `yaml:"dependsOn,omitempty"`
+		Matrix                  *EstafetteMatrix       `yaml:"matrix,omitempty"`
+		Timeout                 *Duration              `yaml:"timeout,omitempty"`
+		Retries                 *EstafetteRetries      `yaml:"retries,omitempty"`
+		AllowFailure            *bool                  `yaml:"allowFailure,omitempty"`
+		CPU                     *EstafetteResource     `yaml:"cpu,omitempty"`
+		Memory                  *EstafetteResource     `yaml:"memory,omitempty"`
 		EnvVars                 map[string]string      `yaml:"env,omitempty"`
 		AutoInjected            bool                   `yaml:"autoInjected,omitempty"`
 		ParallelStages          yaml.MapSlice          `yaml:"parallelStages"`
 		Services                []*EstafetteService    `yaml:"services,omitempty"`
+		Template                string                 `yaml:"template,omitempty"`
+		With                    map[string]string      `yaml:"with,omitempty"`
 		CustomProperties        map[string]interface{} `yaml:",inline"`
 	}
 
@@ -53,20 +72,29 @@ func (stage *EstafetteStage) UnmarshalYAML(unmarshal func(interface{}) error) (e
 	stage.Commands = aux.Commands
 	stage.RunCommandsInForeground = aux.RunCommandsInForeground
 	stage.When = aux.When
+	stage.DependsOn = aux.DependsOn
+	stage.Matrix = aux.Matrix
+	stage.Timeout = aux.Timeout
+	stage.Retries = aux.Retries
+	stage.AllowFailure = aux.AllowFailure
+	stage.CPU = aux.CPU
+	stage.Memory = aux.Memory
 	stage.EnvVars = aux.EnvVars
 	stage.AutoInjected = aux.AutoInjected
 	stage.Services = aux.Services
+	stage.Template = aux.Template
+	stage.With = aux.With
 
 	for _, mi := range aux.ParallelStages {
 
 		bytes, err := yaml.Marshal(mi.Value)
 		if err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("parallelStages.%v", mi.Key), err)
 		}
 
 		var innerStage *EstafetteStage
 		if err := yaml.Unmarshal(bytes, &innerStage); err != nil {
-			return err
+			return wrapUnmarshalError(fmt.Sprintf("parallelStages.%v", mi.Key), err)
 		}
 		if innerStage == nil {
 			innerStage = &EstafetteStage{}
@@ -109,8 +137,23 @@ func (stage *EstafetteStage) SetDefaults(builder EstafetteBuilder) {
 		stage.When = "status == 'succeeded'"
 	}
 
-	// set defaults for inner stages
+	// set defaults for inner stages, which run with the limits of the stage grouping them unless they set their own
 	for _, s := range stage.ParallelStages {
+		if s.Timeout == nil {
+			s.Timeout = stage.Timeout
+		}
+		if s.Retries == nil {
+			s.Retries = stage.Retries
+		}
+		if s.AllowFailure == nil {
+			s.AllowFailure = stage.AllowFailure
+		}
+		if s.CPU == nil {
+			s.CPU = stage.CPU
+		}
+		if s.Memory == nil {
+			s.Memory = stage.Memory
+		}
 		s.SetDefaults(builder)
 	}
 
@@ -119,30 +162,176 @@ func (stage *EstafetteStage) SetDefaults(builder EstafetteBuilder) {
 	}
 }
 
+// patch fills in everything the stage doesn't set itself from base; env vars and custom properties are merged key by key and parallel stages
+// by name, so patching a stage with the same base again doesn't change it anymore
+func (stage *EstafetteStage) patch(base *EstafetteStage) {
+
+	if stage.ContainerImage == "" {
+		stage.ContainerImage = base.ContainerImage
+	}
+	if stage.Shell == "" {
+		stage.Shell = base.Shell
+	}
+	if stage.WorkingDirectory == "" {
+		stage.WorkingDirectory = base.WorkingDirectory
+	}
+	if len(stage.Commands) == 0 {
+		stage.Commands = base.Commands
+	}
+	if !stage.RunCommandsInForeground {
+		stage.RunCommandsInForeground = base.RunCommandsInForeground
+	}
+	if stage.When == "" {
+		stage.When = base.When
+	}
+	if len(stage.DependsOn) == 0 {
+		stage.DependsOn = base.DependsOn
+	}
+	if stage.Matrix == nil {
+		stage.Matrix = base.Matrix
+	}
+	if stage.Timeout == nil {
+		stage.Timeout = base.Timeout
+	}
+	if stage.Retries == nil {
+		stage.Retries = base.Retries
+	}
+	if stage.AllowFailure == nil {
+		stage.AllowFailure = base.AllowFailure
+	}
+	if stage.CPU == nil {
+		stage.CPU = base.CPU
+	}
+	if stage.Memory == nil {
+		stage.Memory = base.Memory
+	}
+	if len(stage.Services) == 0 {
+		stage.Services = base.Services
+	}
+	if len(base.ParallelStages) > 0 {
+		stage.ParallelStages = mergeStages(base.ParallelStages, stage.ParallelStages, true)
+	}
+
+	if len(base.EnvVars) > 0 {
+		stage.EnvVars = mergeEnvVars(base.EnvVars, stage.EnvVars)
+	}
+
+	if len(base.CustomProperties) > 0 {
+		stage.CustomProperties = mergeCustomProperties(base.CustomProperties, stage.CustomProperties)
+	}
+}
+
+// mergeStages combines base stages with stages overriding them by name; overriding stages replace the base stage, or patch it if patch is
+// true, and stages not in base are added at the end
+func mergeStages(base, stages []*EstafetteStage, patch bool) []*EstafetteStage {
+
+	overrides := map[string]*EstafetteStage{}
+	for _, s := range stages {
+		overrides[s.Name] = s
+	}
+
+	merged := []*EstafetteStage{}
+	inBase := map[string]bool{}
+	for _, b := range base {
+		inBase[b.Name] = true
+
+		override, ok := overrides[b.Name]
+		if !ok {
+			merged = append(merged, b)
+			continue
+		}
+		if patch {
+			override.patch(b)
+		}
+		merged = append(merged, override)
+	}
+
+	for _, s := range stages {
+		if !inBase[s.Name] {
+			merged = append(merged, s)
+		}
+	}
+
+	return merged
+}
+
+// mergeCustomProperties deep merges custom properties, with the ones in override taking precedence
+func mergeCustomProperties(base, override map[string]interface{}) map[string]interface{} {
+
+	merged := map[string]interface{}{}
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range override {
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		overrideMap, overrideIsMap := value.(map[string]interface{})
+		if baseIsMap && overrideIsMap {
+			merged[key] = mergeCustomProperties(baseMap, overrideMap)
+			continue
+		}
+		merged[key] = value
+	}
+
+	return merged
+}
+
 // Validate checks whether the stage has valid parameters
 func (stage *EstafetteStage) Validate() (err error) {
 
+	var errs ValidationErrors
+
 	if len(stage.ParallelStages) > 0 {
 		if stage.ContainerImage != "" {
-			return fmt.Errorf("Stage %v cannot use parameters parallelStages and image at the same time", stage.Name)
+			errs.addf("image", "Stage %v cannot use parameters parallelStages and image at the same time", stage.Name)
 		}
 		if stage.Shell != "" {
-			return fmt.Errorf("Stage %v cannot use parameters parallelStages and shell at the same time", stage.Name)
+			errs.addf("shell", "Stage %v cannot use parameters parallelStages and shell at the same time", stage.Name)
 		}
 		if stage.WorkingDirectory != "" {
-			return fmt.Errorf("Stage %v cannot use parameters parallelStages and workDir at the same time", stage.Name)
+			errs.addf("workDir", "Stage %v cannot use parameters parallelStages and workDir at the same time", stage.Name)
 		}
 		if len(stage.Commands) > 0 {
-			return fmt.Errorf("Stage %v cannot use parameters parallelStages and commands at the same time", stage.Name)
+			errs.addf("commands", "Stage %v cannot use parameters parallelStages and commands at the same time", stage.Name)
 		}
 		if len(stage.EnvVars) > 0 {
-			return fmt.Errorf("Stage %v cannot use parameters parallelStages and env at the same time", stage.Name)
+			errs.addf("env", "Stage %v cannot use parameters parallelStages and env at the same time", stage.Name)
 		}
 	} else {
 		if stage.ContainerImage == "" && len(stage.Services) == 0 {
-			return fmt.Errorf("Stage %v has no image set", stage.Name)
+			errs.addf("image", "Stage %v has no image set", stage.Name)
 		}
 	}
 
-	return nil
+	if stage.When != "" {
+		if _, err := ParseWhen(stage.When); err != nil {
+			errs.addf("when", "Stage %v has an invalid when expression: %v", stage.Name, err)
+		}
+	}
+
+	errs.add("", validateExecutionLimits(stage.Timeout, stage.Retries))
+	errs.add("", validateResources(stage.CPU, stage.Memory))
+
+	if stage.Matrix != nil {
+		errs.add("matrix", stage.Matrix.validate(stage.Name))
+		for _, s := range stage.ParallelStages {
+			if s.MatrixValues == nil {
+				errs.addf("matrix", "Stage %v cannot use parameters matrix and parallelStages at the same time", stage.Name)
+				break
+			}
+		}
+	}
+
+	for _, s := range stage.ParallelStages {
+		if s.Matrix != nil {
+			errs.addf(fmt.Sprintf("parallelStages.%v.matrix", s.Name), "Parallel stage %v cannot use matrix", s.Name)
+		}
+		errs.add(fmt.Sprintf("parallelStages.%v", s.Name), s.Validate())
+	}
+
+	for i, svc := range stage.Services {
+		errs.add(fmt.Sprintf("services[%v]", i), svc.Validate())
+	}
+
+	return errs.errorOrNil()
 }
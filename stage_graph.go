@@ -0,0 +1,260 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StageGraph is the dependency graph of a list of stages. Stages without dependsOn depend on the stage before them, so stages run in order
+// like they always did; the parallel stages of a stage depend on whatever that stage depends on and stages depending on it wait for all of them
+type StageGraph struct {
+	// Nodes holds all stages that run, in topological order with ties broken by the order they're defined in
+	Nodes []*StageGraphNode
+}
+
+// StageGraphNode is a stage in a StageGraph; stages with parallel stages aren't nodes themselves, their parallel stages are
+type StageGraphNode struct {
+	// ID is the stage name, or <group>/<name> for parallel stages
+	ID    string
+	Stage *EstafetteStage
+	// Group is the name of the stage holding the parallel stage, empty for other stages
+	Group string
+	// DependsOn holds the ids of the nodes that need to finish before this one can start
+	DependsOn []string
+
+	path  string
+	index int
+	// group is the stage holding the parallel stage, nil for other stages
+	group *EstafetteStage
+}
+
+// NewStageGraph builds the dependency graph for stages; it returns validation errors for unknown dependencies and stages depending on
+// themselves, with paths relative to the stages
+func NewStageGraph(stages []*EstafetteStage) (*StageGraph, error) {
+
+	var errs ValidationErrors
+	graph := &StageGraph{}
+
+	// the ids of the nodes that have finished once a stage has finished
+	ends := map[string][]string{}
+	for _, s := range stages {
+		if len(s.ParallelStages) == 0 {
+			ends[s.Name] = []string{s.Name}
+			continue
+		}
+		for _, ps := range s.ParallelStages {
+			ends[s.Name] = append(ends[s.Name], s.Name+"/"+ps.Name)
+		}
+	}
+
+	var nodes []*StageGraphNode
+	for i, s := range stages {
+
+		dependsOn := s.DependsOn
+		if len(dependsOn) == 0 && i > 0 {
+			dependsOn = []string{stages[i-1].Name}
+		}
+
+		var ids []string
+		for _, d := range dependsOn {
+			if _, found := ends[d]; !found {
+				errs.addf(s.Name+".dependsOn", "Stage %v depends on unknown stage %v", s.Name, d)
+				continue
+			}
+			ids = appendUnique(ids, ends[d]...)
+		}
+
+		if len(s.ParallelStages) == 0 {
+			nodes = append(nodes, &StageGraphNode{ID: s.Name, Stage: s, DependsOn: ids, path: s.Name})
+			continue
+		}
+
+		siblings := map[string]bool{}
+		for _, ps := range s.ParallelStages {
+			siblings[ps.Name] = true
+		}
+		for _, ps := range s.ParallelStages {
+			path := fmt.Sprintf("%v.parallelStages.%v", s.Name, ps.Name)
+
+			// parallel stages only depend on each other if they say so
+			psIDs := append([]string{}, ids...)
+			for _, d := range ps.DependsOn {
+				if !siblings[d] {
+					errs.addf(path+".dependsOn", "Parallel stage %v depends on unknown parallel stage %v of stage %v", ps.Name, d, s.Name)
+					continue
+				}
+				psIDs = appendUnique(psIDs, s.Name+"/"+d)
+			}
+
+			nodes = append(nodes, &StageGraphNode{ID: s.Name + "/" + ps.Name, Stage: ps, Group: s.Name, DependsOn: psIDs, path: path, group: s})
+		}
+	}
+
+	// nodes with the same id would be merged into one, which breaks the ordering
+	byID := map[string]*StageGraphNode{}
+	for i, n := range nodes {
+		if _, exists := byID[n.ID]; exists {
+			errs.addf(n.path, "Stage %v is defined more than once", n.ID)
+			continue
+		}
+		n.index = i
+		byID[n.ID] = n
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	// repeatedly take the first node in definition order whose dependencies have all been taken
+	done := map[string]bool{}
+	for len(graph.Nodes) < len(nodes) {
+		var next *StageGraphNode
+		for _, n := range nodes {
+			if !done[n.ID] && allDone(n.DependsOn, done) {
+				next = n
+				break
+			}
+		}
+		if next == nil {
+			return nil, stageGraphCycleError(nodes, byID, done)
+		}
+		done[next.ID] = true
+		graph.Nodes = append(graph.Nodes, next)
+	}
+
+	return graph, nil
+}
+
+// Node returns the node with id, or nil if there's no such node
+func (g *StageGraph) Node(id string) *StageGraphNode {
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// Levels groups the nodes by the length of their longest chain of dependencies; all nodes in a level can run at the same time once the
+// nodes in the levels before it have finished
+func (g *StageGraph) Levels() (levels [][]*StageGraphNode) {
+
+	depths := map[string]int{}
+	for _, n := range g.Nodes {
+		depth := 0
+		for _, d := range n.DependsOn {
+			if depths[d]+1 > depth {
+				depth = depths[d] + 1
+			}
+		}
+		depths[n.ID] = depth
+
+		for len(levels) <= depth {
+			levels = append(levels, nil)
+		}
+		levels[depth] = append(levels[depth], n)
+	}
+
+	return levels
+}
+
+// StageGraph returns the dependency graph of the build stages
+func (c *EstafetteManifest) StageGraph() (*StageGraph, error) {
+	return stageGraphAt("stages", c.Stages)
+}
+
+// StageGraph returns the dependency graph of the release stages
+func (release *EstafetteRelease) StageGraph() (*StageGraph, error) {
+	return stageGraphAt("stages", release.Stages)
+}
+
+// StageGraph returns the dependency graph of the bot stages
+func (bot *EstafetteBot) StageGraph() (*StageGraph, error) {
+	return stageGraphAt("stages", bot.Stages)
+}
+
+// stageGraphAt builds the graph for stages with the paths of validation errors prefixed with path
+func stageGraphAt(path string, stages []*EstafetteStage) (*StageGraph, error) {
+	graph, err := NewStageGraph(stages)
+	if err != nil {
+		var errs ValidationErrors
+		errs.add(path, err)
+		return nil, errs
+	}
+	return graph, nil
+}
+
+// stageGraphCycleError follows the dependencies of the first node that couldn't be ordered until it runs into a node it has seen before
+func stageGraphCycleError(nodes []*StageGraphNode, byID map[string]*StageGraphNode, done map[string]bool) error {
+
+	var current *StageGraphNode
+	for _, n := range nodes {
+		if !done[n.ID] {
+			current = n
+			break
+		}
+	}
+
+	var chain []*StageGraphNode
+	seen := map[string]int{}
+	for {
+		if i, ok := seen[current.ID]; ok {
+			chain = chain[i:]
+			break
+		}
+		seen[current.ID] = len(chain)
+		chain = append(chain, current)
+
+		for _, d := range current.DependsOn {
+			if !done[d] {
+				current = byID[d]
+				break
+			}
+		}
+	}
+
+	// report the cycle at the stage defined first
+	first := 0
+	for i, n := range chain {
+		if n.index < chain[first].index {
+			first = i
+		}
+	}
+	chain = append(chain[first:], chain[:first]...)
+
+	ids := make([]string, 0, len(chain)+1)
+	for _, n := range chain {
+		ids = append(ids, n.ID)
+	}
+	ids = append(ids, chain[0].ID)
+
+	var errs ValidationErrors
+	errs.addf(chain[0].path+".dependsOn", "Stage %v depends on itself through %v", chain[0].ID, strings.Join(ids, " -> "))
+
+	return errs
+}
+
+func allDone(ids []string, done map[string]bool) bool {
+	for _, id := range ids {
+		if !done[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func appendUnique(values []string, add ...string) []string {
+	for _, a := range add {
+		found := false
+		for _, v := range values {
+			if v == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, a)
+		}
+	}
+	return values
+}
@@ -0,0 +1,255 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func stageGraphIDs(nodes []*StageGraphNode) (ids []string) {
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+	return
+}
+
+func TestStageGraph(t *testing.T) {
+	t.Run("ChainsStagesWithoutDependsOnInOrder", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+  test:
+    image: golang:1.17-alpine
+  bake:
+    image: extensions/docker:stable`, true)
+		assert.Nil(t, err)
+
+		// act
+		graph, err := manifest.StageGraph()
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"build", "test", "bake"}, stageGraphIDs(graph.Nodes))
+		assert.Equal(t, 0, len(graph.Nodes[0].DependsOn))
+		assert.Equal(t, []string{"build"}, graph.Nodes[1].DependsOn)
+		assert.Equal(t, []string{"test"}, graph.Nodes[2].DependsOn)
+		assert.Equal(t, 3, len(graph.Levels()))
+	})
+
+	t.Run("OrdersStagesByDependsOn", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+  lint:
+    image: golangci/golangci-lint:latest
+    dependsOn: [build]
+  test:
+    image: golang:1.17-alpine
+    dependsOn: [build]
+  bake:
+    image: extensions/docker:stable
+    dependsOn:
+    - lint
+    - test`, true)
+		assert.Nil(t, err)
+
+		// act
+		graph, err := manifest.StageGraph()
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"build", "lint", "test", "bake"}, stageGraphIDs(graph.Nodes))
+		levels := graph.Levels()
+		if assert.Equal(t, 3, len(levels)) {
+			assert.Equal(t, []string{"build"}, stageGraphIDs(levels[0]))
+			assert.Equal(t, []string{"lint", "test"}, stageGraphIDs(levels[1]))
+			assert.Equal(t, []string{"bake"}, stageGraphIDs(levels[2]))
+		}
+	})
+
+	t.Run("AllowsDependingOnStagesDefinedLater", func(t *testing.T) {
+
+		// act
+		graph, err := NewStageGraph([]*EstafetteStage{
+			{Name: "build"},
+			{Name: "notify", DependsOn: []string{"deploy"}},
+			{Name: "deploy", DependsOn: []string{"build"}},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"build", "deploy", "notify"}, stageGraphIDs(graph.Nodes))
+	})
+
+	t.Run("ConvertsParallelStagesIntoNodes", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+  checks:
+    parallelStages:
+      lint:
+        image: golangci/golangci-lint:latest
+      test:
+        image: golang:1.17-alpine
+      coverage:
+        image: golang:1.17-alpine
+        dependsOn: [test]
+  bake:
+    image: extensions/docker:stable`, true)
+		assert.Nil(t, err)
+
+		// act
+		graph, err := manifest.StageGraph()
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"build", "checks/lint", "checks/test", "checks/coverage", "bake"}, stageGraphIDs(graph.Nodes))
+		lint := graph.Node("checks/lint")
+		assert.Equal(t, "checks", lint.Group)
+		assert.Equal(t, "lint", lint.Stage.Name)
+		assert.Equal(t, []string{"build"}, lint.DependsOn)
+		assert.Equal(t, []string{"build", "checks/test"}, graph.Node("checks/coverage").DependsOn)
+		assert.Equal(t, []string{"checks/lint", "checks/test", "checks/coverage"}, graph.Node("bake").DependsOn)
+		assert.Nil(t, graph.Node("checks"))
+		assert.Equal(t, 4, len(graph.Levels()))
+	})
+
+	t.Run("ReturnsGraphForReleasesAndBots", func(t *testing.T) {
+
+		manifest, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+
+releases:
+  production:
+    stages:
+      deploy:
+        image: extensions/gke:stable
+      notify:
+        image: extensions/slack-build-status:stable
+        dependsOn: [deploy]
+
+bots:
+  pr-bot:
+    stages:
+      welcome:
+        image: extensions/github-pull-request-welcome:stable`, true)
+		assert.Nil(t, err)
+
+		// act
+		releaseGraph, releaseErr := manifest.Releases[0].StageGraph()
+		botGraph, botErr := manifest.Bots[0].StageGraph()
+
+		assert.Nil(t, releaseErr)
+		assert.Equal(t, []string{"deploy", "notify"}, stageGraphIDs(releaseGraph.Nodes))
+		assert.Nil(t, botErr)
+		assert.Equal(t, []string{"welcome"}, stageGraphIDs(botGraph.Nodes))
+	})
+}
+
+func TestValidateStageGraph(t *testing.T) {
+	t.Run("ReturnsErrorForUnknownDependency", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+  bake:
+    image: extensions/docker:stable
+    dependsOn: [biuld]`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "stages.bake.dependsOn", errs[0].Path)
+				assert.Equal(t, "Stage bake depends on unknown stage biuld", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForUnknownParallelStageDependency", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  checks:
+    parallelStages:
+      lint:
+        image: golangci/golangci-lint:latest
+        dependsOn: [tset]
+      test:
+        image: golang:1.17-alpine`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "stages.checks.parallelStages.lint.dependsOn", errs[0].Path)
+				assert.Equal(t, "Parallel stage lint depends on unknown parallel stage tset of stage checks", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForParallelStagesWithTheSameName", func(t *testing.T) {
+
+		stages := []*EstafetteStage{
+			{Name: "test", ParallelStages: []*EstafetteStage{{Name: "go-1.17"}, {Name: "go-1.17"}}},
+		}
+
+		// act
+		_, err := NewStageGraph(stages)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "test.parallelStages.go-1.17", errs[0].Path)
+				assert.Equal(t, "Stage test/go-1.17 is defined more than once", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForCycle", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+releases:
+  production:
+    stages:
+      deploy:
+        image: extensions/gke:stable
+        dependsOn: [notify]
+      notify:
+        image: extensions/slack-build-status:stable
+
+stages:
+  build:
+    image: golang:1.17-alpine`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "releases.production.stages.deploy.dependsOn", errs[0].Path)
+				assert.Equal(t, "Stage deploy depends on itself through deploy -> notify -> deploy", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForStageDependingOnItself", func(t *testing.T) {
+
+		// act
+		_, err := NewStageGraph([]*EstafetteStage{
+			{Name: "build", DependsOn: []string{"build"}},
+		})
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "build.dependsOn", errs[0].Path)
+				assert.Equal(t, "Stage build depends on itself through build -> build", errs[0].Message)
+			}
+		}
+	})
+}
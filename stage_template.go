@@ -0,0 +1,297 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/jinzhu/copier"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EstafetteStageTemplate is a stage that can be reused by multiple stages with template and with; its parameters are referenced as ${{ name }}
+type EstafetteStageTemplate struct {
+	Name       string                             `yaml:"-"`
+	Parameters []*EstafetteStageTemplateParameter `yaml:"-" json:",omitempty"`
+	Stage      EstafetteStage                     `yaml:",inline"`
+}
+
+// EstafetteStageTemplateParameter is a parameter of a stage template; without a default value it's required
+type EstafetteStageTemplateParameter struct {
+	Name        string  `yaml:"-"`
+	Description string  `yaml:"description,omitempty" json:",omitempty"`
+	Default     *string `yaml:"default,omitempty" json:",omitempty"`
+}
+
+var (
+	stageTemplateParameterRegex     = regexp.MustCompile(`\$\{\{\s*([a-zA-Z0-9_-]+)\s*\}\}`)
+	stageTemplateParameterNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// UnmarshalYAML customizes unmarshalling an EstafetteStageTemplate
+func (template *EstafetteStageTemplate) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
+
+	// the template is a stage with a parameters section, so split that off before unmarshalling the rest as a stage
+	var aux yaml.MapSlice
+	if err := unmarshal(&aux); err != nil {
+		return err
+	}
+
+	var stage yaml.MapSlice
+	for _, mi := range aux {
+		if mi.Key != "parameters" {
+			stage = append(stage, mi)
+			continue
+		}
+
+		parameters, ok := mi.Value.(yaml.MapSlice)
+		if !ok && mi.Value != nil {
+			return wrapUnmarshalError("parameters", fmt.Errorf("Parameters should be a mapping of parameter names to their definition"))
+		}
+		for _, p := range parameters {
+
+			bytes, err := yaml.Marshal(p.Value)
+			if err != nil {
+				return wrapUnmarshalError(fmt.Sprintf("parameters.%v", p.Key), err)
+			}
+
+			var parameter *EstafetteStageTemplateParameter
+			if err := yaml.Unmarshal(bytes, &parameter); err != nil {
+				return wrapUnmarshalError(fmt.Sprintf("parameters.%v", p.Key), err)
+			}
+			if parameter == nil {
+				parameter = &EstafetteStageTemplateParameter{}
+			}
+
+			parameter.Name = fmt.Sprintf("%v", p.Key)
+			template.Parameters = append(template.Parameters, parameter)
+		}
+	}
+
+	bytes, err := yaml.Marshal(stage)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(bytes, &template.Stage)
+}
+
+// MarshalYAML customizes marshalling an EstafetteStageTemplate
+func (template EstafetteStageTemplate) MarshalYAML() (out interface{}, err error) {
+
+	var aux yaml.MapSlice
+
+	if len(template.Parameters) > 0 {
+		var parameters yaml.MapSlice
+		for _, p := range template.Parameters {
+			parameters = append(parameters, yaml.MapItem{
+				Key:   p.Name,
+				Value: p,
+			})
+		}
+		aux = append(aux, yaml.MapItem{Key: "parameters", Value: parameters})
+	}
+
+	bytes, err := yaml.Marshal(template.Stage)
+	if err != nil {
+		return nil, err
+	}
+	var stage yaml.MapSlice
+	if err := yaml.Unmarshal(bytes, &stage); err != nil {
+		return nil, err
+	}
+
+	return append(aux, stage...), nil
+}
+
+// validate checks that the template only references parameters it declares
+func (template *EstafetteStageTemplate) validate() (err error) {
+
+	var errs ValidationErrors
+
+	declared := map[string]bool{}
+	for _, p := range template.Parameters {
+		if !stageTemplateParameterNameRegex.MatchString(p.Name) {
+			errs.addf("parameters", "Parameter name %v should only contain letters, digits, dashes and underscores", p.Name)
+		}
+		declared[p.Name] = true
+	}
+
+	if template.Stage.Template != "" {
+		errs.addf("template", "Stage template %v cannot use another stage template", template.Name)
+	}
+
+	referenced := map[string]bool{}
+	template.substitute(func(name string) (string, bool) {
+		referenced[name] = true
+		return "", false
+	})
+	names := make([]string, 0, len(referenced))
+	for name := range referenced {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !declared[name] {
+			errs.addf("parameters", "Stage template %v uses parameter %v without declaring it", template.Name, name)
+		}
+	}
+
+	return errs.errorOrNil()
+}
+
+// validateWith checks that the values passed to the template are all declared and that all required parameters are set
+func (template *EstafetteStageTemplate) validateWith(with map[string]string) (err error) {
+
+	var errs ValidationErrors
+
+	declared := map[string]bool{}
+	for _, p := range template.Parameters {
+		declared[p.Name] = true
+		if _, ok := with[p.Name]; !ok && p.Default == nil {
+			errs.addf("with", "Stage template %v requires parameter %v", template.Name, p.Name)
+		}
+	}
+
+	keys := make([]string, 0, len(with))
+	for key := range with {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !declared[key] {
+			errs.addf(fmt.Sprintf("with.%v", key), "Stage template %v has no parameter %v", template.Name, key)
+		}
+	}
+
+	return errs.errorOrNil()
+}
+
+// substitute returns a copy of the template's stage with the parameters in its image, commands, env and custom properties replaced;
+// parameters lookup doesn't know are left as is
+func (template *EstafetteStageTemplate) substitute(lookup func(name string) (string, bool)) (stage EstafetteStage) {
+
+	copier.CopyWithOption(&stage, template.Stage, copier.Option{IgnoreEmpty: true, DeepCopy: true})
+
+	replace := func(s string) string {
+		return stageTemplateParameterRegex.ReplaceAllStringFunc(s, func(match string) string {
+			if value, ok := lookup(stageTemplateParameterRegex.FindStringSubmatch(match)[1]); ok {
+				return value
+			}
+			return match
+		})
+	}
+
+	stage.ContainerImage = replace(stage.ContainerImage)
+	for i, c := range stage.Commands {
+		stage.Commands[i] = replace(c)
+	}
+	for key, value := range stage.EnvVars {
+		stage.EnvVars[key] = replace(value)
+	}
+	if stage.CustomProperties != nil {
+		stage.CustomProperties = substituteValue(template.Stage.CustomProperties, replace).(map[string]interface{})
+	}
+
+	return
+}
+
+// substituteValue replaces parameters in all strings nested in a custom property value
+func substituteValue(value interface{}, replace func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return replace(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = substituteValue(item, replace)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = substituteValue(item, replace)
+		}
+		return result
+	}
+	return value
+}
+
+// applyStageTemplate uses the template's stage with its parameters substituted as the base for the stage; properties set on the stage itself take
+// precedence, so applying the same template again doesn't change the stage anymore
+func (stage *EstafetteStage) applyStageTemplate(template *EstafetteStageTemplate) {
+
+	base := template.substitute(func(name string) (string, bool) {
+		if value, ok := stage.With[name]; ok {
+			return value, true
+		}
+		for _, p := range template.Parameters {
+			if p.Name == name && p.Default != nil {
+				return *p.Default, true
+			}
+		}
+		return "", false
+	})
+
+	stage.patch(&base)
+}
+
+// resolveStageTemplates applies the stage templates to all stages referencing them; templates that aren't defined (yet) are skipped, so
+// it can run again once included files add more templates
+func (c *EstafetteManifest) resolveStageTemplates() {
+
+	if len(c.StageTemplates) == 0 {
+		return
+	}
+
+	templates := map[string]*EstafetteStageTemplate{}
+	for _, t := range c.StageTemplates {
+		templates[t.Name] = t
+	}
+
+	apply := func(path string, stage *EstafetteStage) {
+		if template, ok := templates[stage.Template]; ok && stage.Template != "" {
+			stage.applyStageTemplate(template)
+		}
+	}
+
+	c.walkStages(apply)
+	for _, rt := range c.ReleaseTemplates {
+		walkStages(fmt.Sprintf("releaseTemplates.%v.stages", rt.Name), rt.Stages, apply)
+	}
+	for _, bt := range c.BotTemplates {
+		walkStages(fmt.Sprintf("botTemplates.%v.stages", bt.Name), bt.Stages, apply)
+	}
+}
+
+// validateStageTemplates checks the stage templates and the stages using them
+func (c *EstafetteManifest) validateStageTemplates() (err error) {
+
+	var errs ValidationErrors
+
+	templates := map[string]*EstafetteStageTemplate{}
+	for _, t := range c.StageTemplates {
+		templates[t.Name] = t
+		errs.add(fmt.Sprintf("stageTemplates.%v", t.Name), t.validate())
+	}
+
+	c.walkStages(func(path string, stage *EstafetteStage) {
+		if stage.Template == "" {
+			if len(stage.With) > 0 {
+				errs.addf(path+".with", "Stage %v sets with without using a stage template", stage.Name)
+			}
+			return
+		}
+
+		template, ok := templates[stage.Template]
+		if !ok {
+			errs.addf(path+".template", "Stage %v uses unknown stage template %v", stage.Name, stage.Template)
+			return
+		}
+		errs.add(path, template.validateWith(stage.With))
+	})
+
+	return errs.errorOrNil()
+}
@@ -0,0 +1,234 @@
+package manifest
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const stageTemplatesManifest = `
+stageTemplates:
+  push-to-docker:
+    parameters:
+      repository:
+        description: The repository to push the container image to
+      tag:
+        default: ${ESTAFETTE_BUILD_VERSION}
+    image: extensions/docker:stable
+    action: push
+    repositories:
+    - ${{ repository }}
+    tags:
+    - ${{tag}}
+    env:
+      REPOSITORY: ${{ repository }}
+    commands:
+    - echo pushing ${{ repository }}:${{ tag }}
+
+stages:
+  push-to-dockerhub:
+    template: push-to-docker
+    with:
+      repository: estafette
+  push-to-gcr:
+    template: push-to-docker
+    with:
+      repository: eu.gcr.io/estafette
+      tag: dev
+    env:
+      EXTRA: value
+
+releases:
+  tooling:
+    stages:
+      push:
+        template: push-to-docker
+        with:
+          repository: extensions
+          tag: stable`
+
+func TestStageTemplates(t *testing.T) {
+	t.Run("SubstitutesParametersWhenUnmarshalling", func(t *testing.T) {
+
+		var manifest EstafetteManifest
+
+		// act
+		err := yaml.Unmarshal([]byte(stageTemplatesManifest), &manifest)
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(manifest.StageTemplates)) {
+			assert.Equal(t, "push-to-docker", manifest.StageTemplates[0].Name)
+			assert.Equal(t, 2, len(manifest.StageTemplates[0].Parameters))
+		}
+
+		stage := manifest.Stages[0]
+		assert.Equal(t, "extensions/docker:stable", stage.ContainerImage)
+		assert.Equal(t, []string{"echo pushing estafette:${ESTAFETTE_BUILD_VERSION}"}, stage.Commands)
+		assert.Equal(t, map[string]string{"REPOSITORY": "estafette"}, stage.EnvVars)
+		assert.Equal(t, "push", stage.CustomProperties["action"])
+		assert.Equal(t, []interface{}{"estafette"}, stage.CustomProperties["repositories"])
+		assert.Equal(t, []interface{}{"${ESTAFETTE_BUILD_VERSION}"}, stage.CustomProperties["tags"])
+	})
+
+	t.Run("KeepsPropertiesSetOnTheStageItself", func(t *testing.T) {
+
+		var manifest EstafetteManifest
+
+		// act
+		err := yaml.Unmarshal([]byte(stageTemplatesManifest), &manifest)
+
+		assert.Nil(t, err)
+		stage := manifest.Stages[1]
+		assert.Equal(t, []string{"echo pushing eu.gcr.io/estafette:dev"}, stage.Commands)
+		assert.Equal(t, map[string]string{"REPOSITORY": "eu.gcr.io/estafette", "EXTRA": "value"}, stage.EnvVars)
+		assert.Equal(t, []interface{}{"dev"}, stage.CustomProperties["tags"])
+
+		// stages using the same template don't share anything
+		assert.Equal(t, []interface{}{"estafette"}, manifest.Stages[0].CustomProperties["repositories"])
+		assert.Equal(t, "${{ repository }}", manifest.StageTemplates[0].Stage.EnvVars["REPOSITORY"])
+	})
+
+	t.Run("AppliesTemplatesToReleaseStages", func(t *testing.T) {
+
+		// act
+		manifest, err := ReadManifest(nil, stageTemplatesManifest, true)
+
+		assert.Nil(t, err)
+		stage := manifest.Releases[0].Stages[0]
+		assert.Equal(t, "extensions/docker:stable", stage.ContainerImage)
+		assert.Equal(t, []interface{}{"extensions"}, stage.CustomProperties["repositories"])
+		assert.Equal(t, "status == 'succeeded'", stage.When)
+	})
+
+	t.Run("AppliesTemplatesFromIncludedFiles", func(t *testing.T) {
+
+		fsys := fstest.MapFS{
+			".estafette.yaml": &fstest.MapFile{Data: []byte(`
+include:
+- templates.yaml
+stages:
+  build:
+    template: go-build
+    with:
+      version: "1.17"`)},
+			"templates.yaml": &fstest.MapFile{Data: []byte(`
+stageTemplates:
+  go-build:
+    parameters:
+      version:
+    image: golang:${{ version }}-alpine`)},
+		}
+
+		// act
+		manifest, err := ReadManifestWithOptions(nil, ReadOptions{FS: fsys, File: ".estafette.yaml", SkipDefaults: true, SkipValidation: true})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "golang:1.17-alpine", manifest.Stages[0].ContainerImage)
+	})
+
+	t.Run("MarshalsTemplatesWithTheirParameters", func(t *testing.T) {
+
+		var manifest EstafetteManifest
+		err := yaml.Unmarshal([]byte(stageTemplatesManifest), &manifest)
+		assert.Nil(t, err)
+
+		// act
+		output, err := yaml.Marshal(manifest)
+
+		assert.Nil(t, err)
+		var roundTripped EstafetteManifest
+		err = yaml.Unmarshal(output, &roundTripped)
+		assert.Nil(t, err)
+		assert.Equal(t, manifest.StageTemplates, roundTripped.StageTemplates)
+		assert.Equal(t, manifest.Stages, roundTripped.Stages)
+	})
+}
+
+func TestValidateStageTemplates(t *testing.T) {
+	t.Run("ReturnsErrorsForMissingAndUnknownParameters", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stageTemplates:
+  push-to-docker:
+    parameters:
+      repository:
+    image: extensions/docker:stable
+    repositories:
+    - ${{ repository }}
+
+stages:
+  push:
+    template: push-to-docker
+    with:
+      repo: estafette`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 2, len(errs)) {
+				assert.Equal(t, "stages.push.with", errs[0].Path)
+				assert.Equal(t, "Stage template push-to-docker requires parameter repository", errs[0].Message)
+				assert.Equal(t, "stages.push.with.repo", errs[1].Path)
+				assert.Equal(t, "Stage template push-to-docker has no parameter repo", errs[1].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForUnknownTemplate", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  push:
+    image: extensions/docker:stable
+    template: push-to-docker`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "stages.push.template", errs[0].Path)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForUndeclaredParameterInTemplate", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stageTemplates:
+  go-build:
+    image: golang:${{ version }}-alpine
+
+stages:
+  build:
+    template: go-build`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "stageTemplates.go-build.parameters", errs[0].Path)
+				assert.Equal(t, "Stage template go-build uses parameter version without declaring it", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorForWithWithoutTemplate", func(t *testing.T) {
+
+		// act
+		_, err := ReadManifest(nil, `
+stages:
+  build:
+    image: golang:1.17-alpine
+    with:
+      version: "1.17"`, true)
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "stages.build.with", errs[0].Path)
+			}
+		}
+	})
+}
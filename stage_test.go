@@ -448,4 +448,57 @@ func TestValidateOnStage(t *testing.T) {
 
 		assert.Nil(t, err)
 	})
+
+	t.Run("ReturnsErrorIfWhenIsInvalid", func(t *testing.T) {
+
+		stage := EstafetteStage{
+			Name:           "build",
+			ContainerImage: "golang:1.17-alpine",
+			When:           "status == 'succeeded' && brnach == 'main'",
+		}
+		stage.SetDefaults(EstafetteBuilder{
+			OperatingSystem: "linux",
+			Track:           "stable",
+		})
+
+		// act
+		err := stage.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "when", errs[0].Path)
+				assert.Equal(t, "Stage build has an invalid when expression: Unknown identifier brnach, use status, branch, action, server, trigger or labels.<name> at offset 25", errs[0].Message)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorIfWhenOfServiceIsInvalid", func(t *testing.T) {
+
+		stage := EstafetteStage{
+			Name:           "test",
+			ContainerImage: "golang:1.17-alpine",
+			Services: []*EstafetteService{
+				&EstafetteService{
+					Name:           "cockroachdb",
+					ContainerImage: "cockroachdb/cockroach:v19.2.0",
+					When:           "status = 'succeeded'",
+				},
+			},
+		}
+		stage.SetDefaults(EstafetteBuilder{
+			OperatingSystem: "linux",
+			Track:           "stable",
+		})
+
+		// act
+		err := stage.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 1, len(errs)) {
+				assert.Equal(t, "services[0].when", errs[0].Path)
+			}
+		}
+	})
 }
@@ -0,0 +1,34 @@
+package manifest
+
+import "fmt"
+
+// StagesMergeMode sets how the stages of a release are combined with the stages of its release template
+type StagesMergeMode string
+
+const (
+	StagesMergeModeReplace StagesMergeMode = "replace" // the stages of the release replace all stages of the template; the default
+	StagesMergeModeAppend  StagesMergeMode = "append"  // stages of the release replace template stages with the same name, others are added after the template stages
+	StagesMergeModePatch   StagesMergeMode = "patch"   // stages of the release are merged with template stages with the same name, others are added after the template stages
+)
+
+func validateStagesMerge(mode StagesMergeMode) error {
+	switch mode {
+	case "", StagesMergeModeReplace, StagesMergeModeAppend, StagesMergeModePatch:
+		return nil
+	}
+	return fmt.Errorf("Unknown stagesMerge %v, use %v, %v or %v", mode, StagesMergeModeReplace, StagesMergeModeAppend, StagesMergeModePatch)
+}
+
+// merge combines the stages of a template with the ones of the release, bot or template using it
+func (mode StagesMergeMode) merge(templateStages, stages []*EstafetteStage) []*EstafetteStage {
+	switch mode {
+	case StagesMergeModeAppend:
+		return mergeStages(templateStages, stages, false)
+	case StagesMergeModePatch:
+		return mergeStages(templateStages, stages, true)
+	}
+	if len(stages) == 0 {
+		return templateStages
+	}
+	return stages
+}
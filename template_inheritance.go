@@ -0,0 +1,122 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// inheritableTemplate is a release or bot template, which can inherit from a parent template of the same kind
+type inheritableTemplate interface {
+	templateName() string
+	parentTemplateName() string
+	// deepCopy returns a copy of the template that doesn't share pointers with it
+	deepCopy() inheritableTemplate
+	// inheritFromTemplate uses the values of parent, which is of the same kind, for everything the template doesn't set itself
+	inheritFromTemplate(parent inheritableTemplate)
+}
+
+// templateLookup returns the template of the same kind with name, if it exists
+type templateLookup func(name string) (inheritableTemplate, bool)
+
+// inheritedTemplateFields points at the fields release and bot templates both have and inherit in the same way
+type inheritedTemplateFields struct {
+	builder         **EstafetteBuilder
+	cloneRepository **bool
+	triggers        *[]*EstafetteTrigger
+	envVars         *map[string]string
+	stages          *[]*EstafetteStage
+	stagesMerge     StagesMergeMode
+}
+
+// resolveTemplate returns a copy of template with everything it inherits from its parent templates filled in, together with the names of
+// all templates involved, starting with this one; kind is release or bot and is used in errors
+func resolveTemplate(kind string, template inheritableTemplate, lookup templateLookup) (resolved inheritableTemplate, resolvedTemplates []string, err error) {
+
+	chain, circular := templateChain(template, lookup)
+	if circular {
+		return nil, nil, fmt.Errorf("%v template %v inherits from itself", strings.Title(kind), template.templateName())
+	}
+	if root := chain[len(chain)-1]; root.parentTemplateName() != "" {
+		return nil, nil, fmt.Errorf("%v template %v uses unknown %v template %v", strings.Title(kind), root.templateName(), kind, root.parentTemplateName())
+	}
+
+	for _, t := range chain {
+		resolvedTemplates = append(resolvedTemplates, t.templateName())
+	}
+
+	// start at the root of the chain and let each template override its parent; deep copies so there's no pointers shared with others
+	resolved = chain[len(chain)-1].deepCopy()
+	for i := len(chain) - 2; i >= 0; i-- {
+		child := chain[i].deepCopy()
+		child.inheritFromTemplate(resolved)
+		resolved = child
+	}
+
+	return resolved, resolvedTemplates, nil
+}
+
+// templateChain returns the template followed by its parent templates, up to the first one without a parent or with an unknown parent;
+// circular is true if a template in the chain inherits from itself
+func templateChain(template inheritableTemplate, lookup templateLookup) (chain []inheritableTemplate, circular bool) {
+
+	chain = []inheritableTemplate{template}
+	visited := map[string]bool{template.templateName(): true}
+
+	for current := template; current.parentTemplateName() != ""; {
+		if visited[current.parentTemplateName()] {
+			return chain, true
+		}
+
+		parent, found := lookup(current.parentTemplateName())
+		if !found {
+			return chain, false
+		}
+
+		chain = append(chain, parent)
+		visited[parent.templateName()] = true
+		current = parent
+	}
+
+	return chain, false
+}
+
+// validateTemplateParent checks that the parent template exists and that the template doesn't inherit from itself
+func validateTemplateParent(kind string, template inheritableTemplate, lookup templateLookup) (err error) {
+
+	var errs ValidationErrors
+
+	if template.parentTemplateName() != "" {
+		if _, found := lookup(template.parentTemplateName()); !found {
+			errs.addf("template", "%v template %v uses unknown %v template %v", strings.Title(kind), template.templateName(), kind, template.parentTemplateName())
+		} else if chain, circular := templateChain(template, lookup); circular {
+			names := []string{}
+			for _, t := range chain {
+				names = append(names, t.templateName())
+			}
+			errs.addf("template", "%v template %v inherits from itself through %v -> %v", strings.Title(kind), template.templateName(), strings.Join(names, " -> "), chain[len(chain)-1].parentTemplateName())
+		}
+	}
+
+	return errs.errorOrNil()
+}
+
+// inheritFrom uses the values of parent for the fields the template doesn't set itself; env vars are merged key by key and stages are
+// combined according to StagesMerge
+func (fields inheritedTemplateFields) inheritFrom(parent inheritedTemplateFields) {
+
+	if *fields.builder == nil {
+		*fields.builder = *parent.builder
+	}
+
+	if *fields.cloneRepository == nil {
+		*fields.cloneRepository = *parent.cloneRepository
+	}
+
+	if len(*fields.triggers) == 0 {
+		*fields.triggers = *parent.triggers
+	}
+
+	*fields.envVars = mergeEnvVars(*parent.envVars, *fields.envVars)
+
+	*fields.stages = fields.stagesMerge.merge(*parent.stages, *fields.stages)
+}
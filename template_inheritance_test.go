@@ -0,0 +1,57 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveTemplate(t *testing.T) {
+	t.Run("ReturnsTemplateWithValuesOfItsParentsAndTheirNames", func(t *testing.T) {
+
+		botTemplates := map[string]*EstafetteBotTemplate{
+			"base":    {Name: "base", Builder: &EstafetteBuilder{Track: "stable"}, EnvVars: map[string]string{"A": "base", "B": "base"}},
+			"go":      {Name: "go", Template: "base", EnvVars: map[string]string{"B": "go"}},
+			"pr-bots": {Name: "pr-bots", Template: "go"},
+		}
+
+		// act
+		resolved, resolvedTemplates, err := resolveTemplate("bot", botTemplates["pr-bots"], botTemplateLookup(botTemplates))
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"pr-bots", "go", "base"}, resolvedTemplates)
+		bot := resolved.(*EstafetteBotTemplate)
+		assert.Equal(t, "pr-bots", bot.Name)
+		assert.Equal(t, "stable", bot.Builder.Track)
+		assert.Equal(t, map[string]string{"A": "base", "B": "go"}, bot.EnvVars)
+	})
+
+	t.Run("ReturnsErrorIfTemplateInheritsFromItself", func(t *testing.T) {
+
+		releaseTemplates := map[string]*EstafetteReleaseTemplate{
+			"a": {Name: "a", Template: "b"},
+			"b": {Name: "b", Template: "a"},
+		}
+
+		// act
+		_, _, err := resolveTemplate("release", releaseTemplates["a"], releaseTemplateLookup(releaseTemplates))
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Release template a inherits from itself", err.Error())
+		}
+	})
+
+	t.Run("ReturnsErrorIfRootOfChainUsesUnknownTemplate", func(t *testing.T) {
+
+		botTemplates := map[string]*EstafetteBotTemplate{
+			"go": {Name: "go", Template: "bsae"},
+		}
+
+		// act
+		_, _, err := resolveTemplate("bot", botTemplates["go"], botTemplateLookup(botTemplates))
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Bot template go uses unknown bot template bsae", err.Error())
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -20,6 +22,7 @@ type EstafetteTrigger struct {
 	PubSub    *EstafettePubSubTrigger    `yaml:"pubsub,omitempty" json:"pubsub,omitempty"`
 	Github    *EstafetteGithubTrigger    `yaml:"github,omitempty" json:"github,omitempty"`
 	Bitbucket *EstafetteBitbucketTrigger `yaml:"bitbucket,omitempty" json:"bitbucket,omitempty"`
+	Manual    *EstafetteManualTrigger    `yaml:"manual,omitempty" json:"manual,omitempty"`
 
 	BuildAction   *EstafetteTriggerBuildAction   `yaml:"builds,omitempty" json:"builds,omitempty"`
 	ReleaseAction *EstafetteTriggerReleaseAction `yaml:"releases,omitempty" json:"releases,omitempty"`
@@ -56,27 +59,48 @@ type EstafetteDockerTrigger struct {
 	Tag   string `yaml:"tag,omitempty" json:"tag,omitempty"`
 }
 
-// EstafettePubSubTrigger fires for pubsub events in a certain project and topic
+// EstafettePubSubTrigger fires for pubsub events in a certain project and topic; attributes and data filter on the message
 type EstafettePubSubTrigger struct {
-	Project string `yaml:"project,omitempty" json:"project,omitempty"`
-	Topic   string `yaml:"topic,omitempty" json:"topic,omitempty"`
+	Project    string                       `yaml:"project,omitempty" json:"project,omitempty"`
+	Topic      string                       `yaml:"topic,omitempty" json:"topic,omitempty"`
+	Attributes map[string]string            `yaml:"attributes,omitempty" json:"attributes,omitempty"`
+	Data       []*EstafettePubSubDataFilter `yaml:"data,omitempty" json:"data,omitempty"`
 }
 
-// EstafetteGithubTrigger fires for github events
+// EstafettePubSubDataFilter matches the values selected by a JSONPath from the json data of a pubsub message against a regex
+type EstafettePubSubDataFilter struct {
+	Path  string `yaml:"path,omitempty" json:"path,omitempty"`
+	Value string `yaml:"value,omitempty" json:"value,omitempty"`
+}
+
+// EstafetteGithubTrigger fires for github events; actions, branch, labels and sender filter on the payload of the event
 type EstafetteGithubTrigger struct {
 	Events     []string `yaml:"events,omitempty" json:"events,omitempty"`
 	Repository string   `yaml:"repository,omitempty" json:"repository,omitempty"`
+	Actions    []string `yaml:"actions,omitempty" json:"actions,omitempty"`
+	Branch     string   `yaml:"branch,omitempty" json:"branch,omitempty"`
+	Labels     []string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Sender     string   `yaml:"sender,omitempty" json:"sender,omitempty"`
 }
 
-// EstafetteBitbucketTrigger fires for bitbucket events
+// EstafetteBitbucketTrigger fires for bitbucket events; branch and sender filter on the payload of the event
 type EstafetteBitbucketTrigger struct {
 	Events     []string `yaml:"events,omitempty" json:"events,omitempty"`
 	Repository string   `yaml:"repository,omitempty" json:"repository,omitempty"`
+	Branch     string   `yaml:"branch,omitempty" json:"branch,omitempty"`
+	Sender     string   `yaml:"sender,omitempty" json:"sender,omitempty"`
+}
+
+// EstafetteManualTrigger fires when a build, release or bot run is started from the gui, cli or slack
+type EstafetteManualTrigger struct {
+	Users []string `yaml:"users,omitempty" json:"users,omitempty"`
 }
 
-// EstafetteCronTrigger fires at intervals specified by the cron schedule
+// EstafetteCronTrigger fires at intervals specified by the cron schedule, in the timezone set either by timezone or by a CRON_TZ= prefix of
+// the schedule and in UTC otherwise
 type EstafetteCronTrigger struct {
 	Schedule string `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
 }
 
 // EstafetteTriggerBuildAction determines what builds when the trigger fires
@@ -177,6 +201,9 @@ func (g *EstafetteGitTrigger) SetDefaults() {
 
 // SetDefaults sets defaults for EstafetteDockerTrigger
 func (d *EstafetteDockerTrigger) SetDefaults() {
+	if d.Event == "" {
+		d.Event = "push"
+	}
 }
 
 // SetDefaults sets defaults for EstafetteCronTrigger
@@ -233,6 +260,8 @@ func (b *EstafetteTriggerBotAction) SetDefaults(preferences EstafetteManifestPre
 // Validate checks if EstafetteTrigger is valid
 func (t *EstafetteTrigger) Validate(triggerType TriggerType, targetName string) (err error) {
 
+	var errs ValidationErrors
+
 	numberOfTypes := 0
 
 	if t.Pipeline == nil &&
@@ -242,163 +271,166 @@ func (t *EstafetteTrigger) Validate(triggerType TriggerType, targetName string)
 		t.Cron == nil &&
 		t.PubSub == nil &&
 		t.Github == nil &&
-		t.Bitbucket == nil {
-		return fmt.Errorf("Set at least a 'pipeline', 'release', 'git', 'docker', 'cron', 'pubsub', 'github' or 'bitbucket' trigger")
+		t.Bitbucket == nil &&
+		t.Manual == nil {
+		errs.addf("", "Set at least a 'pipeline', 'release', 'git', 'docker', 'cron', 'pubsub', 'github', 'bitbucket' or 'manual' trigger")
 	}
 
 	if t.Pipeline != nil {
-		err = t.Pipeline.Validate()
-		if err != nil {
-			return err
-		}
+		errs.add("pipeline", t.Pipeline.Validate())
 		numberOfTypes++
 	}
 	if t.Release != nil {
-		err = t.Release.Validate()
-		if err != nil {
-			return err
-		}
+		errs.add("release", t.Release.Validate())
 		numberOfTypes++
 	}
 	if t.Git != nil {
-		err = t.Git.Validate()
-		if err != nil {
-			return err
-		}
+		errs.add("git", t.Git.Validate())
 		numberOfTypes++
 	}
 	if t.Docker != nil {
-		err = t.Docker.Validate()
-		if err != nil {
-			return err
-		}
+		errs.add("docker", t.Docker.Validate())
 		numberOfTypes++
 	}
 	if t.Cron != nil {
-		err = t.Cron.Validate()
-		if err != nil {
-			return err
-		}
+		errs.add("cron", t.Cron.Validate())
 		numberOfTypes++
 	}
 	if t.PubSub != nil {
-		err = t.PubSub.Validate()
-		if err != nil {
-			return err
-		}
+		errs.add("pubsub", t.PubSub.Validate())
 		numberOfTypes++
 	}
 	if t.Github != nil {
-		err = t.Github.Validate()
-		if err != nil {
-			return err
-		}
+		errs.add("github", t.Github.Validate())
 		numberOfTypes++
 	}
 	if t.Bitbucket != nil {
-		err = t.Bitbucket.Validate()
-		if err != nil {
-			return err
-		}
+		errs.add("bitbucket", t.Bitbucket.Validate())
+		numberOfTypes++
+	}
+	if t.Manual != nil {
 		numberOfTypes++
 	}
 
-	if numberOfTypes != 1 {
-		return fmt.Errorf("Do not specify more than one type of trigger 'pipeline', 'release', 'git', 'docker', 'cron', 'pubsub', 'github' or 'bitbucket' per trigger object")
+	if numberOfTypes > 1 {
+		errs.addf("", "Do not specify more than one type of trigger 'pipeline', 'release', 'git', 'docker', 'cron', 'pubsub', 'github', 'bitbucket' or 'manual' per trigger object")
 	}
 
 	switch triggerType {
 	case TriggerTypeBuild:
 		if t.BuildAction == nil {
-			return fmt.Errorf("For a build trigger set the 'builds' property")
+			errs.addf("builds", "For a build trigger set the 'builds' property")
+		} else {
+			errs.add("builds", t.BuildAction.Validate())
 		}
 		if t.ReleaseAction != nil {
-			return fmt.Errorf("For a build trigger do not set the 'releases' property")
-		}
-		err = t.BuildAction.Validate()
-		if err != nil {
-			return err
+			errs.addf("releases", "For a build trigger do not set the 'releases' property")
 		}
 	case TriggerTypeRelease:
 		if t.ReleaseAction == nil {
-			return fmt.Errorf("For a release trigger set the 'releases' property")
+			errs.addf("releases", "For a release trigger set the 'releases' property")
+		} else {
+			errs.add("releases", t.ReleaseAction.Validate(targetName))
 		}
 		if t.BuildAction != nil {
-			return fmt.Errorf("For a release trigger do not set the 'builds' property")
-		}
-		err = t.ReleaseAction.Validate(targetName)
-		if err != nil {
-			return err
+			errs.addf("builds", "For a release trigger do not set the 'builds' property")
 		}
 	case TriggerTypeBot:
 		if t.BotAction == nil {
-			return fmt.Errorf("For a bot trigger set the 'runs' property")
-		}
-		err = t.BotAction.Validate()
-		if err != nil {
-			return err
+			errs.addf("runs", "For a bot trigger set the 'runs' property")
+		} else {
+			errs.add("runs", t.BotAction.Validate())
 		}
 	}
 
-	return nil
+	return errs.errorOrNil()
 }
 
 // Validate checks if EstafettePipelineTrigger is valid
 func (p *EstafettePipelineTrigger) Validate() (err error) {
+	var errs ValidationErrors
 	if p.Event != "started" && p.Event != "finished" {
-		return fmt.Errorf("Set pipeline.event in your trigger to 'started' or 'finished'")
+		errs.addf("event", "Set pipeline.event in your trigger to 'started' or 'finished'")
 	}
 	if p.Event == "finished" && p.Status != "succeeded" && p.Status != "failed" {
-		return fmt.Errorf("Set pipeline.status in your trigger to 'succeeded' or 'failed' for event 'finished'")
+		errs.addf("status", "Set pipeline.status in your trigger to 'succeeded' or 'failed' for event 'finished'")
 	}
 	if p.Name == "" {
-		return fmt.Errorf("Set pipeline.name in your trigger to 'self' or a full qualified pipeline name, i.e. github.com/estafette/estafette-ci-manifest")
+		errs.addf("name", "Set pipeline.name in your trigger to 'self' or a full qualified pipeline name, i.e. github.com/estafette/estafette-ci-manifest")
 	}
-	return nil
+	return errs.errorOrNil()
 }
 
 // Validate checks if EstafetteReleaseTrigger is valid
 func (r *EstafetteReleaseTrigger) Validate() (err error) {
+	var errs ValidationErrors
 	if r.Event != "started" && r.Event != "finished" {
-		return fmt.Errorf("Set release.event in your trigger to 'started' or 'finished'")
+		errs.addf("event", "Set release.event in your trigger to 'started' or 'finished'")
 	}
 	if r.Event == "finished" && r.Status != "succeeded" && r.Status != "failed" {
-		return fmt.Errorf("Set release.status in your trigger to 'succeeded' or 'failed' for event 'finished'")
+		errs.addf("status", "Set release.status in your trigger to 'succeeded' or 'failed' for event 'finished'")
 	}
 	if r.Name == "" {
-		return fmt.Errorf("Set release.name in your trigger to 'self' or a full qualified pipeline name, i.e. github.com/estafette/estafette-ci-manifest")
+		errs.addf("name", "Set release.name in your trigger to 'self' or a full qualified pipeline name, i.e. github.com/estafette/estafette-ci-manifest")
 	}
 	if r.Target == "" {
-		return fmt.Errorf("Set release.target in your trigger to a release target name on the pipeline set by release.name")
+		errs.addf("target", "Set release.target in your trigger to a release target name on the pipeline set by release.name")
 	}
-	return nil
+	return errs.errorOrNil()
 }
 
 // Validate checks if EstafetteGitTrigger is valid
 func (g *EstafetteGitTrigger) Validate() (err error) {
+	var errs ValidationErrors
 	if g.Event != "push" {
-		return fmt.Errorf("Set git.event in your trigger to 'push'")
+		errs.addf("event", "Set git.event in your trigger to 'push'")
 	}
 	if g.Repository == "" {
-		return fmt.Errorf("Set git.repository in your trigger to a full qualified git repository name, i.e. github.com/estafette/estafette-ci-manifest")
+		errs.addf("repository", "Set git.repository in your trigger to a full qualified git repository name, i.e. github.com/estafette/estafette-ci-manifest")
 	}
-	return nil
+	return errs.errorOrNil()
 }
 
 // Validate checks if EstafetteDockerTrigger is valid
 func (d *EstafetteDockerTrigger) Validate() (err error) {
-	return nil
+	var errs ValidationErrors
+	if d.Event != "push" {
+		errs.addf("event", "Set docker.event in your trigger to 'push'")
+	}
+	if d.Image == "" {
+		errs.addf("image", "Set docker.image in your trigger to a full qualified image name or a regular expression matching image names, i.e. estafette/estafette-ci-api")
+	} else if err := validateRegex(d.Image); err != nil {
+		errs.addf("image", "Invalid docker.image in your trigger: %v", err)
+	}
+	if d.Tag != "" {
+		if err := validateRegex(d.Tag); err != nil {
+			errs.addf("tag", "Invalid docker.tag in your trigger: %v", err)
+		}
+	}
+	return errs.errorOrNil()
 }
 
 // Validate checks if EstafetteCronTrigger is valid
 func (c *EstafetteCronTrigger) Validate() (err error) {
 
 	if c.Schedule == "" {
-		return fmt.Errorf("Set cron.schedule in your trigger to '<minute> <hour> <day of month> <month> <day of week>'")
+		return &ValidationError{Path: "schedule", Message: "Set cron.schedule in your trigger to '<minute> <hour> <day of month> <month> <day of week>'"}
+	}
+
+	schedule, timezone := c.splitSchedule()
+	if timezone != "" && c.Timezone != "" {
+		return &ValidationError{Path: "timezone", Message: "Set either cron.timezone or a CRON_TZ= prefix in cron.schedule in your trigger, not both"}
 	}
-	_, err = cron.ParseStandard(c.Schedule)
+	if _, err := c.location(); err != nil {
+		path := "timezone"
+		if timezone != "" {
+			path = "schedule"
+		}
+		return &ValidationError{Path: path, Message: fmt.Sprintf("Invalid timezone in your cron trigger, use a name from the tz database like Europe/Amsterdam: %v", err)}
+	}
+	_, err = cron.ParseStandard(schedule)
 	if err != nil {
-		return fmt.Errorf("Invalid cron.schedule in your trigger: %v", err)
+		return &ValidationError{Path: "schedule", Message: fmt.Sprintf("Invalid cron.schedule in your trigger: %v", err)}
 	}
 
 	return nil
@@ -406,32 +438,71 @@ func (c *EstafetteCronTrigger) Validate() (err error) {
 
 // Validate checks if EstafettePubSubTrigger is valid
 func (p *EstafettePubSubTrigger) Validate() (err error) {
+	var errs ValidationErrors
 	if p.Project == "" {
-		return fmt.Errorf("Set pubsub.project in your trigger to the google cloud project id containing the pubsub topic")
+		errs.addf("project", "Set pubsub.project in your trigger to the google cloud project id containing the pubsub topic")
 	}
 	if p.Topic == "" {
-		return fmt.Errorf("Set pubsub.topic in your trigger to the pubsub topic you want this pipeline to subscribe to")
+		errs.addf("topic", "Set pubsub.topic in your trigger to the pubsub topic you want this pipeline to subscribe to")
+	}
+	for _, name := range sortedKeys(p.Attributes) {
+		if err := validateRegex(p.Attributes[name]); err != nil {
+			errs.addf(fmt.Sprintf("attributes.%v", name), "Invalid pubsub.attributes.%v in your trigger: %v", name, err)
+		}
+	}
+	for i, f := range p.Data {
+		if f == nil {
+			continue
+		}
+		if f.Path == "" {
+			errs.addf(fmt.Sprintf("data[%v].path", i), "Set pubsub.data.path in your trigger to a JSONPath like $.action")
+		} else if _, err := parseJSONPath(f.Path); err != nil {
+			errs.addf(fmt.Sprintf("data[%v].path", i), "Invalid pubsub.data.path in your trigger: %v", err)
+		}
+		if err := validateRegex(f.Value); err != nil {
+			errs.addf(fmt.Sprintf("data[%v].value", i), "Invalid pubsub.data.value in your trigger: %v", err)
+		}
 	}
 
-	return nil
+	return errs.errorOrNil()
 }
 
 // Validate checks if EstafetteGithubTrigger is valid
 func (p *EstafetteGithubTrigger) Validate() (err error) {
+	var errs ValidationErrors
 	if len(p.Events) == 0 {
-		return fmt.Errorf("Set array github.events in your trigger to at least one github event")
+		errs.addf("events", "Set array github.events in your trigger to at least one github event")
 	}
-
-	return nil
+	if p.Branch != "" {
+		if err := validateRegex(p.Branch); err != nil {
+			errs.addf("branch", "Invalid github.branch in your trigger: %v", err)
+		}
+	}
+	if p.Sender != "" {
+		if err := validateRegex(p.Sender); err != nil {
+			errs.addf("sender", "Invalid github.sender in your trigger: %v", err)
+		}
+	}
+	return errs.errorOrNil()
 }
 
 // Validate checks if EstafetteBitbucketTrigger is valid
 func (p *EstafetteBitbucketTrigger) Validate() (err error) {
+	var errs ValidationErrors
 	if len(p.Events) == 0 {
-		return fmt.Errorf("Set array bitbucket.events in your trigger to at least one bitbucket event")
+		errs.addf("events", "Set array bitbucket.events in your trigger to at least one bitbucket event")
 	}
-
-	return nil
+	if p.Branch != "" {
+		if err := validateRegex(p.Branch); err != nil {
+			errs.addf("branch", "Invalid bitbucket.branch in your trigger: %v", err)
+		}
+	}
+	if p.Sender != "" {
+		if err := validateRegex(p.Sender); err != nil {
+			errs.addf("sender", "Invalid bitbucket.sender in your trigger: %v", err)
+		}
+	}
+	return errs.errorOrNil()
 }
 
 // Validate checks if EstafetteTriggerBuildAction is valid
@@ -442,7 +513,7 @@ func (b *EstafetteTriggerBuildAction) Validate() (err error) {
 // Validate checks if EstafetteTriggerReleaseAction is valid
 func (r *EstafetteTriggerReleaseAction) Validate(targetName string) (err error) {
 	if r.Target != targetName {
-		return fmt.Errorf("The target in your releases action should have defaulted to '%v'", targetName)
+		return &ValidationError{Path: "target", Message: fmt.Sprintf("The target in your releases action should have defaulted to '%v'", targetName)}
 	}
 
 	return nil
@@ -469,6 +540,124 @@ func (t *EstafetteTrigger) ReplaceSelf(pipeline string) {
 	}
 }
 
+// withSelfReplaced returns a copy of EstafetteTrigger with pipeline names set to "self" replaced with the actual pipeline name, leaving the
+// trigger itself as is
+func (t *EstafetteTrigger) withSelfReplaced(pipeline string) *EstafetteTrigger {
+	trigger := *t
+	if t.Pipeline != nil {
+		p := *t.Pipeline
+		trigger.Pipeline = &p
+	}
+	if t.Release != nil {
+		r := *t.Release
+		trigger.Release = &r
+	}
+	if t.Github != nil {
+		g := *t.Github
+		trigger.Github = &g
+	}
+	if t.Bitbucket != nil {
+		b := *t.Bitbucket
+		trigger.Bitbucket = &b
+	}
+	trigger.ReplaceSelf(pipeline)
+
+	return &trigger
+}
+
+// EstafetteTriggerMatch is an action to take because trigger fired for an event; only the action matching the type of trigger is set
+type EstafetteTriggerMatch struct {
+	Trigger       *EstafetteTrigger
+	TriggerType   TriggerType
+	BuildAction   *EstafetteTriggerBuildAction
+	ReleaseAction *EstafetteTriggerReleaseAction
+	BotAction     *EstafetteTriggerBotAction
+}
+
+// Fires indicates whether EstafetteTrigger fires for an EstafetteEvent, by passing the event to the trigger of the same type
+func (t *EstafetteTrigger) Fires(e *EstafetteEvent) bool {
+	if e == nil {
+		return false
+	}
+
+	switch {
+	case t.Pipeline != nil && e.Pipeline != nil:
+		return t.Pipeline.Fires(e.Pipeline)
+	case t.Release != nil && e.Release != nil:
+		return t.Release.Fires(e.Release)
+	case t.Git != nil && e.Git != nil:
+		return t.Git.Fires(e.Git)
+	case t.Docker != nil && e.Docker != nil:
+		return t.Docker.Fires(e.Docker)
+	case t.Cron != nil && e.Cron != nil:
+		return t.Cron.Fires(e.Cron)
+	case t.PubSub != nil && e.PubSub != nil:
+		return t.PubSub.Fires(e.PubSub)
+	case t.Github != nil && e.Github != nil:
+		return t.Github.Fires(e.Github)
+	case t.Bitbucket != nil && e.Bitbucket != nil:
+		return t.Bitbucket.Fires(e.Bitbucket)
+	case t.Manual != nil && e.Manual != nil:
+		return t.Manual.Fires(e.Manual)
+	}
+
+	return false
+}
+
+// match returns the action to take when EstafetteTrigger fires for an EstafetteEvent, with a release version of 'same' resolved to the
+// version in the event and the version, action and branch requested by a manual event taking precedence
+func (t *EstafetteTrigger) match(triggerType TriggerType, e *EstafetteEvent) EstafetteTriggerMatch {
+
+	match := EstafetteTriggerMatch{
+		Trigger:     t,
+		TriggerType: triggerType,
+	}
+
+	manual := e.Manual
+	if manual == nil {
+		manual = &EstafetteManualEvent{}
+	}
+
+	switch triggerType {
+	case TriggerTypeBuild:
+		if t.BuildAction != nil {
+			action := *t.BuildAction
+			if manual.Branch != "" {
+				action.Branch = manual.Branch
+			}
+			match.BuildAction = &action
+		}
+	case TriggerTypeRelease:
+		if t.ReleaseAction != nil {
+			action := *t.ReleaseAction
+			if action.Version == "same" {
+				if e.Pipeline != nil && e.Pipeline.BuildVersion != "" {
+					action.Version = e.Pipeline.BuildVersion
+				} else if e.Release != nil && e.Release.ReleaseVersion != "" {
+					action.Version = e.Release.ReleaseVersion
+				}
+			}
+			if manual.Version != "" {
+				action.Version = manual.Version
+			}
+			if manual.Action != "" {
+				action.Action = manual.Action
+			}
+			match.ReleaseAction = &action
+		}
+	case TriggerTypeBot:
+		if t.BotAction != nil {
+			action := *t.BotAction
+			if manual.Branch != "" {
+				action.Branch = manual.Branch
+			}
+			match.BotAction = &action
+		}
+	}
+
+	return match
+}
+
 // Fires indicates whether EstafettePipelineTrigger fires for an EstafettePipelineEvent
 func (p *EstafettePipelineTrigger) Fires(e *EstafettePipelineEvent) bool {
 
@@ -557,30 +746,132 @@ func (g *EstafetteGitTrigger) Fires(e *EstafetteGitEvent) bool {
 
 // Fires indicates whether EstafetteDockerTrigger fires for an EstafetteDockerEvent
 func (d *EstafetteDockerTrigger) Fires(e *EstafetteDockerEvent) bool {
-	return false
+	// compare event as regex
+	eventMatched, err := regexMatch(d.Event, e.Event)
+	if err != nil || !eventMatched {
+		return false
+	}
+
+	// compare image by name, or as regex if the name doesn't match
+	if d.Image != e.Image {
+		imageMatched, err := regexMatch(d.Image, e.Image)
+		if err != nil || !imageMatched {
+			return false
+		}
+	}
+
+	// compare tag as regex, an empty tag matches all tags
+	if d.Tag != "" {
+		tagMatched, err := regexMatch(d.Tag, e.Tag)
+		if err != nil || !tagMatched {
+			return false
+		}
+	}
+
+	return true
 }
 
-// Fires indicates whether EstafetteCronTrigger fires for an EstafetteCronEvent
+// Fires indicates whether EstafetteCronTrigger fires for an EstafetteCronEvent, comparing the schedule to the wall clock time of the event in
+// the timezone of the trigger; times skipped when daylight saving time starts don't fire, times repeated when it ends fire only the first time
 func (c *EstafetteCronTrigger) Fires(e *EstafetteCronEvent) bool {
 
+	schedule, _ := c.splitSchedule()
+
 	// ParseStandard expects 5 entries representing: minute, hour, day of month, month and day of week, in that order.
-	sched, err := cron.ParseStandard(c.Schedule)
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return false
+	}
+	location, err := c.location()
 	if err != nil {
 		return false
 	}
 
 	// truncate event time to the minute
-	eventTime := time.Date(e.Time.Year(), e.Time.Month(), e.Time.Day(), e.Time.Hour(), e.Time.Minute(), 0, 0, time.UTC)
+	eventTime := wallClock(e.Time.In(location))
 	// subtract 1 minute, otherwise the next time is at least 1 minute later
-	eventTime = eventTime.Add(time.Minute * -1)
-	// get the next time the cron expression would fire
-	nextTime := sched.Next(eventTime)
+	if !sched.Next(eventTime.Add(time.Minute * -1)).Equal(eventTime) {
+		return false
+	}
+
+	// an hour earlier shows the same wall clock time only in the hour repeated when daylight saving time ends
+	return !wallClock(e.Time.Add(time.Hour * -1).In(location)).Equal(eventTime)
+}
+
+// NextFireTimes returns the first n times after from at which EstafetteCronTrigger fires, so they can be shown before they happen
+func (c *EstafetteCronTrigger) NextFireTimes(from time.Time, n int) ([]time.Time, error) {
+
+	schedule, _ := c.splitSchedule()
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, err
+	}
+	location, err := c.location()
+	if err != nil {
+		return nil, err
+	}
+
+	times := []time.Time{}
 
-	return nextTime.Year() == e.Time.Year() &&
-		nextTime.Month() == e.Time.Month() &&
-		nextTime.Day() == e.Time.Day() &&
-		nextTime.Hour() == e.Time.Hour() &&
-		nextTime.Minute() == e.Time.Minute()
+	// the schedule is evaluated on wall clock times, which are converted to actual times in the timezone afterwards
+	next := wallClock(from.In(location))
+	for len(times) < n {
+		next = sched.Next(next)
+		if next.IsZero() {
+			break
+		}
+
+		fireTime := time.Date(next.Year(), next.Month(), next.Day(), next.Hour(), next.Minute(), 0, 0, location)
+		if !wallClock(fireTime).Equal(next) {
+			// skipped when daylight saving time starts
+			continue
+		}
+		if earlier := fireTime.Add(time.Hour * -1); wallClock(earlier).Equal(next) {
+			// repeated when daylight saving time ends, only the first one fires
+			fireTime = earlier
+		}
+		if !fireTime.After(from) {
+			continue
+		}
+
+		times = append(times, fireTime)
+	}
+
+	return times, nil
+}
+
+// splitSchedule returns the schedule without CRON_TZ= or TZ= prefix and the timezone set by that prefix
+func (c *EstafetteCronTrigger) splitSchedule() (schedule, timezone string) {
+	schedule = strings.TrimSpace(c.Schedule)
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if strings.HasPrefix(schedule, prefix) {
+			fields := strings.SplitN(schedule, " ", 2)
+			timezone = strings.TrimPrefix(fields[0], prefix)
+			schedule = ""
+			if len(fields) > 1 {
+				schedule = strings.TrimSpace(fields[1])
+			}
+			break
+		}
+	}
+	return
+}
+
+// location returns the timezone the schedule is in, which is UTC if none is set
+func (c *EstafetteCronTrigger) location() (*time.Location, error) {
+	_, timezone := c.splitSchedule()
+	if timezone == "" {
+		timezone = c.Timezone
+	}
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(timezone)
+}
+
+// wallClock returns the date, hour and minute of t as time in UTC, so cron schedules can be evaluated without daylight saving time
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
 }
 
 func regexMatch(pattern, value string) (bool, error) {
@@ -613,6 +904,13 @@ func regexMatch(pattern, value string) (bool, error) {
 	return match, nil
 }
 
+// validateRegex checks whether pattern, optionally prefixed with =~ or !~, is a valid regular expression for regexMatch
+func validateRegex(pattern string) error {
+	pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "=~"), "!~")
+	_, err := regexp.Compile(fmt.Sprintf("^(%v)$", strings.TrimSpace(pattern)))
+	return err
+}
+
 // Fires indicates whether EstafettePubSubTrigger fires for an EstafettePubSubEvent
 func (p *EstafettePubSubTrigger) Fires(e *EstafettePubSubEvent) bool {
 
@@ -626,18 +924,122 @@ func (p *EstafettePubSubTrigger) Fires(e *EstafettePubSubEvent) bool {
 		return false
 	}
 
+	// compare attributes as regex, a missing attribute counts as empty
+	for name, pattern := range p.Attributes {
+		attributeMatched, err := regexMatch(pattern, e.Message.Attributes[name])
+		if err != nil || !attributeMatched {
+			return false
+		}
+	}
+
+	if len(p.Data) == 0 {
+		return true
+	}
+
+	data, err := base64.StdEncoding.DecodeString(e.Message.Data)
+	if err != nil {
+		return false
+	}
+	for _, f := range p.Data {
+		if f != nil && !f.matches(data) {
+			return false
+		}
+	}
+
 	return true
 }
 
+// matches returns whether any of the values selected by the path from json data matches the value regex; without value regex it only checks
+// the path selects anything, and if nothing is selected the regex is matched against an empty string, so negative regexes match missing fields
+func (f *EstafettePubSubDataFilter) matches(data []byte) bool {
+
+	path, err := parseJSONPath(f.Path)
+	if err != nil {
+		return false
+	}
+	values, err := path.selectFrom(data)
+	if err != nil {
+		return false
+	}
+	if f.Value == "" {
+		return len(values) > 0
+	}
+	if len(values) == 0 {
+		values = []interface{}{""}
+	}
+
+	for _, v := range values {
+		if matched, err := regexMatch(f.Value, jsonPathValueString(v)); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// targets returns whether EstafetteManualEvent is for the build, or for the release or bot named target
+func (e *EstafetteManualEvent) targets(triggerType TriggerType, target string) bool {
+	return e.TargetType == triggerType && (triggerType == TriggerTypeBuild || e.Target == target)
+}
+
+// Fires indicates whether EstafetteManualTrigger fires for an EstafetteManualEvent; without users it fires for anyone
+func (m *EstafetteManualTrigger) Fires(e *EstafetteManualEvent) bool {
+	return len(m.Users) == 0 || containsString(m.Users, e.UserID)
+}
+
 // Fires indicates whether EstafetteGithubTrigger fires for an EstafetteGithubEvent
 func (p *EstafetteGithubTrigger) Fires(e *EstafetteGithubEvent) bool {
-	if e.Repository != "" && e.Repository != p.Repository {
+
+	// compare repository case insensitive; events without repository fire for any repository
+	if e.Repository != "" && !strings.EqualFold(p.Repository, e.Repository) {
 		return false
 	}
 
-	for _, ev := range p.Events {
-		if ev == e.Event {
-			return true
+	if !containsString(p.Events, e.Event) {
+		return false
+	}
+
+	if len(p.Actions) == 0 && p.Branch == "" && len(p.Labels) == 0 && p.Sender == "" {
+		return true
+	}
+
+	// the payload is only needed for filtering on it; a payload without the filtered field doesn't match
+	var payload githubPayload
+	if err := json.Unmarshal([]byte(e.Payload), &payload); err != nil {
+		return false
+	}
+
+	if len(p.Actions) > 0 && !containsString(p.Actions, payload.Action) {
+		return false
+	}
+
+	// compare branch as regex
+	if p.Branch != "" {
+		branchMatched, err := regexMatch(p.Branch, payload.branch())
+		if err != nil || !branchMatched {
+			return false
+		}
+	}
+
+	// fire if any of the labels is set
+	if len(p.Labels) > 0 {
+		labelMatched := false
+		for _, l := range payload.labels() {
+			if containsString(p.Labels, l) {
+				labelMatched = true
+				break
+			}
+		}
+		if !labelMatched {
+			return false
+		}
+	}
+
+	// compare sender as regex
+	if p.Sender != "" {
+		senderMatched, err := regexMatch(p.Sender, payload.Sender.Login)
+		if err != nil || !senderMatched {
+			return false
 		}
 	}
 
@@ -646,15 +1048,140 @@ func (p *EstafetteGithubTrigger) Fires(e *EstafetteGithubEvent) bool {
 
 // Fires indicates whether EstafetteBitbucketTrigger fires for an EstafetteBitbucketEvent
 func (p *EstafetteBitbucketTrigger) Fires(e *EstafetteBitbucketEvent) bool {
-	if e.Repository != "" && e.Repository != p.Repository {
+
+	// compare repository case insensitive; events without repository fire for any repository
+	if e.Repository != "" && !strings.EqualFold(p.Repository, e.Repository) {
 		return false
 	}
 
-	for _, ev := range p.Events {
-		if ev == e.Event {
-			return true
+	if !containsString(p.Events, e.Event) {
+		return false
+	}
+
+	if p.Branch == "" && p.Sender == "" {
+		return true
+	}
+
+	// the payload is only needed for filtering on it; a payload without the filtered field doesn't match
+	var payload bitbucketPayload
+	if err := json.Unmarshal([]byte(e.Payload), &payload); err != nil {
+		return false
+	}
+
+	// compare branch as regex
+	if p.Branch != "" {
+		branchMatched, err := regexMatch(p.Branch, payload.branch())
+		if err != nil || !branchMatched {
+			return false
+		}
+	}
+
+	// compare sender as regex
+	if p.Sender != "" {
+		senderMatched, err := regexMatch(p.Sender, payload.sender())
+		if err != nil || !senderMatched {
+			return false
 		}
 	}
 
 	return true
 }
+
+// githubPayload holds the fields of github webhook payloads the github trigger filters on
+type githubPayload struct {
+	Action      string `json:"action"`
+	Ref         string `json:"ref"`
+	PullRequest *struct {
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	} `json:"pull_request"`
+	Issue *struct {
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	} `json:"issue"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// branch returns the target branch of a pull request, or the pushed branch for other events
+func (p *githubPayload) branch() string {
+	if p.PullRequest != nil {
+		return p.PullRequest.Base.Ref
+	}
+	return strings.TrimPrefix(p.Ref, "refs/heads/")
+}
+
+// labels returns the names of the labels of the pull request or issue
+func (p *githubPayload) labels() (names []string) {
+	if p.PullRequest != nil {
+		for _, l := range p.PullRequest.Labels {
+			names = append(names, l.Name)
+		}
+	}
+	if p.Issue != nil {
+		for _, l := range p.Issue.Labels {
+			names = append(names, l.Name)
+		}
+	}
+	return names
+}
+
+// bitbucketPayload holds the fields of bitbucket webhook payloads the bitbucket trigger filters on
+type bitbucketPayload struct {
+	PullRequest *struct {
+		Destination struct {
+			Branch struct {
+				Name string `json:"name"`
+			} `json:"branch"`
+		} `json:"destination"`
+	} `json:"pullrequest"`
+	Push *struct {
+		Changes []struct {
+			New *struct {
+				Name string `json:"name"`
+			} `json:"new"`
+		} `json:"changes"`
+	} `json:"push"`
+	Actor struct {
+		Nickname string `json:"nickname"`
+		Username string `json:"username"`
+	} `json:"actor"`
+}
+
+// branch returns the destination branch of a pull request, or the first pushed branch for push events
+func (p *bitbucketPayload) branch() string {
+	if p.PullRequest != nil {
+		return p.PullRequest.Destination.Branch.Name
+	}
+	if p.Push != nil {
+		for _, c := range p.Push.Changes {
+			if c.New != nil {
+				return c.New.Name
+			}
+		}
+	}
+	return ""
+}
+
+// sender returns the nickname of the actor, or the username in older payloads that don't have a nickname
+func (p *bitbucketPayload) sender() string {
+	if p.Actor.Nickname != "" {
+		return p.Actor.Nickname
+	}
+	return p.Actor.Username
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
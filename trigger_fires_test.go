@@ -1,6 +1,7 @@
 package manifest
 
 import (
+	"encoding/base64"
 	"testing"
 	"time"
 
@@ -300,14 +301,734 @@ func TestEstafetteReleaseTriggerFires(t *testing.T) {
 }
 
 func TestEstafetteCronTriggerFires(t *testing.T) {
+
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	assert.Nil(t, err)
+
 	t.Run("ReturnsTrueIfEventTimeMatchesCronSchedule", func(t *testing.T) {
 
-		event := EstafetteCronEvent{
-			Time: time.Date(2019, 4, 5, 11, 10, 0, 0, time.UTC),
+		event := EstafetteCronEvent{
+			Time: time.Date(2019, 4, 5, 11, 10, 0, 0, time.UTC),
+		}
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "*/5 * * * *",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfEventTimeMatchesCronSchedule", func(t *testing.T) {
+
+		event := EstafetteCronEvent{
+			Time: time.Date(2019, 4, 5, 11, 12, 1, 0, time.UTC),
+		}
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "*/5 * * * *",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfEventTimeInOtherLocationMatchesCronScheduleInUTC", func(t *testing.T) {
+
+		event := EstafetteCronEvent{
+			Time: time.Date(2019, 4, 5, 13, 10, 0, 0, amsterdam),
+		}
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "10 11 * * *",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfEventTimeMatchesCronScheduleInTimezoneDuringSummerTime", func(t *testing.T) {
+
+		event := EstafetteCronEvent{
+			Time: time.Date(2021, 7, 1, 8, 0, 0, 0, time.UTC),
+		}
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "0 10 * * *",
+			Timezone: "Europe/Amsterdam",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfEventTimeOnlyMatchesCronScheduleInUTC", func(t *testing.T) {
+
+		event := EstafetteCronEvent{
+			Time: time.Date(2021, 7, 1, 10, 0, 0, 0, time.UTC),
+		}
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "0 10 * * *",
+			Timezone: "Europe/Amsterdam",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfEventTimeMatchesCronScheduleWithTimezonePrefixDuringWinterTime", func(t *testing.T) {
+
+		event := EstafetteCronEvent{
+			Time: time.Date(2021, 1, 15, 9, 0, 0, 0, time.UTC),
+		}
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "CRON_TZ=Europe/Amsterdam 0 10 * * *",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsTrueForFirstOccurrenceOfTimeRepeatedWhenDaylightSavingTimeEnds", func(t *testing.T) {
+
+		event := EstafetteCronEvent{
+			Time: time.Date(2021, 10, 31, 0, 30, 0, 0, time.UTC),
+		}
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "30 2 * * *",
+			Timezone: "Europe/Amsterdam",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseForSecondOccurrenceOfTimeRepeatedWhenDaylightSavingTimeEnds", func(t *testing.T) {
+
+		event := EstafetteCronEvent{
+			Time: time.Date(2021, 10, 31, 1, 30, 0, 0, time.UTC),
+		}
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "30 2 * * *",
+			Timezone: "Europe/Amsterdam",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfTimezoneIsInvalid", func(t *testing.T) {
+
+		event := EstafetteCronEvent{
+			Time: time.Date(2021, 7, 1, 8, 0, 0, 0, time.UTC),
+		}
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "0 10 * * *",
+			Timezone: "Europe/Atlantis",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+}
+
+func TestEstafetteCronTriggerNextFireTimes(t *testing.T) {
+	t.Run("ReturnsNextTimesInUTCIfTimezoneIsNotSet", func(t *testing.T) {
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "*/15 * * * *",
+		}
+
+		// act
+		times, err := trigger.NextFireTimes(time.Date(2021, 7, 1, 8, 0, 0, 0, time.UTC), 3)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []time.Time{
+			time.Date(2021, 7, 1, 8, 15, 0, 0, time.UTC),
+			time.Date(2021, 7, 1, 8, 30, 0, 0, time.UTC),
+			time.Date(2021, 7, 1, 8, 45, 0, 0, time.UTC),
+		}, times)
+	})
+
+	t.Run("SkipsTimeThatDoesNotExistWhenDaylightSavingTimeStarts", func(t *testing.T) {
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "30 2 * * *",
+			Timezone: "Europe/Amsterdam",
+		}
+
+		// act
+		times, err := trigger.NextFireTimes(time.Date(2021, 3, 27, 0, 0, 0, 0, time.UTC), 2)
+
+		if assert.Nil(t, err) && assert.Equal(t, 2, len(times)) {
+			assert.Equal(t, time.Date(2021, 3, 27, 1, 30, 0, 0, time.UTC), times[0].UTC())
+			assert.Equal(t, time.Date(2021, 3, 29, 0, 30, 0, 0, time.UTC), times[1].UTC())
+		}
+	})
+
+	t.Run("ReturnsTimeRepeatedWhenDaylightSavingTimeEndsOnce", func(t *testing.T) {
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "CRON_TZ=Europe/Amsterdam 30 2 * * *",
+		}
+
+		// act
+		times, err := trigger.NextFireTimes(time.Date(2021, 10, 30, 0, 0, 0, 0, time.UTC), 3)
+
+		if assert.Nil(t, err) && assert.Equal(t, 3, len(times)) {
+			assert.Equal(t, time.Date(2021, 10, 30, 0, 30, 0, 0, time.UTC), times[0].UTC())
+			assert.Equal(t, time.Date(2021, 10, 31, 0, 30, 0, 0, time.UTC), times[1].UTC())
+			assert.Equal(t, time.Date(2021, 11, 1, 1, 30, 0, 0, time.UTC), times[2].UTC())
+		}
+	})
+
+	t.Run("ReturnsTimesInTheTimezone", func(t *testing.T) {
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "0 10 * * *",
+			Timezone: "Europe/Amsterdam",
+		}
+
+		// act
+		times, err := trigger.NextFireTimes(time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC), 1)
+
+		if assert.Nil(t, err) && assert.Equal(t, 1, len(times)) {
+			assert.Equal(t, "2021-07-02T10:00:00+02:00", times[0].Format(time.RFC3339))
+		}
+	})
+
+	t.Run("ReturnsErrorIfScheduleIsInvalid", func(t *testing.T) {
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "0 * * * * *",
+		}
+
+		// act
+		_, err := trigger.NextFireTimes(time.Now(), 1)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestEstafetteGitTriggerFires(t *testing.T) {
+	t.Run("ReturnsTrueIfEventStatusNameAndBranchMatch", func(t *testing.T) {
+
+		event := EstafetteGitEvent{
+			Event:      "push",
+			Repository: "bitbucket.org/xivart/icarus_to_email_service_trigger",
+			Branch:     "main"}
+
+		trigger := EstafetteGitTrigger{
+			Event:      "push",
+			Repository: "bitbucket.org/xivart/icarus_to_email_service_trigger",
+			Branch:     "main",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+}
+
+func TestEstafetteDockerTriggerFires(t *testing.T) {
+	t.Run("ReturnsTrueIfEventAndImageMatchAndTagIsEmpty", func(t *testing.T) {
+
+		event := EstafetteDockerEvent{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "1.2.3",
+		}
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfImageMatchesAsRegex", func(t *testing.T) {
+
+		event := EstafetteDockerEvent{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "1.2.3",
+		}
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "estafette/estafette-ci-.+",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfImageContainingRegexCharactersMatchesExactly", func(t *testing.T) {
+
+		event := EstafetteDockerEvent{
+			Event: "push",
+			Image: "gcr.io/estafette/estafette-ci-api",
+			Tag:   "1.2.3",
+		}
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "gcr.io/estafette/estafette-ci-api",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfImageDoesNotMatch", func(t *testing.T) {
+
+		event := EstafetteDockerEvent{
+			Event: "push",
+			Image: "estafette/estafette-ci-web",
+			Tag:   "1.2.3",
+		}
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfTagMatchesRegex", func(t *testing.T) {
+
+		event := EstafetteDockerEvent{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "1.2.3",
+		}
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "[0-9]+\\.[0-9]+\\.[0-9]+",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfTagDoesNotMatchRegex", func(t *testing.T) {
+
+		event := EstafetteDockerEvent{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "1.2.3-beta",
+		}
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "[0-9]+\\.[0-9]+\\.[0-9]+",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfNegativeLookupTagRegexDoesNotMatch", func(t *testing.T) {
+
+		event := EstafetteDockerEvent{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "1.2.3",
+		}
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "!~ .+-beta",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfNegativeLookupTagRegexDoesMatch", func(t *testing.T) {
+
+		event := EstafetteDockerEvent{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "1.2.3-beta",
+		}
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "!~ .+-beta",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfEventDoesNotMatch", func(t *testing.T) {
+
+		event := EstafetteDockerEvent{
+			Event: "delete",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "1.2.3",
+		}
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+}
+
+func TestEstafetteGithubTriggerFires(t *testing.T) {
+	t.Run("ReturnsTrueIfEventIsContainedInTriggerEvents", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event: "create",
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events: []string{
+				"commit_comment",
+				"create",
+				"delete",
+				"deployment",
+				"deployment_status",
+				"fork",
+				"gollum",
+				"installation",
+				"installation_repositories",
+			},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfEventIsNotContainedInTriggerEvents", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event:      "issues",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    `{"action":"labeled","pull_request":{"base":{"ref":"main"},"labels":[{"name":"bug"},{"name":"deploy"}]},"sender":{"login":"dependabot[bot]"}}`,
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events:     []string{"pull_request"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfRepositoryMatchesCaseInsensitive", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event:      "pull_request",
+			Repository: "github.com/Estafette/Estafette-CI-Manifest",
+			Payload:    `{"action":"labeled","pull_request":{"base":{"ref":"main"},"labels":[{"name":"bug"},{"name":"deploy"}]},"sender":{"login":"dependabot[bot]"}}`,
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events:     []string{"pull_request"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfEventHasNoRepository", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event: "pull_request",
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events:     []string{"pull_request"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfRepositoryDoesNotMatch", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event:      "pull_request",
+			Repository: "github.com/estafette/estafette-ci-api",
+			Payload:    `{"action":"labeled","pull_request":{"base":{"ref":"main"},"labels":[{"name":"bug"},{"name":"deploy"}]},"sender":{"login":"dependabot[bot]"}}`,
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events:     []string{"pull_request"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfActionBranchLabelAndSenderMatch", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event:      "pull_request",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    `{"action":"labeled","pull_request":{"base":{"ref":"main"},"labels":[{"name":"bug"},{"name":"deploy"}]},"sender":{"login":"dependabot[bot]"}}`,
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events:     []string{"pull_request"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Actions:    []string{"opened", "labeled"},
+			Branch:     "main|master",
+			Labels:     []string{"deploy"},
+			Sender:     "dependabot.*",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfActionDoesNotMatch", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event:      "pull_request",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    `{"action":"labeled","pull_request":{"base":{"ref":"main"},"labels":[{"name":"bug"},{"name":"deploy"}]},"sender":{"login":"dependabot[bot]"}}`,
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events:     []string{"pull_request"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Actions:    []string{"opened", "synchronize"},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfBranchDoesNotMatch", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event:      "pull_request",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    `{"action":"labeled","pull_request":{"base":{"ref":"main"},"labels":[{"name":"bug"},{"name":"deploy"}]},"sender":{"login":"dependabot[bot]"}}`,
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events:     []string{"pull_request"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Branch:     "release-.+",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfNoneOfTheLabelsIsSet", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event:      "pull_request",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    `{"action":"labeled","pull_request":{"base":{"ref":"main"},"labels":[{"name":"bug"},{"name":"deploy"}]},"sender":{"login":"dependabot[bot]"}}`,
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events:     []string{"pull_request"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Labels:     []string{"skip-ci", "wip"},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfNegativeLookupSenderRegexDoesMatch", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event:      "pull_request",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    `{"action":"labeled","pull_request":{"base":{"ref":"main"},"labels":[{"name":"bug"},{"name":"deploy"}]},"sender":{"login":"dependabot[bot]"}}`,
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events:     []string{"pull_request"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Sender:     "!~ dependabot.*",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfPushedBranchMatches", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event:      "push",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    `{"ref":"refs/heads/main","sender":{"login":"jorrit"}}`,
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events:     []string{"push"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Branch:     "main",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfPayloadIsMissingForFilter", func(t *testing.T) {
+
+		event := EstafetteGithubEvent{
+			Event:      "pull_request",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    ``,
+		}
+
+		trigger := EstafetteGithubTrigger{
+			Events:     []string{"pull_request"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Branch:     "main",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+}
+
+func TestEstafetteBitbucketTriggerFires(t *testing.T) {
+	t.Run("ReturnsTrueIfEventIsContainedInTriggerEvents", func(t *testing.T) {
+
+		event := EstafetteBitbucketEvent{
+			Event: "pullrequest:comment_created",
+		}
+
+		trigger := EstafetteBitbucketTrigger{
+			Events: []string{
+				"pullrequest:fulfilled",
+				"pullrequest:rejected",
+				"pullrequest:comment_created",
+				"pullrequest:comment_updated",
+				"pullrequest:comment_deleted",
+			},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfEventIsNotContainedInTriggerEvents", func(t *testing.T) {
+
+		event := EstafetteBitbucketEvent{
+			Event:      "pullrequest:rejected",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    `{"pullrequest":{"destination":{"branch":{"name":"main"}}},"actor":{"nickname":"jorrit"}}`,
 		}
 
-		trigger := EstafetteCronTrigger{
-			Schedule: "*/5 * * * *",
+		trigger := EstafetteBitbucketTrigger{
+			Events:     []string{"pull_request"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfRepositoryMatchesCaseInsensitive", func(t *testing.T) {
+
+		event := EstafetteBitbucketEvent{
+			Event:      "pullrequest:created",
+			Repository: "github.com/Estafette/Estafette-CI-Manifest",
+			Payload:    `{"pullrequest":{"destination":{"branch":{"name":"main"}}},"actor":{"nickname":"jorrit"}}`,
+		}
+
+		trigger := EstafetteBitbucketTrigger{
+			Events:     []string{"pullrequest:created"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
 		}
 
 		// act
@@ -316,35 +1037,36 @@ func TestEstafetteCronTriggerFires(t *testing.T) {
 		assert.True(t, fires)
 	})
 
-	t.Run("ReturnsTrueIfEventTimeMatchesCronSchedule", func(t *testing.T) {
+	t.Run("ReturnsTrueIfEventHasNoRepository", func(t *testing.T) {
 
-		event := EstafetteCronEvent{
-			Time: time.Date(2019, 4, 5, 11, 12, 1, 0, time.UTC),
+		event := EstafetteBitbucketEvent{
+			Event: "pullrequest:created",
 		}
 
-		trigger := EstafetteCronTrigger{
-			Schedule: "*/5 * * * *",
+		trigger := EstafetteBitbucketTrigger{
+			Events:     []string{"pullrequest:created"},
+			Repository: "bitbucket.org/estafette/estafette-ci-manifest",
 		}
 
 		// act
 		fires := trigger.Fires(&event)
 
-		assert.False(t, fires)
+		assert.True(t, fires)
 	})
-}
 
-func TestEstafetteGitTriggerFires(t *testing.T) {
-	t.Run("ReturnsTrueIfEventStatusNameAndBranchMatch", func(t *testing.T) {
+	t.Run("ReturnsTrueIfBranchAndSenderMatch", func(t *testing.T) {
 
-		event := EstafetteGitEvent{
-			Event:      "push",
-			Repository: "bitbucket.org/xivart/icarus_to_email_service_trigger",
-			Branch:     "main"}
+		event := EstafetteBitbucketEvent{
+			Event:      "pullrequest:created",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    `{"pullrequest":{"destination":{"branch":{"name":"main"}}},"actor":{"nickname":"jorrit"}}`,
+		}
 
-		trigger := EstafetteGitTrigger{
-			Event:      "push",
-			Repository: "bitbucket.org/xivart/icarus_to_email_service_trigger",
+		trigger := EstafetteBitbucketTrigger{
+			Events:     []string{"pullrequest:created"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
 			Branch:     "main",
+			Sender:     "jorrit",
 		}
 
 		// act
@@ -352,57 +1074,45 @@ func TestEstafetteGitTriggerFires(t *testing.T) {
 
 		assert.True(t, fires)
 	})
-}
 
-func TestEstafetteGithubTriggerFires(t *testing.T) {
-	t.Run("ReturnsTrueIfEventIsContainedInTriggerEvents", func(t *testing.T) {
+	t.Run("ReturnsFalseIfBranchDoesNotMatch", func(t *testing.T) {
 
-		event := EstafetteGithubEvent{
-			Event: "create",
+		event := EstafetteBitbucketEvent{
+			Event:      "pullrequest:created",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    `{"pullrequest":{"destination":{"branch":{"name":"main"}}},"actor":{"nickname":"jorrit"}}`,
 		}
 
-		trigger := EstafetteGithubTrigger{
-			Events: []string{
-				"commit_comment",
-				"create",
-				"delete",
-				"deployment",
-				"deployment_status",
-				"fork",
-				"gollum",
-				"installation",
-				"installation_repositories",
-			},
+		trigger := EstafetteBitbucketTrigger{
+			Events:     []string{"pullrequest:created"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Branch:     "develop",
 		}
 
 		// act
 		fires := trigger.Fires(&event)
 
-		assert.True(t, fires)
+		assert.False(t, fires)
 	})
-}
 
-func TestEstafetteBitbucketTriggerFires(t *testing.T) {
-	t.Run("ReturnsTrueIfEventIsContainedInTriggerEvents", func(t *testing.T) {
+	t.Run("ReturnsFalseIfSenderDoesNotMatch", func(t *testing.T) {
 
-		event := EstafetteGithubEvent{
-			Event: "pullrequest:comment_created",
+		event := EstafetteBitbucketEvent{
+			Event:      "pullrequest:created",
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Payload:    `{"pullrequest":{"destination":{"branch":{"name":"main"}}},"actor":{"nickname":"jorrit"}}`,
 		}
 
-		trigger := EstafetteGithubTrigger{
-			Events: []string{
-				"pullrequest:fulfilled",
-				"pullrequest:rejected",
-				"pullrequest:comment_created",
-				"pullrequest:comment_updated",
-				"pullrequest:comment_deleted",
-			},
+		trigger := EstafetteBitbucketTrigger{
+			Events:     []string{"pullrequest:created"},
+			Repository: "github.com/estafette/estafette-ci-manifest",
+			Sender:     "=~ bot-.+",
 		}
 
 		// act
 		fires := trigger.Fires(&event)
 
-		assert.True(t, fires)
+		assert.False(t, fires)
 	})
 }
 
@@ -514,4 +1224,287 @@ func TestEstafettePubsubTriggerFires(t *testing.T) {
 
 		assert.False(t, fires)
 	})
+
+	gcrMessage := PubsubMessage{
+		Attributes: map[string]string{"eventType": "INSERT"},
+		Data:       base64.StdEncoding.EncodeToString([]byte(`{"action":"INSERT","digest":"gcr.io/my-project/my-image@sha256:abc","tag":"gcr.io/my-project/my-image:1.2.3"}`)),
+	}
+
+	t.Run("ReturnsTrueIfAttributesAndDataMatch", func(t *testing.T) {
+
+		event := EstafettePubSubEvent{
+			Project: "my-project",
+			Topic:   "gcr",
+			Message: gcrMessage,
+		}
+
+		trigger := EstafettePubSubTrigger{
+			Project:    "my-project",
+			Topic:      "gcr",
+			Attributes: map[string]string{"eventType": "INSERT|UPDATE"},
+			Data: []*EstafettePubSubDataFilter{
+				{Path: "$.action", Value: "INSERT"},
+				{Path: "$.tag", Value: "gcr.io/my-project/my-image:.+"},
+			},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfAttributeDoesNotMatch", func(t *testing.T) {
+
+		event := EstafettePubSubEvent{
+			Project: "my-project",
+			Topic:   "gcr",
+			Message: gcrMessage,
+		}
+
+		trigger := EstafettePubSubTrigger{
+			Project:    "my-project",
+			Topic:      "gcr",
+			Attributes: map[string]string{"eventType": "DELETE"},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfDataDoesNotMatch", func(t *testing.T) {
+
+		event := EstafettePubSubEvent{
+			Project: "my-project",
+			Topic:   "gcr",
+			Message: gcrMessage,
+		}
+
+		trigger := EstafettePubSubTrigger{
+			Project: "my-project",
+			Topic:   "gcr",
+			Data: []*EstafettePubSubDataFilter{
+				{Path: "$.tag", Value: "gcr.io/my-project/another-image:.+"},
+			},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfNegativeRegexIsUsedForMissingData", func(t *testing.T) {
+
+		event := EstafettePubSubEvent{
+			Project: "my-project",
+			Topic:   "gcr",
+			Message: gcrMessage,
+		}
+
+		trigger := EstafettePubSubTrigger{
+			Project: "my-project",
+			Topic:   "gcr",
+			Data: []*EstafettePubSubDataFilter{
+				{Path: "$.labels[*]", Value: "!~ skip-ci"},
+			},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfDataWithoutValueIsMissing", func(t *testing.T) {
+
+		event := EstafettePubSubEvent{
+			Project: "my-project",
+			Topic:   "gcr",
+			Message: gcrMessage,
+		}
+
+		trigger := EstafettePubSubTrigger{
+			Project: "my-project",
+			Topic:   "gcr",
+			Data: []*EstafettePubSubDataFilter{
+				{Path: "$.labels"},
+			},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfDataIsNotBase64EncodedJSON", func(t *testing.T) {
+
+		event := EstafettePubSubEvent{
+			Project: "my-project",
+			Topic:   "gcr",
+			Message: PubsubMessage{Data: "not base64"},
+		}
+
+		trigger := EstafettePubSubTrigger{
+			Project: "my-project",
+			Topic:   "gcr",
+			Data: []*EstafettePubSubDataFilter{
+				{Path: "$.action", Value: "INSERT"},
+			},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+}
+
+func TestEstafetteManualTriggerFires(t *testing.T) {
+	t.Run("ReturnsTrueIfUsersAreEmpty", func(t *testing.T) {
+
+		event := EstafetteManualEvent{
+			UserID: "jorrit@estafette.io",
+		}
+
+		trigger := EstafetteManualTrigger{}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfUserIsContainedInTriggerUsers", func(t *testing.T) {
+
+		event := EstafetteManualEvent{
+			UserID: "jorrit@estafette.io",
+		}
+
+		trigger := EstafetteManualTrigger{
+			Users: []string{"jorrit@estafette.io", "admin@estafette.io"},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfUserIsNotContainedInTriggerUsers", func(t *testing.T) {
+
+		event := EstafetteManualEvent{
+			UserID: "someone@estafette.io",
+		}
+
+		trigger := EstafetteManualTrigger{
+			Users: []string{"jorrit@estafette.io"},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+}
+
+func TestEstafetteTriggerFires(t *testing.T) {
+	t.Run("ReturnsTrueIfTriggerOfTheSameTypeAsTheEventFires", func(t *testing.T) {
+
+		event := EstafetteEvent{
+			Docker: &EstafetteDockerEvent{
+				Event: "push",
+				Image: "golang",
+				Tag:   "1.17-alpine",
+			},
+		}
+
+		trigger := EstafetteTrigger{
+			Docker: &EstafetteDockerTrigger{
+				Event: "push",
+				Image: "golang",
+			},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfTriggerOfTheSameTypeAsTheEventDoesNotFire", func(t *testing.T) {
+
+		event := EstafetteEvent{
+			Docker: &EstafetteDockerEvent{
+				Event: "push",
+				Image: "alpine",
+			},
+		}
+
+		trigger := EstafetteTrigger{
+			Docker: &EstafetteDockerTrigger{
+				Event: "push",
+				Image: "golang",
+			},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfTriggerIsOfAnotherTypeThanTheEvent", func(t *testing.T) {
+
+		event := EstafetteEvent{
+			Manual: &EstafetteManualEvent{
+				UserID: "jorrit@estafette.io",
+			},
+		}
+
+		trigger := EstafetteTrigger{
+			Docker: &EstafetteDockerTrigger{
+				Event: "push",
+				Image: "golang",
+			},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.False(t, fires)
+	})
+
+	t.Run("ReturnsTrueIfManualTriggerFiresForManualEvent", func(t *testing.T) {
+
+		event := EstafetteEvent{
+			Manual: &EstafetteManualEvent{
+				UserID: "jorrit@estafette.io",
+			},
+		}
+
+		trigger := EstafetteTrigger{
+			Manual: &EstafetteManualTrigger{},
+		}
+
+		// act
+		fires := trigger.Fires(&event)
+
+		assert.True(t, fires)
+	})
+
+	t.Run("ReturnsFalseIfEventIsNil", func(t *testing.T) {
+
+		trigger := EstafetteTrigger{
+			Manual: &EstafetteManualTrigger{},
+		}
+
+		// act
+		fires := trigger.Fires(nil)
+
+		assert.False(t, fires)
+	})
 }
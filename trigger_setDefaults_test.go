@@ -95,6 +95,32 @@ func TestEstafetteGitTriggerSetDefaults(t *testing.T) {
 	})
 }
 
+func TestEstafetteDockerTriggerSetDefaults(t *testing.T) {
+	t.Run("SetsEventToPushIfEmpty", func(t *testing.T) {
+
+		trigger := EstafetteDockerTrigger{
+			Event: "",
+		}
+
+		// act
+		trigger.SetDefaults()
+
+		assert.Equal(t, "push", trigger.Event)
+	})
+
+	t.Run("KeepsTagEmptyIfEmpty", func(t *testing.T) {
+
+		trigger := EstafetteDockerTrigger{
+			Tag: "",
+		}
+
+		// act
+		trigger.SetDefaults()
+
+		assert.Equal(t, "", trigger.Tag)
+	})
+}
+
 func TestEstafetteTriggerBuildActionSetDefaults(t *testing.T) {
 	t.Run("SetsBranchToMasterIfEmpty", func(t *testing.T) {
 
@@ -363,6 +363,289 @@ func TestEstafetteGitTriggerValidate(t *testing.T) {
 	})
 }
 
+func TestEstafetteDockerTriggerValidate(t *testing.T) {
+	t.Run("ReturnsErrorIfEventIsEmpty", func(t *testing.T) {
+
+		trigger := EstafetteDockerTrigger{
+			Event: "",
+			Image: "estafette/estafette-ci-api",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfImageIsEmpty", func(t *testing.T) {
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			assert.Equal(t, "image", errs[0].Path)
+		}
+	})
+
+	t.Run("ReturnsErrorIfImageIsInvalidRegex", func(t *testing.T) {
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "estafette/(estafette-ci-api",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			assert.Equal(t, "image", errs[0].Path)
+		}
+	})
+
+	t.Run("ReturnsErrorIfTagIsInvalidRegex", func(t *testing.T) {
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "estafette/estafette-ci-api",
+			Tag:   "!~ [0-9+",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			assert.Equal(t, "tag", errs[0].Path)
+		}
+	})
+
+	t.Run("ReturnsNoErrorIfValid", func(t *testing.T) {
+
+		trigger := EstafetteDockerTrigger{
+			Event: "push",
+			Image: "estafette/estafette-ci-.+",
+			Tag:   "=~ [0-9]+\\.[0-9]+\\.[0-9]+",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		assert.Nil(t, err)
+	})
+}
+
+func TestEstafettePubSubTriggerValidate(t *testing.T) {
+	t.Run("ReturnsErrorIfProjectAndTopicAreEmpty", func(t *testing.T) {
+
+		trigger := EstafettePubSubTrigger{}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 2, len(errs)) {
+				assert.Equal(t, "project", errs[0].Path)
+				assert.Equal(t, "topic", errs[1].Path)
+			}
+		}
+	})
+
+	t.Run("ReturnsErrorIfAttributeIsInvalidRegex", func(t *testing.T) {
+
+		trigger := EstafettePubSubTrigger{
+			Project:    "my-project",
+			Topic:      "gcr",
+			Attributes: map[string]string{"eventType": "(INSERT"},
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			assert.Equal(t, "attributes.eventType", errs[0].Path)
+		}
+	})
+
+	t.Run("ReturnsErrorsIfDataPathOrValueIsInvalid", func(t *testing.T) {
+
+		trigger := EstafettePubSubTrigger{
+			Project: "my-project",
+			Topic:   "gcr",
+			Data: []*EstafettePubSubDataFilter{
+				{Path: "$.action", Value: "[INSERT"},
+				{Path: "action", Value: "INSERT"},
+				{Value: "INSERT"},
+			},
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			if assert.Equal(t, 3, len(errs)) {
+				assert.Equal(t, "data[0].value", errs[0].Path)
+				assert.Equal(t, "data[1].path", errs[1].Path)
+				assert.Equal(t, "Invalid pubsub.data.path in your trigger: JSONPath action is not valid, it should start with $ like $.target.tag", errs[1].Message)
+				assert.Equal(t, "data[2].path", errs[2].Path)
+			}
+		}
+	})
+
+	t.Run("ReturnsNoErrorIfValid", func(t *testing.T) {
+
+		trigger := EstafettePubSubTrigger{
+			Project:    "my-project",
+			Topic:      "gcr",
+			Attributes: map[string]string{"eventType": "INSERT|UPDATE"},
+			Data: []*EstafettePubSubDataFilter{
+				{Path: "$.tag", Value: "=~ gcr.io/my-project/.+"},
+				{Path: "$['digest']"},
+			},
+		}
+
+		// act
+		err := trigger.Validate()
+
+		assert.Nil(t, err)
+	})
+}
+
+func TestEstafetteGithubTriggerValidate(t *testing.T) {
+	t.Run("ReturnsErrorIfEventsAreEmpty", func(t *testing.T) {
+
+		trigger := EstafetteGithubTrigger{
+			Repository: "github.com/estafette/estafette-ci-manifest",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			assert.Equal(t, "events", errs[0].Path)
+		}
+	})
+
+	t.Run("ReturnsErrorIfBranchIsInvalidRegex", func(t *testing.T) {
+
+		trigger := EstafetteGithubTrigger{
+			Events: []string{"pull_request"},
+			Branch: "(main",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			assert.Equal(t, "branch", errs[0].Path)
+		}
+	})
+
+	t.Run("ReturnsErrorIfSenderIsInvalidRegex", func(t *testing.T) {
+
+		trigger := EstafetteGithubTrigger{
+			Events: []string{"pull_request"},
+			Sender: "!~ [bot",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			assert.Equal(t, "sender", errs[0].Path)
+		}
+	})
+
+	t.Run("ReturnsNoErrorIfValid", func(t *testing.T) {
+
+		trigger := EstafetteGithubTrigger{
+			Events: []string{"pull_request"},
+			Branch: "main|master",
+			Sender: "!~ .+\\[bot\\]",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		assert.Nil(t, err)
+	})
+}
+
+func TestEstafetteBitbucketTriggerValidate(t *testing.T) {
+	t.Run("ReturnsErrorIfEventsAreEmpty", func(t *testing.T) {
+
+		trigger := EstafetteBitbucketTrigger{
+			Repository: "github.com/estafette/estafette-ci-manifest",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			assert.Equal(t, "events", errs[0].Path)
+		}
+	})
+
+	t.Run("ReturnsErrorIfBranchIsInvalidRegex", func(t *testing.T) {
+
+		trigger := EstafetteBitbucketTrigger{
+			Events: []string{"pullrequest:created"},
+			Branch: "(main",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			assert.Equal(t, "branch", errs[0].Path)
+		}
+	})
+
+	t.Run("ReturnsErrorIfSenderIsInvalidRegex", func(t *testing.T) {
+
+		trigger := EstafetteBitbucketTrigger{
+			Events: []string{"pullrequest:created"},
+			Sender: "!~ [bot",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			errs := err.(ValidationErrors)
+			assert.Equal(t, "sender", errs[0].Path)
+		}
+	})
+
+	t.Run("ReturnsNoErrorIfValid", func(t *testing.T) {
+
+		trigger := EstafetteBitbucketTrigger{
+			Events: []string{"pullrequest:created"},
+			Branch: "main|master",
+			Sender: "!~ .+\\[bot\\]",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		assert.Nil(t, err)
+	})
+}
+
 func TestEstafetteCronTriggerValidate(t *testing.T) {
 	t.Run("ReturnsErrorIfScheduleIsEmpty", func(t *testing.T) {
 
@@ -399,4 +682,61 @@ func TestEstafetteCronTriggerValidate(t *testing.T) {
 
 		assert.Nil(t, err)
 	})
+
+	t.Run("ReturnsErrorIfTimezoneIsInvalid", func(t *testing.T) {
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "*/5 * * * *",
+			Timezone: "Europe/Atlantis",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "timezone", err.(*ValidationError).Path)
+		}
+	})
+
+	t.Run("ReturnsErrorIfTimezonePrefixIsInvalid", func(t *testing.T) {
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "CRON_TZ=Europe/Atlantis */5 * * * *",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "schedule", err.(*ValidationError).Path)
+		}
+	})
+
+	t.Run("ReturnsErrorIfBothTimezoneAndTimezonePrefixAreSet", func(t *testing.T) {
+
+		trigger := EstafetteCronTrigger{
+			Schedule: "TZ=Europe/Amsterdam */5 * * * *",
+			Timezone: "Europe/Amsterdam",
+		}
+
+		// act
+		err := trigger.Validate()
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "timezone", err.(*ValidationError).Path)
+		}
+	})
+
+	t.Run("ReturnsNoErrorIfScheduleWithTimezoneIsValid", func(t *testing.T) {
+
+		for _, trigger := range []EstafetteCronTrigger{
+			{Schedule: "0 10 * * *", Timezone: "Europe/Amsterdam"},
+			{Schedule: "CRON_TZ=America/New_York 0 10 * * 1-5"},
+		} {
+			// act
+			err := trigger.Validate()
+
+			assert.Nil(t, err)
+		}
+	})
 }
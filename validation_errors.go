@@ -0,0 +1,83 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError is a single problem found while parsing or validating a manifest, located by a structured path like releases.production.triggers[1].pipeline.status
+// and - when read from a file or string - by its position in the source
+type ValidationError struct {
+	Path     string    `yaml:"path,omitempty" json:"path,omitempty"`
+	Message  string    `yaml:"message,omitempty" json:"message,omitempty"`
+	Position *Position `yaml:"position,omitempty" json:"position,omitempty"`
+}
+
+// Error returns the message prefixed with the position and path if set
+func (e *ValidationError) Error() string {
+	message := e.Message
+	if e.Path != "" {
+		message = fmt.Sprintf("%v: %v", e.Path, message)
+	}
+	if e.Position != nil {
+		if position := e.Position.String(); position != "" {
+			message = fmt.Sprintf("%v: %v", position, message)
+		}
+	}
+	return message
+}
+
+// ValidationErrors holds all problems found while validating a manifest
+type ValidationErrors []*ValidationError
+
+// Error returns all problems, one per line
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// add appends err at path; paths of nested validation errors are prefixed with path
+func (errs *ValidationErrors) add(path string, err error) {
+	switch e := err.(type) {
+	case nil:
+		return
+	case ValidationErrors:
+		for _, ve := range e {
+			*errs = append(*errs, &ValidationError{Path: joinPath(path, ve.Path), Message: ve.Message, Position: ve.Position})
+		}
+	case *ValidationError:
+		*errs = append(*errs, &ValidationError{Path: joinPath(path, e.Path), Message: e.Message, Position: e.Position})
+	default:
+		*errs = append(*errs, &ValidationError{Path: path, Message: err.Error()})
+	}
+}
+
+// addf appends a new problem at path
+func (errs *ValidationErrors) addf(path, format string, a ...interface{}) {
+	*errs = append(*errs, &ValidationError{Path: path, Message: fmt.Sprintf(format, a...)})
+}
+
+// errorOrNil returns nil if no problems were found, so callers don't end up with a non-nil error interface holding an empty slice
+func (errs ValidationErrors) errorOrNil() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// joinPath combines a parent and child path, so that sequence indexes attach without a dot
+func joinPath(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+	if child == "" {
+		return parent
+	}
+	if strings.HasPrefix(child, "[") {
+		return parent + child
+	}
+	return parent + "." + child
+}
@@ -0,0 +1,52 @@
+package manifest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationErrors(t *testing.T) {
+	t.Run("ErrorReturnsAllProblemsOnSeparateLines", func(t *testing.T) {
+
+		errs := ValidationErrors{
+			{Path: "stages.build.image", Message: "Stage build has no image set"},
+			{Path: "", Message: "Some problem without a path"},
+		}
+
+		// act
+		message := errs.Error()
+
+		assert.Equal(t, "stages.build.image: Stage build has no image set\nSome problem without a path", message)
+	})
+
+	t.Run("AddPrefixesPathsOfNestedValidationErrors", func(t *testing.T) {
+
+		var errs ValidationErrors
+
+		// act
+		errs.add("releases.production", ValidationErrors{{Path: "triggers[1]", Message: "a"}, {Path: "stages.deploy", Message: "b"}})
+		errs.add("bots.pr-bot", &ValidationError{Path: "builder.track", Message: "c"})
+		errs.add("stages", fmt.Errorf("d"))
+		errs.add("stages", nil)
+
+		if assert.Equal(t, 4, len(errs)) {
+			assert.Equal(t, "releases.production.triggers[1]", errs[0].Path)
+			assert.Equal(t, "releases.production.stages.deploy", errs[1].Path)
+			assert.Equal(t, "bots.pr-bot.builder.track", errs[2].Path)
+			assert.Equal(t, "stages", errs[3].Path)
+			assert.Equal(t, "d", errs[3].Message)
+		}
+	})
+
+	t.Run("ErrorOrNilReturnsUntypedNilIfEmpty", func(t *testing.T) {
+
+		var errs ValidationErrors
+
+		// act
+		err := errs.errorOrNil()
+
+		assert.Nil(t, err)
+	})
+}
@@ -0,0 +1,238 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WhenExpression is a parsed when expression of a stage or service, like status == 'succeeded' && branch == 'main'
+type WhenExpression struct {
+	Source string
+	Root   WhenNode
+}
+
+// WhenContext holds the values the identifiers in a when expression evaluate to
+type WhenContext struct {
+	Status  string
+	Branch  string
+	Action  string
+	Server  string
+	Trigger string
+	Labels  map[string]string
+}
+
+// WhenNode is a node in the syntax tree of a when expression
+type WhenNode interface {
+	String() string
+	kind() whenKind
+	evaluate(context WhenContext) (interface{}, error)
+}
+
+// WhenIdentifier is one of status, branch, action, server, trigger or labels.<name>
+type WhenIdentifier struct {
+	Name string
+}
+
+// WhenLiteral is a quoted string or true or false
+type WhenLiteral struct {
+	Value interface{}
+}
+
+// WhenUnary is the negation of a boolean expression
+type WhenUnary struct {
+	Operator string
+	Operand  WhenNode
+}
+
+// WhenBinary is a comparison, regular expression match or logical combination of two expressions; like in trigger filters =~ and !~ match
+// the whole value, as if the pattern is wrapped in ^( and )$
+type WhenBinary struct {
+	Operator string
+	Left     WhenNode
+	Right    WhenNode
+}
+
+type whenKind int
+
+const (
+	whenKindString whenKind = iota
+	whenKindBool
+)
+
+func (k whenKind) String() string {
+	if k == whenKindBool {
+		return "boolean"
+	}
+	return "string"
+}
+
+// whenIdentifiers lists the identifiers available in when expressions, besides labels.<name>
+var whenIdentifiers = []string{"status", "branch", "action", "server", "trigger"}
+
+// whenLabelPrefix is the prefix of identifiers referencing a label of the manifest
+const whenLabelPrefix = "labels."
+
+// Evaluate returns whether the expression holds for context
+func (e *WhenExpression) Evaluate(context WhenContext) (bool, error) {
+	value, err := e.Root.evaluate(context)
+	if err != nil {
+		return false, err
+	}
+	return value.(bool), nil
+}
+
+// Identifiers returns the distinct identifiers used in the expression, sorted by name
+func (e *WhenExpression) Identifiers() (identifiers []string) {
+	seen := map[string]bool{}
+	var walk func(node WhenNode)
+	walk = func(node WhenNode) {
+		switch n := node.(type) {
+		case *WhenIdentifier:
+			if !seen[n.Name] {
+				seen[n.Name] = true
+				identifiers = append(identifiers, n.Name)
+			}
+		case *WhenUnary:
+			walk(n.Operand)
+		case *WhenBinary:
+			walk(n.Left)
+			walk(n.Right)
+		}
+	}
+	walk(e.Root)
+	sort.Strings(identifiers)
+
+	return identifiers
+}
+
+// String returns the expression in its canonical form
+func (e *WhenExpression) String() string {
+	return e.Root.String()
+}
+
+// EvaluateWhen parses expression and returns whether it holds for context
+func EvaluateWhen(expression string, context WhenContext) (bool, error) {
+	e, err := ParseWhen(expression)
+	if err != nil {
+		return false, err
+	}
+	return e.Evaluate(context)
+}
+
+// String returns the name of the identifier
+func (n *WhenIdentifier) String() string {
+	return n.Name
+}
+
+func (n *WhenIdentifier) kind() whenKind {
+	return whenKindString
+}
+
+func (n *WhenIdentifier) evaluate(context WhenContext) (interface{}, error) {
+	switch n.Name {
+	case "status":
+		return context.Status, nil
+	case "branch":
+		return context.Branch, nil
+	case "action":
+		return context.Action, nil
+	case "server":
+		return context.Server, nil
+	case "trigger":
+		return context.Trigger, nil
+	}
+	if strings.HasPrefix(n.Name, whenLabelPrefix) {
+		return context.Labels[strings.TrimPrefix(n.Name, whenLabelPrefix)], nil
+	}
+	return nil, fmt.Errorf("Unknown identifier %v", n.Name)
+}
+
+// String returns the literal as it would be written in an expression
+func (n *WhenLiteral) String() string {
+	if s, ok := n.Value.(string); ok {
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+	}
+	return strconv.FormatBool(n.Value.(bool))
+}
+
+func (n *WhenLiteral) kind() whenKind {
+	if _, ok := n.Value.(bool); ok {
+		return whenKindBool
+	}
+	return whenKindString
+}
+
+func (n *WhenLiteral) evaluate(context WhenContext) (interface{}, error) {
+	return n.Value, nil
+}
+
+// String returns the operator followed by its operand
+func (n *WhenUnary) String() string {
+	return n.Operator + n.Operand.String()
+}
+
+func (n *WhenUnary) kind() whenKind {
+	return whenKindBool
+}
+
+func (n *WhenUnary) evaluate(context WhenContext) (interface{}, error) {
+	value, err := n.Operand.evaluate(context)
+	if err != nil {
+		return nil, err
+	}
+	return !value.(bool), nil
+}
+
+// String returns both operands with the operator in between, in parentheses so the precedence is explicit
+func (n *WhenBinary) String() string {
+	return fmt.Sprintf("(%v %v %v)", n.Left, n.Operator, n.Right)
+}
+
+func (n *WhenBinary) kind() whenKind {
+	return whenKindBool
+}
+
+func (n *WhenBinary) evaluate(context WhenContext) (interface{}, error) {
+	left, err := n.Left.evaluate(context)
+	if err != nil {
+		return nil, err
+	}
+
+	// short-circuit logical operators, like the builder always did
+	switch n.Operator {
+	case "&&":
+		if !left.(bool) {
+			return false, nil
+		}
+	case "||":
+		if left.(bool) {
+			return true, nil
+		}
+	}
+
+	right, err := n.Right.evaluate(context)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Operator {
+	case "&&", "||":
+		return right.(bool), nil
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "=~", "!~":
+		// anchored like the regular expressions in trigger filters, so 'main' only matches main and not every branch containing it
+		pattern, err := regexp.Compile(fmt.Sprintf("^(%v)$", strings.TrimSpace(right.(string))))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression %v: %v", right, err)
+		}
+		return pattern.MatchString(left.(string)) == (n.Operator == "=~"), nil
+	}
+
+	return nil, fmt.Errorf("Unknown operator %v", n.Operator)
+}
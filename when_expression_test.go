@@ -0,0 +1,68 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateWhen(t *testing.T) {
+
+	context := WhenContext{
+		Status:  "succeeded",
+		Branch:  "release/1.2",
+		Action:  "deploy-canary",
+		Server:  "estafette",
+		Trigger: "git",
+		Labels:  map[string]string{"team": "estafette-team"},
+	}
+
+	testCases := []struct {
+		name       string
+		expression string
+		expected   bool
+	}{
+		{"ReturnsTrueForDefaultWhenIfSucceeded", "status == 'succeeded'", true},
+		{"ReturnsFalseForFailedStatus", "status == 'failed'", false},
+		{"ReturnsTrueIfEitherSideOfOrHolds", "status == 'failed' || branch != 'main'", true},
+		{"ReturnsFalseIfOneSideOfAndFails", "status == 'succeeded' && branch == 'main'", false},
+		{"ReturnsNegation", "!(action == 'deploy-stable')", true},
+		{"MatchesRegularExpressions", "branch =~ '^release/[0-9.]+$' && server !~ 'gocd'", true},
+		{"MatchesRegularExpressionsAgainstTheWholeValue", "branch =~ 'release/[0-9.]+' && branch !~ 'release'", true},
+		{"DoesNotMatchRegularExpressionsAgainstPartOfTheValue", "branch =~ 'release' || action =~ 'deploy'", false},
+		{"EvaluatesTrigger", "trigger == 'git'", true},
+		{"EvaluatesLabels", "labels.team == 'estafette-team'", true},
+		{"EvaluatesUnknownLabelsAsEmpty", "labels.app == ''", true},
+		{"ComparesBooleans", "(status == 'succeeded') == true", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// act
+			result, err := EvaluateWhen(tc.expression, context)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+
+	t.Run("ReturnsErrorForInvalidExpression", func(t *testing.T) {
+
+		// act
+		_, err := EvaluateWhen("status === 'succeeded'", context)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForInvalidRegularExpressionFromLabel", func(t *testing.T) {
+
+		expression, err := ParseWhen("branch =~ labels.pattern")
+		assert.Nil(t, err)
+
+		// act
+		_, err = expression.Evaluate(WhenContext{Labels: map[string]string{"pattern": "("}})
+
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,309 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// WhenSyntaxError is a problem found while parsing a when expression, located by its byte offset in the expression
+type WhenSyntaxError struct {
+	Offset  int
+	Message string
+}
+
+// Error returns the message followed by the offset
+func (e *WhenSyntaxError) Error() string {
+	return fmt.Sprintf("%v at offset %v", e.Message, e.Offset)
+}
+
+type whenTokenType int
+
+const (
+	whenTokenEOF whenTokenType = iota
+	whenTokenIdentifier
+	whenTokenString
+	whenTokenOperator
+	whenTokenLeftParen
+	whenTokenRightParen
+)
+
+type whenToken struct {
+	Type   whenTokenType
+	Value  string
+	Offset int
+}
+
+// String returns the token as shown in error messages
+func (t whenToken) String() string {
+	switch t.Type {
+	case whenTokenEOF:
+		return "end of expression"
+	case whenTokenString:
+		return fmt.Sprintf("string '%v'", t.Value)
+	}
+	return t.Value
+}
+
+// whenOperators lists the operators, longest first so == isn't lexed as =
+var whenOperators = []string{"==", "!=", "=~", "!~", "&&", "||", "!"}
+
+// ParseWhen parses a when expression; it returns a *WhenSyntaxError for syntax errors, unknown identifiers and operands of the wrong type
+func ParseWhen(expression string) (*WhenExpression, error) {
+
+	tokens, err := lexWhen(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &whenParser{tokens: tokens}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.Type != whenTokenEOF {
+		return nil, &WhenSyntaxError{Offset: t.Offset, Message: fmt.Sprintf("Unexpected %v", t)}
+	}
+	if root.kind() != whenKindBool {
+		return nil, &WhenSyntaxError{Offset: 0, Message: fmt.Sprintf("Expression %v is a string instead of a boolean", root)}
+	}
+
+	return &WhenExpression{Source: expression, Root: root}, nil
+}
+
+// lexWhen splits the expression into tokens
+func lexWhen(expression string) (tokens []whenToken, err error) {
+
+	for i := 0; i < len(expression); {
+		c := expression[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '(':
+			tokens = append(tokens, whenToken{Type: whenTokenLeftParen, Value: "(", Offset: i})
+			i++
+
+		case c == ')':
+			tokens = append(tokens, whenToken{Type: whenTokenRightParen, Value: ")", Offset: i})
+			i++
+
+		case c == '\'' || c == '"':
+			start := i
+			var value strings.Builder
+			for i++; ; i++ {
+				if i >= len(expression) {
+					return nil, &WhenSyntaxError{Offset: start, Message: "Unterminated string"}
+				}
+				if expression[i] == '\\' && i+1 < len(expression) {
+					i++
+					value.WriteByte(expression[i])
+					continue
+				}
+				if expression[i] == c {
+					i++
+					break
+				}
+				value.WriteByte(expression[i])
+			}
+			tokens = append(tokens, whenToken{Type: whenTokenString, Value: value.String(), Offset: start})
+
+		case isWhenIdentifierStart(c):
+			start := i
+			for i < len(expression) && (isWhenIdentifierStart(expression[i]) || isWhenDigit(expression[i]) || expression[i] == '.' || expression[i] == '-') {
+				i++
+			}
+			tokens = append(tokens, whenToken{Type: whenTokenIdentifier, Value: expression[start:i], Offset: start})
+
+		default:
+			operator := ""
+			for _, o := range whenOperators {
+				if strings.HasPrefix(expression[i:], o) {
+					operator = o
+					break
+				}
+			}
+			if operator == "" {
+				return nil, &WhenSyntaxError{Offset: i, Message: fmt.Sprintf("Unexpected character %q", c)}
+			}
+			tokens = append(tokens, whenToken{Type: whenTokenOperator, Value: operator, Offset: i})
+			i += len(operator)
+		}
+	}
+
+	return append(tokens, whenToken{Type: whenTokenEOF, Offset: len(expression)}), nil
+}
+
+func isWhenIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isWhenDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// whenParser is a recursive descent parser; from low to high precedence it handles ||, &&, !, comparisons and operands
+type whenParser struct {
+	tokens   []whenToken
+	position int
+}
+
+func (p *whenParser) peek() whenToken {
+	return p.tokens[p.position]
+}
+
+func (p *whenParser) next() whenToken {
+	t := p.tokens[p.position]
+	if t.Type != whenTokenEOF {
+		p.position++
+	}
+	return t
+}
+
+func (p *whenParser) parseOr() (WhenNode, error) {
+	return p.parseLogical("||", p.parseAnd)
+}
+
+func (p *whenParser) parseAnd() (WhenNode, error) {
+	return p.parseLogical("&&", p.parseNot)
+}
+
+func (p *whenParser) parseLogical(operator string, operand func() (WhenNode, error)) (WhenNode, error) {
+
+	offset := p.peek().Offset
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().Type == whenTokenOperator && p.peek().Value == operator {
+		if err := checkWhenBoolean(left, offset); err != nil {
+			return nil, err
+		}
+		p.next()
+
+		offset = p.peek().Offset
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkWhenBoolean(right, offset); err != nil {
+			return nil, err
+		}
+		left = &WhenBinary{Operator: operator, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// checkWhenBoolean returns an error if node, found at offset, isn't a boolean
+func checkWhenBoolean(node WhenNode, offset int) error {
+	if node.kind() != whenKindBool {
+		return &WhenSyntaxError{Offset: offset, Message: fmt.Sprintf("Expected a boolean instead of %v", node)}
+	}
+	return nil
+}
+
+func (p *whenParser) parseNot() (WhenNode, error) {
+
+	if t := p.peek(); t.Type == whenTokenOperator && t.Value == "!" {
+		p.next()
+		offset := p.peek().Offset
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkWhenBoolean(operand, offset); err != nil {
+			return nil, err
+		}
+		return &WhenUnary{Operator: "!", Operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *whenParser) parseComparison() (WhenNode, error) {
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.Type != whenTokenOperator || (t.Value != "==" && t.Value != "!=" && t.Value != "=~" && t.Value != "!~") {
+		return left, nil
+	}
+	p.next()
+
+	rightToken := p.peek()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.Value {
+	case "==", "!=":
+		if left.kind() != right.kind() {
+			return nil, &WhenSyntaxError{Offset: t.Offset, Message: fmt.Sprintf("Cannot compare %v %v with %v %v", left.kind(), left, right.kind(), right)}
+		}
+	case "=~", "!~":
+		if left.kind() != whenKindString || right.kind() != whenKindString {
+			return nil, &WhenSyntaxError{Offset: t.Offset, Message: fmt.Sprintf("Operator %v needs strings on both sides", t.Value)}
+		}
+		if literal, ok := right.(*WhenLiteral); ok {
+			if _, err := regexp.Compile(literal.Value.(string)); err != nil {
+				return nil, &WhenSyntaxError{Offset: rightToken.Offset, Message: fmt.Sprintf("Invalid regular expression %v", literal)}
+			}
+		}
+	}
+
+	return &WhenBinary{Operator: t.Value, Left: left, Right: right}, nil
+}
+
+func (p *whenParser) parseOperand() (WhenNode, error) {
+
+	t := p.next()
+
+	switch t.Type {
+	case whenTokenLeftParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.Type != whenTokenRightParen {
+			return nil, &WhenSyntaxError{Offset: closing.Offset, Message: fmt.Sprintf("Expected ) instead of %v", closing)}
+		}
+		return node, nil
+
+	case whenTokenString:
+		return &WhenLiteral{Value: t.Value}, nil
+
+	case whenTokenIdentifier:
+		switch t.Value {
+		case "true":
+			return &WhenLiteral{Value: true}, nil
+		case "false":
+			return &WhenLiteral{Value: false}, nil
+		}
+		if !isKnownWhenIdentifier(t.Value) {
+			return nil, &WhenSyntaxError{Offset: t.Offset, Message: fmt.Sprintf("Unknown identifier %v, use %v or %v<name>", t.Value, strings.Join(whenIdentifiers, ", "), whenLabelPrefix)}
+		}
+		return &WhenIdentifier{Name: t.Value}, nil
+	}
+
+	return nil, &WhenSyntaxError{Offset: t.Offset, Message: fmt.Sprintf("Unexpected %v", t)}
+}
+
+func isKnownWhenIdentifier(name string) bool {
+	if strings.HasPrefix(name, whenLabelPrefix) {
+		return len(name) > len(whenLabelPrefix)
+	}
+	for _, i := range whenIdentifiers {
+		if i == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWhen(t *testing.T) {
+	t.Run("ReturnsTreeRespectingOperatorPrecedence", func(t *testing.T) {
+
+		// act
+		expression, err := ParseWhen("status == 'failed' || branch == 'main' && !(server != \"estafette\")")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "((status == 'failed') || ((branch == 'main') && !(server != 'estafette')))", expression.String())
+	})
+
+	t.Run("ParsesExpressionsSpanningMultipleLines", func(t *testing.T) {
+
+		// act
+		expression, err := ParseWhen("status == 'succeeded' &&\n  branch =~ '^release/.+$'\n")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"branch", "status"}, expression.Identifiers())
+	})
+
+	t.Run("ParsesLabelsAndBooleanLiterals", func(t *testing.T) {
+
+		// act
+		expression, err := ParseWhen("labels.app-group == 'estafette' || true")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "((labels.app-group == 'estafette') || true)", expression.String())
+	})
+
+	testCases := []struct {
+		name       string
+		expression string
+		message    string
+	}{
+		{"ReturnsErrorForUnknownIdentifier", "stauts == 'succeeded'", "Unknown identifier stauts, use status, branch, action, server, trigger or labels.<name> at offset 0"},
+		{"ReturnsErrorForLabelWithoutName", "labels. == 'x'", "Unknown identifier labels., use status, branch, action, server, trigger or labels.<name> at offset 0"},
+		{"ReturnsErrorForUnterminatedString", "status == 'succeeded", "Unterminated string at offset 10"},
+		{"ReturnsErrorForSingleEquals", "status = 'succeeded'", "Unexpected character '=' at offset 7"},
+		{"ReturnsErrorForMissingOperand", "status == 'succeeded' &&", "Unexpected end of expression at offset 24"},
+		{"ReturnsErrorForMissingParenthesis", "(status == 'succeeded'", "Expected ) instead of end of expression at offset 22"},
+		{"ReturnsErrorForTrailingTokens", "status == 'succeeded' branch", "Unexpected branch at offset 22"},
+		{"ReturnsErrorForStringExpression", "status", "Expression status is a string instead of a boolean at offset 0"},
+		{"ReturnsErrorForStringOperandOfLogicalOperator", "branch && status == 'succeeded'", "Expected a boolean instead of branch at offset 0"},
+		{"ReturnsErrorForComparingStringWithBoolean", "status == true", "Cannot compare string status with boolean true at offset 7"},
+		{"ReturnsErrorForInvalidRegularExpression", "branch =~ 'release/(.+'", "Invalid regular expression 'release/(.+' at offset 10"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// act
+			_, err := ParseWhen(tc.expression)
+
+			if assert.NotNil(t, err) {
+				assert.IsType(t, &WhenSyntaxError{}, err)
+				assert.Equal(t, tc.message, err.Error())
+			}
+		})
+	}
+}